@@ -2,62 +2,135 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/mkaykisiz/sender"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/go-kit/kit/endpoint"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/go-kit/log"
 	"github.com/joho/godotenv"
 	envvars "github.com/mkaykisiz/sender/configs/env-vars"
+	"github.com/mkaykisiz/sender/internal/auth"
 	"github.com/mkaykisiz/sender/internal/client/messageclient"
+	"github.com/mkaykisiz/sender/internal/client/sandboxclient"
+	"github.com/mkaykisiz/sender/internal/clientip"
+	"github.com/mkaykisiz/sender/internal/encryption"
 	"github.com/mkaykisiz/sender/internal/localization"
 	"github.com/mkaykisiz/sender/internal/middlewares"
+	"github.com/mkaykisiz/sender/internal/ratelimit"
+	"github.com/mkaykisiz/sender/internal/recipientguard"
+	"github.com/mkaykisiz/sender/internal/redact"
 	"github.com/mkaykisiz/sender/internal/service"
+	"github.com/mkaykisiz/sender/internal/signing"
 	mongostore "github.com/mkaykisiz/sender/internal/store/mongo"
 	redisstore "github.com/mkaykisiz/sender/internal/store/redis"
+	"github.com/mkaykisiz/sender/internal/tracing"
+	grpctransport "github.com/mkaykisiz/sender/internal/transport/grpc"
+	"github.com/mkaykisiz/sender/internal/transport/grpc/pb"
 	httptransport "github.com/mkaykisiz/sender/internal/transport/http"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
 )
 
+// metricsNamespace is the namespace of prometheus metrics
+const metricsNamespace = "sender"
+
+// tracerShutdownTimeout bounds exporting pending spans on shutdown
+const tracerShutdownTimeout = 5 * time.Second
+
 func main() {
+	reencrypt := flag.Bool("reencrypt-messages", false, "re-encrypt stored messages with the active encryption key and exit")
+	configFile := flag.String("config", "", "yaml config file overridden by environment variables, CONFIG_FILE is used when empty")
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	flag.Parse()
+
 	var l log.Logger
 	{
 		l = log.NewLogfmtLogger(os.Stdout)
+		l = redact.NewLogger(l)
 		l = log.With(l, "time", log.DefaultTimestampUTC)
 	}
 
 	_ = godotenv.Load()
 
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+
 	var ev *envvars.EnvVars
 	var err error
 	{
-		ev, err = envvars.LoadEnvVars()
+		ev, err = envvars.LoadEnvVars(*configFile)
 		if err != nil {
 			_ = l.Log("error", err.Error())
 			return
 		}
 	}
 
+	if *printConfig {
+		if err := ev.Print(os.Stdout); err != nil {
+			_ = l.Log("error", err.Error())
+		}
+		return
+	}
+
 	err = localization.InitializeBundle(ev.Localization)
 	if err != nil {
 		_ = l.Log("error", err.Error())
 		return
 	}
 
+	var tp *sdktrace.TracerProvider
+	if ev.Tracing.Enabled {
+		tp, err = tracing.NewProvider(context.Background(), tracing.Config{
+			ServiceName: ev.Service.Name,
+			Environment: ev.Service.Environment,
+			Release:     ev.Service.Release,
+			Endpoint:    ev.Tracing.Endpoint,
+			SampleRatio: ev.Tracing.SampleRatio,
+		})
+		if err != nil {
+			_ = l.Log("error", err.Error())
+			return
+		}
+	}
+
+	var c *encryption.Cipher
+	{
+		c, err = newCipher(ev.Encryption)
+		if err != nil {
+			_ = l.Log("error", err.Error())
+			return
+		}
+	}
+
 	var ms mongostore.Store
 	{
-		ms, err = mongostore.NewStore(ev.Mongo)
+		ms, err = mongostore.NewStore(ev.Mongo, c, log.With(l, "component", "mongo"))
 		if err != nil {
 			_ = l.Log("error", err.Error())
 			return
 		}
-		seedMessages(context.Background(), l, ms, ev.Configs.StartMessageCount)
 	}
 
+	if *reencrypt {
+		reencryptMessages(context.Background(), l, ms)
+		return
+	}
+
+	seedMessages(context.Background(), l, ms, ev.Configs.StartMessageCount)
+
 	var rs redisstore.Store
 	{
 		rs, err = redisstore.NewStore(ev.Redis)
@@ -68,13 +141,88 @@ func main() {
 	}
 
 	var mc messageclient.MessageClient
-	{
-		mc = messageclient.NewClient(ev.MessageClient.Url, ev.MessageClient.AuthKey, ev.MessageClient.MaxRetries, ev.MessageClient.RetryDelay*time.Second)
+	if ev.Sandbox.Enabled {
+		if ev.Service.Environment == sender.Prod {
+			_ = l.Log("error", "sandbox provider cannot be enabled in prod")
+			return
+		}
+
+		mc, err = sandboxclient.NewClient(ms, ev.Sandbox.FailureRate, ev.Sandbox.FailRecipients)
+		if err != nil {
+			_ = l.Log("error", err.Error())
+			return
+		}
+		_ = l.Log("msg", "sandbox provider is enabled, messages are recorded instead of being delivered", "failureRate", ev.Sandbox.FailureRate)
+	} else {
+		var signer *signing.Signer
+		if ev.MessageClient.SigningSecret != "" {
+			signer = signing.NewSigner(ev.MessageClient.SigningKeyID, ev.MessageClient.SigningSecret)
+		}
+		cli := messageclient.NewClient(ev.MessageClient.Url, ev.MessageClient.AuthKey, ev.MessageClient.MaxRetries, ev.MessageClient.RetryDelay*time.Second, signer)
+		cli.SetMetrics(&messageclient.ClientMetrics{
+			Requests: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "provider",
+				Name:      "requests_total",
+				Help:      "Number of provider requests by http status.",
+			}, []string{"provider", "status"}),
+			Latency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "provider",
+				Name:      "request_duration_seconds",
+				Help:      "Latency of provider requests in seconds.",
+			}, []string{"provider", "status"}),
+		})
+		mc = cli
 	}
 
 	var w *service.Worker
 	{
 		w = service.NewWorker(mc, ms, rs, log.With(l, "component", "worker"), int64(ev.Configs.StartMessageCount))
+		w.SetMetrics(&service.WorkerMetrics{
+			BatchSize: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "worker",
+				Name:      "batch_size",
+				Help:      "Number of messages taken in a batch.",
+				Buckets:   stdprometheus.LinearBuckets(0, 1, 11),
+			}, []string{}),
+			Sends: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "worker",
+				Name:      "sends_total",
+				Help:      "Number of messages processed by outcome.",
+			}, []string{"outcome"}),
+			SendLatency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "worker",
+				Name:      "send_duration_seconds",
+				Help:      "Latency of sending a message to the provider in seconds.",
+			}, []string{}),
+			QueueDepth: kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "worker",
+				Name:      "queue_depth",
+				Help:      "Number of due pending and failed messages and expired claims when a batch is taken.",
+			}, []string{}),
+		})
+		if err := w.SetClaimLease(ev.Configs.WorkerClaimLease); err != nil {
+			_ = l.Log("error", err.Error())
+			return
+		}
+	}
+
+	if ev.Service.Environment != sender.Prod {
+		g := recipientguard.NewGuard(ev.Service.Environment, ev.RecipientGuard.AllowedNumbers, ev.RecipientGuard.AllowedPrefixes)
+		w.SetRecipientGuard(g)
+
+		details := g.Details()
+		_ = l.Log("msg", "recipient guard is enabled, messages to recipients outside the allow-list are blocked",
+			"environment", ev.Service.Environment, "allowedNumbers", details["allowed_numbers"], "allowedPrefixes", details["allowed_prefixes"])
+		if g.IsEmpty() {
+			_ = l.Log("level", "warn", "msg", "recipient allow-list is empty, every message is blocked until "+
+				"RECIPIENT_ALLOWED_NUMBERS or RECIPIENT_ALLOWED_PREFIXES is set, or SERVICE_ENVIRONMENT is prod", "environment", ev.Service.Environment)
+		}
 	}
 
 	var s sender.Service
@@ -90,9 +238,72 @@ func main() {
 		s = lm(s)
 	}
 
+	var im middlewares.Middleware
+	{
+		im = middlewares.NewInstrumentingMiddleware(
+			kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "service",
+				Name:      "requests_total",
+				Help:      "Number of requests by method.",
+			}, []string{"method", "error"}),
+			kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Subsystem: "service",
+				Name:      "request_duration_seconds",
+				Help:      "Latency of requests in seconds.",
+			}, []string{"method", "error"}),
+		)
+
+		s = im(s)
+	}
+
+	var tm middlewares.Middleware
+	{
+		tm = middlewares.NewTracingMiddleware()
+
+		s = tm(s)
+	}
+
+	var am auth.Middleware
+	{
+		am = auth.NopMiddleware
+		if ev.Auth.Enabled {
+			seedAPIKey(context.Background(), l, ms, ev.Auth.BootstrapAPIKey)
+			am = auth.NewMiddleware(ms)
+		}
+	}
+
+	var v *signing.Verifier
+	{
+		keys, err := signing.ParseKeys(ev.Callback.SigningKeys)
+		if err != nil {
+			_ = l.Log("error", err.Error())
+			return
+		}
+		v = signing.NewVerifier(keys, ev.Callback.SignatureTolerance, rs)
+	}
+
+	var rl endpoint.Middleware
+	{
+		rl = ratelimit.NopMiddleware
+		if ev.RateLimit.Enabled {
+			rl = ratelimit.NewMiddleware(rs, ev.RateLimit.Requests, ev.RateLimit.Window, log.With(l, "component", "rate-limit"))
+		}
+	}
+
+	var ipr *clientip.Resolver
+	{
+		ipr, err = clientip.NewResolver(ev.HTTPServer.TrustedProxies)
+		if err != nil {
+			_ = l.Log("error", err.Error())
+			return
+		}
+	}
+
 	var h http.Handler
 	{
-		h = httptransport.MakeHTTPHandler(log.With(l, "transport", "http"), s)
+		h = httptransport.MakeHTTPHandler(log.With(l, "transport", "http"), s, am, rl, v, ipr)
 	}
 
 	var hs *http.Server
@@ -105,6 +316,23 @@ func main() {
 			MaxHeaderBytes: ev.HTTPServer.MaxHeaderBytes,
 			Handler:        h,
 		}
+		httptransport.CloseStreamsOnShutdown(hs)
+	}
+
+	var mhs *http.Server
+	{
+		mhs = &http.Server{
+			Addr:        ev.HTTPServer.MetricsAddress,
+			ReadTimeout: ev.HTTPServer.ReadTimeout,
+			IdleTimeout: ev.HTTPServer.IdleTimeout,
+			Handler:     httptransport.MakeMetricsHandler(),
+		}
+	}
+
+	var gs *grpc.Server
+	{
+		gs = grpc.NewServer()
+		pb.RegisterSenderServer(gs, grpctransport.NewGRPCServer(log.With(l, "transport", "grpc"), s, am, rl))
 	}
 
 	// set service health status to true
@@ -127,6 +355,29 @@ func main() {
 		}
 	}()
 
+	go func() {
+		_ = l.Log("transport", "metrics", "address", ev.HTTPServer.MetricsAddress)
+
+		err := mhs.ListenAndServe()
+		if err != http.ErrServerClosed {
+			errs <- err
+		}
+	}()
+
+	go func() {
+		_ = l.Log("transport", "grpc", "address", ev.GRPCServer.Address)
+
+		lis, err := net.Listen("tcp", ev.GRPCServer.Address)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		if err := gs.Serve(lis); err != nil {
+			errs <- err
+		}
+	}()
+
 	err = <-errs
 	if err != nil {
 		_ = l.Log("error", err.Error())
@@ -147,6 +398,18 @@ func main() {
 	if err := hs.Shutdown(ctx); err != nil {
 		_ = l.Log("error", err.Error())
 	}
+	if err := mhs.Shutdown(ctx); err != nil {
+		_ = l.Log("error", err.Error())
+	}
+
+	stopGRPCServer(gs, ev.GRPCServer.ShutdownTimeout)
+
+	// stores are closed once the running batch has written its statuses and claims are released
+	dctx, dcf := context.WithTimeout(context.Background(), ev.Configs.WorkerDrainTimeout)
+	if err := w.Drain(dctx); err != nil {
+		_ = l.Log("error", err.Error())
+	}
+	dcf()
 
 	if err := rs.Close(); err != nil {
 		_ = l.Log("error", err.Error())
@@ -156,9 +419,91 @@ func main() {
 		_ = l.Log("error", err.Error())
 	}
 
+	// export spans which are not exported yet, shutdown context of servers is likely spent by now
+	if tp != nil {
+		tctx, tcf := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+		if err := tp.Shutdown(tctx); err != nil {
+			_ = l.Log("error", err.Error())
+		}
+		tcf()
+	}
+
 	_ = l.Log("shutdown", ev.Service.Name)
 }
 
+// stopGRPCServer waits for in-flight rpcs to finish until timeout, then closes remaining connections
+func stopGRPCServer(gs *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		gs.Stop()
+	}
+}
+
+// newCipher returns cipher of encryption configuration, nil is returned when no keys are given
+func newCipher(e envvars.Encryption) (*encryption.Cipher, error) {
+	if e.Keys == "" {
+		return nil, nil
+	}
+
+	keys, err := encryption.ParseKeys(e.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(e.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding blind index key failed, %s", err.Error())
+	}
+
+	return encryption.NewCipher(keys, e.ActiveKeyID, indexKey)
+}
+
+func reencryptMessages(ctx context.Context, l log.Logger, ms mongostore.Store) {
+	defer func() {
+		if err := ms.Close(); err != nil {
+			_ = l.Log("method", "reencryptMessages", "error", err.Error())
+		}
+	}()
+
+	reencrypted, skipped, err := ms.ReencryptMessages(ctx)
+	if err != nil {
+		_ = l.Log("method", "reencryptMessages", "error", err.Error(), "reencrypted", reencrypted, "skipped", skipped)
+		return
+	}
+
+	_ = l.Log("method", "reencryptMessages", "msg", "re-encrypted messages and sandbox messages", "reencrypted", reencrypted, "skipped", skipped)
+}
+
+// seedAPIKey stores bootstrap api key unless it is stored already, a revoked bootstrap key stays revoked
+func seedAPIKey(ctx context.Context, l log.Logger, ms mongostore.Store, key string) {
+	if key == "" {
+		return
+	}
+
+	_, err := ms.GetAPIKeyByHash(ctx, auth.HashKey(key))
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, mongostore.ErrAPIKeyNotFound) {
+		_ = l.Log("method", "seedAPIKey", "error", err.Error())
+		return
+	}
+
+	if _, err := ms.InsertAPIKey(ctx, auth.NewAPIKey(key, "bootstrap", sender.RoleScopes[sender.RoleAdmin], "")); err != nil {
+		_ = l.Log("method", "seedAPIKey", "error", err.Error())
+		return
+	}
+
+	_ = l.Log("method", "seedAPIKey", "msg", "stored bootstrap api key")
+}
+
 func seedMessages(ctx context.Context, l log.Logger, ms mongostore.Store, startMessageCount int) {
 	count, err := ms.Count(ctx, mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING}})
 	if err != nil {
@@ -2,132 +2,195 @@ package envvars
 
 import (
 	"fmt"
+	"reflect"
 	"time"
-
-	"github.com/codingconcepts/env"
 )
 
 // EnvVars represents environment variables
 type EnvVars struct {
-	Service       Service
-	Mongo         Mongo
-	Redis         Redis
-	Localization  Localization
-	HTTPServer    HTTPServer
-	Configs       Configs
-	MessageClient MessageClient
+	Service        Service        `yaml:"service"`
+	Mongo          Mongo          `yaml:"mongo"`
+	Redis          Redis          `yaml:"redis"`
+	Localization   Localization   `yaml:"localization"`
+	HTTPServer     HTTPServer     `yaml:"http_server"`
+	GRPCServer     GRPCServer     `yaml:"grpc_server"`
+	Auth           Auth           `yaml:"auth"`
+	Configs        Configs        `yaml:"configs"`
+	MessageClient  MessageClient  `yaml:"message_client"`
+	Callback       Callback       `yaml:"callback"`
+	Encryption     Encryption     `yaml:"encryption"`
+	RateLimit      RateLimit      `yaml:"rate_limit"`
+	Tracing        Tracing        `yaml:"tracing"`
+	Sandbox        Sandbox        `yaml:"sandbox"`
+	RecipientGuard RecipientGuard `yaml:"recipient_guard"`
 }
 
 // Configs represents environment configs
 type Configs struct {
-	StartMessageCount int           `env:"CONFIG_START_MESSAGE_COUNT" default:"2"`
-	SendMessageDelay  time.Duration `env:"CONFIG_SEND_MESSAGE_DURATION" default:"120s"`
+	StartMessageCount  int           `env:"CONFIG_START_MESSAGE_COUNT" default:"2" yaml:"start_message_count"`
+	SendMessageDelay   time.Duration `env:"CONFIG_SEND_MESSAGE_DURATION" default:"120s" yaml:"send_message_delay"`
+	StatsWindowMinutes int           `env:"CONFIG_STATS_WINDOW_MINUTES" default:"15" yaml:"stats_window_minutes"`
+	StatsCacheTTL      time.Duration `env:"CONFIG_STATS_CACHE_TTL" default:"10s" yaml:"stats_cache_ttl"`
+	// HealthCheckTimeout bounds each dependency check of readiness probe
+	HealthCheckTimeout time.Duration `env:"CONFIG_HEALTH_CHECK_TIMEOUT" default:"2s" yaml:"health_check_timeout"`
+	// WorkerDrainTimeout bounds waiting for the running batch on shutdown, the batch is cancelled afterwards
+	WorkerDrainTimeout time.Duration `env:"CONFIG_WORKER_DRAIN_TIMEOUT" default:"30s" yaml:"worker_drain_timeout"`
+	// WorkerClaimLease is how long a worker holds claimed messages, they are claimed again once it expires
+	WorkerClaimLease time.Duration `env:"CONFIG_WORKER_CLAIM_LEASE" default:"5m" yaml:"worker_claim_lease"`
 }
 
 // MessageClient represents message client webhook
 type MessageClient struct {
-	Url string `env:"MESSAGE_CLIENT_URL" required:"true"`
-	AuthKey string `env:"MESSAGE_CLIENT_AUTH_KEY" required:"true"`
-	Timeout time.Duration `env:"MESSAGE_CLIENT_TIMEOUT" default:"30s"`
-	MaxRetries int `env:"MESSAGE_CLIENT_MAX_RETRIES" default:"3"`
-	RetryDelay time.Duration `env:"MESSAGE_CLIENT_RETRY_DELAY" default:"1s"`
+	Url        string        `env:"MESSAGE_CLIENT_URL" required:"true" yaml:"url"`
+	AuthKey    string        `env:"MESSAGE_CLIENT_AUTH_KEY" required:"true" yaml:"auth_key" secret:"true"`
+	Timeout    time.Duration `env:"MESSAGE_CLIENT_TIMEOUT" default:"30s" yaml:"timeout"`
+	MaxRetries int           `env:"MESSAGE_CLIENT_MAX_RETRIES" default:"3" yaml:"max_retries"`
+	RetryDelay time.Duration `env:"MESSAGE_CLIENT_RETRY_DELAY" default:"1s" yaml:"retry_delay"`
+	// requests are signed with hmac-sha256 when signing secret is given
+	SigningKeyID  string `env:"MESSAGE_CLIENT_SIGNING_KEY_ID" yaml:"signing_key_id"`
+	SigningSecret string `env:"MESSAGE_CLIENT_SIGNING_SECRET" yaml:"signing_secret" secret:"true"`
+}
+
+// Callback represents configurations of inbound provider callbacks
+type Callback struct {
+	// SigningKeys holds comma separated id:secret pairs, requests signed with any of them are accepted
+	SigningKeys        string        `env:"CALLBACK_SIGNING_KEYS" yaml:"signing_keys" secret:"true"`
+	SignatureTolerance time.Duration `env:"CALLBACK_SIGNATURE_TOLERANCE" default:"5m" yaml:"signature_tolerance"`
+}
+
+// Encryption represents configurations of message encryption at rest, messages are stored in
+// plaintext when no keys are given
+type Encryption struct {
+	// Keys holds comma separated id:key pairs whose keys are base64 encoded 32 bytes
+	Keys          string `env:"ENCRYPTION_KEYS" yaml:"keys" secret:"true"`
+	ActiveKeyID   string `env:"ENCRYPTION_ACTIVE_KEY_ID" yaml:"active_key_id"`
+	BlindIndexKey string `env:"ENCRYPTION_BLIND_INDEX_KEY" yaml:"blind_index_key" secret:"true"`
+}
+
+// RateLimit represents configurations of inbound api rate limiting, clients are limited per api key or
+// per ip address when requests are not authenticated
+type RateLimit struct {
+	Enabled  bool          `env:"RATE_LIMIT_ENABLED" default:"true" yaml:"enabled"`
+	Requests int64         `env:"RATE_LIMIT_REQUESTS" default:"600" yaml:"requests"`
+	Window   time.Duration `env:"RATE_LIMIT_WINDOW" default:"1m" yaml:"window"`
+}
+
+// Tracing represents configurations of opentelemetry tracing, spans are exported over otlp http
+type Tracing struct {
+	Enabled bool `env:"TRACING_ENABLED" default:"false" yaml:"enabled"`
+	// Endpoint is the otlp http url spans are exported to, OTEL_EXPORTER_OTLP_* variables are used when empty
+	Endpoint    string  `env:"TRACING_ENDPOINT" yaml:"endpoint"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" default:"1" yaml:"sample_ratio"`
+}
+
+// Sandbox represents configurations of sandbox provider, messages are recorded instead of being delivered
+// when it is enabled. It cannot be enabled in prod.
+type Sandbox struct {
+	Enabled     bool    `env:"SANDBOX_ENABLED" default:"false" yaml:"enabled"`
+	FailureRate float64 `env:"SANDBOX_FAILURE_RATE" default:"0" yaml:"failure_rate"`
+	// FailRecipients holds comma separated recipients whose sends always fail
+	FailRecipients string `env:"SANDBOX_FAIL_RECIPIENTS" yaml:"fail_recipients"`
+}
+
+// RecipientGuard represents the allow-list of recipients messages can be sent to outside prod, messages to
+// other recipients are blocked. Every recipient is blocked when neither numbers nor prefixes are given.
+type RecipientGuard struct {
+	// AllowedNumbers holds comma separated recipients
+	AllowedNumbers string `env:"RECIPIENT_ALLOWED_NUMBERS" yaml:"allowed_numbers"`
+	// AllowedPrefixes holds comma separated prefixes, e.g. +90555000
+	AllowedPrefixes string `env:"RECIPIENT_ALLOWED_PREFIXES" yaml:"allowed_prefixes"`
 }
 
 // Service represents service configurations
 type Service struct {
-	ProjectName           string        `env:"SERVICE_PROJECT_NAME" required:"true"`
-	Name                  string        `env:"SERVICE_NAME" required:"true"`
-	Environment           string        `env:"SERVICE_ENVIRONMENT" default:"dev"`
-	Release               string        `env:"SERVICE_RELEASE"`
-	ShutdownSleepDuration time.Duration `env:"SERVICE_SHUTDOWN_SLEEP_DURATION" default:"30s"`
+	ProjectName           string        `env:"SERVICE_PROJECT_NAME" required:"true" yaml:"project_name"`
+	Name                  string        `env:"SERVICE_NAME" required:"true" yaml:"name"`
+	Environment           string        `env:"SERVICE_ENVIRONMENT" default:"dev" yaml:"environment"`
+	Release               string        `env:"SERVICE_RELEASE" yaml:"release"`
+	ShutdownSleepDuration time.Duration `env:"SERVICE_SHUTDOWN_SLEEP_DURATION" default:"30s" yaml:"shutdown_sleep_duration"`
 }
 
 // Mongo represents mongo configurations
 type Mongo struct {
-	URI               string        `env:"MONGO_URI" required:"true"`
-	Database          string        `env:"MONGO_DATABASE" required:"true"`
-	ConnectTimeout    time.Duration `env:"MONGO_CONNECT_TIMEOUT" default:"10s"`
-	PingTimeout       time.Duration `env:"MONGO_PING_TIMEOUT" default:"10s"`
-	ReadTimeout       time.Duration `env:"MONGO_READ_TIMEOUT" default:"10s"`
-	WriteTimeout      time.Duration `env:"MONGO_WRITE_TIMEOUT" default:"5s"`
-	DisconnectTimeout time.Duration `env:"MONGO_DISCONNECT_TIMEOUT" default:"5s"`
+	URI               string        `env:"MONGO_URI" required:"true" yaml:"uri" secret:"url"`
+	Database          string        `env:"MONGO_DATABASE" required:"true" yaml:"database"`
+	ConnectTimeout    time.Duration `env:"MONGO_CONNECT_TIMEOUT" default:"10s" yaml:"connect_timeout"`
+	PingTimeout       time.Duration `env:"MONGO_PING_TIMEOUT" default:"10s" yaml:"ping_timeout"`
+	ReadTimeout       time.Duration `env:"MONGO_READ_TIMEOUT" default:"10s" yaml:"read_timeout"`
+	WriteTimeout      time.Duration `env:"MONGO_WRITE_TIMEOUT" default:"5s" yaml:"write_timeout"`
+	DisconnectTimeout time.Duration `env:"MONGO_DISCONNECT_TIMEOUT" default:"5s" yaml:"disconnect_timeout"`
 }
 
 // Redis represents redis configurations
 type Redis struct {
-	Address            string        `env:"REDIS_ADDRESS" required:"true"`
-	Username           string        `env:"REDIS_USERNAME"`
-	Password           string        `env:"REDIS_PASSWORD"`
-	DB                 int           `env:"REDIS_DB"`
-	DialTimeout        time.Duration `env:"REDIS_DIAL_TIMEOUT" default:"5s"`
-	ReadTimeout        time.Duration `env:"REDIS_READ_TIMEOUT" default:"10s"`
-	WriteTimeout       time.Duration `env:"REDIS_WRITE_TIMEOUT" default:"10s"`
-	PoolSize           int           `env:"REDIS_POOL_SIZE" default:"10"`
-	MinIdleConnections int           `env:"REDIS_MIN_IDLE_CONNECTIONS" default:"5"`
-	MaxConnectionAge   time.Duration `env:"REDIS_MAX_CONNECTION_AGE" default:"5m"`
-	IdleTimeout        time.Duration `env:"REDIS_IDLE_TIMEOUT" default:"5m"`
+	Address            string        `env:"REDIS_ADDRESS" required:"true" yaml:"address"`
+	Username           string        `env:"REDIS_USERNAME" yaml:"username"`
+	Password           string        `env:"REDIS_PASSWORD" yaml:"password" secret:"true"`
+	DB                 int           `env:"REDIS_DB" yaml:"db"`
+	DialTimeout        time.Duration `env:"REDIS_DIAL_TIMEOUT" default:"5s" yaml:"dial_timeout"`
+	ReadTimeout        time.Duration `env:"REDIS_READ_TIMEOUT" default:"10s" yaml:"read_timeout"`
+	WriteTimeout       time.Duration `env:"REDIS_WRITE_TIMEOUT" default:"10s" yaml:"write_timeout"`
+	PoolSize           int           `env:"REDIS_POOL_SIZE" default:"10" yaml:"pool_size"`
+	MinIdleConnections int           `env:"REDIS_MIN_IDLE_CONNECTIONS" default:"5" yaml:"min_idle_connections"`
+	MaxConnectionAge   time.Duration `env:"REDIS_MAX_CONNECTION_AGE" default:"5m" yaml:"max_connection_age"`
+	IdleTimeout        time.Duration `env:"REDIS_IDLE_TIMEOUT" default:"5m" yaml:"idle_timeout"`
 }
 
 // Localization represents localization configurations
 type Localization struct {
-	LanguageFilesDirectory string `env:"LOCALIZATION_LANGUAGE_FILES_DIRECTORY" required:"true"`
+	LanguageFilesDirectory string `env:"LOCALIZATION_LANGUAGE_FILES_DIRECTORY" required:"true" yaml:"language_files_directory"`
 }
 
 // HTTPServer represents http server configurations
 type HTTPServer struct {
-	Address         string        `env:"HTTP_SERVER_ADDRESS" default:":8004"`
-	ReadTimeout     time.Duration `env:"HTTP_SERVER_READ_TIMEOUT" default:"15s"`
-	WriteTimeout    time.Duration `env:"HTTP_SERVER_WRITE_TIMEOUT" default:"15s"`
-	IdleTimeout     time.Duration `env:"HTTP_SERVER_IDLE_TIMEOUT" default:"15s"`
-	MaxHeaderBytes  int           `env:"HTTP_SERVER_MAX_HEADER_BYTES" default:"1048576"`
-	ShutdownTimeout time.Duration `env:"HTTP_SERVER_SHUTDOWN_TIMEOUT" default:"10s"`
-}
-
-// LoadEnvVars loads and returns environment variables
-func LoadEnvVars() (*EnvVars, error) {
-	s := Service{}
-	if err := env.Set(&s); err != nil {
-		return nil, fmt.Errorf("loading service environment variables failed, %s", err.Error())
-	}
-
-	m := Mongo{}
-	if err := env.Set(&m); err != nil {
-		return nil, fmt.Errorf("loading mongo environment variables failed, %s", err.Error())
-	}
-
-	r := Redis{}
-	if err := env.Set(&r); err != nil {
-		return nil, fmt.Errorf("loading redis environment variables failed, %s", err.Error())
-	}
+	Address         string        `env:"HTTP_SERVER_ADDRESS" default:":8004" yaml:"address"`
+	ReadTimeout     time.Duration `env:"HTTP_SERVER_READ_TIMEOUT" default:"15s" yaml:"read_timeout"`
+	WriteTimeout    time.Duration `env:"HTTP_SERVER_WRITE_TIMEOUT" default:"15s" yaml:"write_timeout"`
+	IdleTimeout     time.Duration `env:"HTTP_SERVER_IDLE_TIMEOUT" default:"15s" yaml:"idle_timeout"`
+	MaxHeaderBytes  int           `env:"HTTP_SERVER_MAX_HEADER_BYTES" default:"1048576" yaml:"max_header_bytes"`
+	ShutdownTimeout time.Duration `env:"HTTP_SERVER_SHUTDOWN_TIMEOUT" default:"10s" yaml:"shutdown_timeout"`
+	// TrustedProxies holds comma separated ip addresses or cidr ranges whose forwarding headers are trusted
+	TrustedProxies string `env:"HTTP_SERVER_TRUSTED_PROXIES" yaml:"trusted_proxies"`
+	// MetricsAddress is the listen address of /metrics, it is kept apart from the api and exposed only internally
+	MetricsAddress string `env:"HTTP_SERVER_METRICS_ADDRESS" default:":8005" yaml:"metrics_address"`
+}
 
-	l := Localization{}
-	if err := env.Set(&l); err != nil {
-		return nil, fmt.Errorf("loading localization environment variables failed, %s", err.Error())
-	}
+// GRPCServer represents grpc server configurations
+type GRPCServer struct {
+	Address         string        `env:"GRPC_SERVER_ADDRESS" default:":9004" yaml:"address"`
+	ShutdownTimeout time.Duration `env:"GRPC_SERVER_SHUTDOWN_TIMEOUT" default:"10s" yaml:"shutdown_timeout"`
+}
 
-	hs := HTTPServer{}
-	if err := env.Set(&hs); err != nil {
-		return nil, fmt.Errorf("loading http server environment variables failed, %s", err.Error())
-	}
+// Auth represents api key authentication configurations
+type Auth struct {
+	Enabled bool `env:"AUTH_ENABLED" default:"true" yaml:"enabled"`
+	// BootstrapAPIKey is stored on startup unless it is stored already, it is used to create the first api keys
+	BootstrapAPIKey string `env:"AUTH_BOOTSTRAP_API_KEY" yaml:"bootstrap_api_key" secret:"true"`
+}
 
-	cfg := Configs{}
-	if err := env.Set(&cfg); err != nil {
-		return nil, fmt.Errorf("loading http server environment variables failed, %s", err.Error())
+// LoadEnvVars loads and returns configurations. Defaults are overridden by configFile, which is overridden
+// by environment variables. configFile is an optional yaml file keyed by yaml tags, its unknown keys are
+// rejected.
+func LoadEnvVars(configFile string) (*EnvVars, error) {
+	ev := &EnvVars{}
+	v := reflect.ValueOf(ev).Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		if err := setDefaults(v.Field(i)); err != nil {
+			return nil, fmt.Errorf("loading %s defaults failed, %s", sectionName(v.Type().Field(i)), err.Error())
+		}
 	}
 
-	msg := MessageClient{}
-	if err := env.Set(&msg); err != nil {
-		return nil, fmt.Errorf("loading http server environment variables failed, %s", err.Error())
+	if configFile != "" {
+		if err := decodeFile(configFile, ev); err != nil {
+			return nil, fmt.Errorf("loading config file %s failed, %s", configFile, err.Error())
+		}
 	}
 
-	ev := &EnvVars{
-		Service:       s,
-		Mongo:         m,
-		Redis:         r,
-		Localization:  l,
-		HTTPServer:    hs,
-		Configs:       cfg,
-		MessageClient: msg,
+	for i := 0; i < v.NumField(); i++ {
+		if err := setEnv(v.Field(i), v.Type().Field(i)); err != nil {
+			return nil, fmt.Errorf("loading %s environment variables failed, %s", sectionName(v.Type().Field(i)), err.Error())
+		}
 	}
 
 	return ev, nil
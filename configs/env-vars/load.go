@@ -0,0 +1,114 @@
+package envvars
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decodeFile overrides fields of ev with values of yaml file at path, fields missing in file are kept
+func decodeFile(path string, ev *EnvVars) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := yaml.NewDecoder(f)
+	d.KnownFields(true)
+	if err := d.Decode(ev); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// setDefaults sets fields of section to their default tags
+func setDefaults(section reflect.Value) error {
+	for i := 0; i < section.NumField(); i++ {
+		f := section.Type().Field(i)
+		d, ok := f.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		if err := setValue(section.Field(i), d); err != nil {
+			return fmt.Errorf("error setting %q: %v", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// setEnv sets fields of section to environment variables of their env tags which are set, required fields
+// which are still empty are reported
+func setEnv(section reflect.Value, sf reflect.StructField) error {
+	for i := 0; i < section.NumField(); i++ {
+		f := section.Type().Field(i)
+		envTag, ok := f.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+
+		if value, ok := os.LookupEnv(envTag); ok {
+			if err := setValue(section.Field(i), value); err != nil {
+				return fmt.Errorf("error setting %q: %v", f.Name, err)
+			}
+		}
+
+		if f.Tag.Get("required") == "true" && section.Field(i).IsZero() {
+			return fmt.Errorf("%s environment variable or %s.%s config key was missing", envTag, sf.Tag.Get("yaml"), f.Tag.Get("yaml"))
+		}
+	}
+
+	return nil
+}
+
+// setValue parses s into v by kind of v
+func setValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// sectionName returns readable name of section used in errors, e.g. http server
+func sectionName(sf reflect.StructField) string {
+	return strings.ReplaceAll(sf.Tag.Get("yaml"), "_", " ")
+}
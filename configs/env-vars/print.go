@@ -0,0 +1,58 @@
+package envvars
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets, it matches redact.Redacted which cannot be imported here since redact
+// depends on this package through sender
+const redacted = "[redacted]"
+
+// Redacted returns copy of ev whose fields tagged as secret are redacted. Only the password of url
+// secrets is redacted so that their hosts remain visible.
+func (ev EnvVars) Redacted() EnvVars {
+	v := reflect.ValueOf(&ev).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		section := v.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			f := section.Field(j)
+			if f.Kind() != reflect.String || f.String() == "" {
+				continue
+			}
+
+			switch section.Type().Field(j).Tag.Get("secret") {
+			case "true":
+				f.SetString(redacted)
+			case "url":
+				f.SetString(redactURL(f.String()))
+			}
+		}
+	}
+
+	return ev
+}
+
+// Print writes ev with its secrets redacted to w as yaml, the output can be used as a config file
+func (ev EnvVars) Print(w io.Writer) error {
+	e := yaml.NewEncoder(w)
+	e.SetIndent(2)
+	if err := e.Encode(ev.Redacted()); err != nil {
+		return err
+	}
+
+	return e.Close()
+}
+
+// redactURL redacts password of u, u is redacted as a whole when it cannot be parsed
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return redacted
+	}
+
+	return parsed.Redacted()
+}
@@ -17,13 +17,15 @@
 //	SecurityDefinitions:
 //	api_key:
 //	     type: apiKey
-//	     name: KEY
+//	     name: X-API-Key
 //	     in: header
 //
 // swagger:meta
 package docs
 
 import (
+	"time"
+
 	"github.com/mkaykisiz/sender"
 )
 
@@ -68,6 +70,24 @@ type requestHeader struct {
 	AcceptLanguage string `json:"Accept-Language"`
 }
 
+// Success, worker health is reported as its component
+// swagger:response livenessResponse
+type livenessResponse struct {
+	Body struct {
+		Components map[string]sender.ComponentHealth `json:"components"`
+		Result     *apiError                         `json:"result"`
+	}
+}
+
+// Success, mongo, redis and worker health are reported as components
+// swagger:response readinessResponse
+type readinessResponse struct {
+	Body struct {
+		Components map[string]sender.ComponentHealth `json:"components"`
+		Result     *apiError                         `json:"result"`
+	}
+}
+
 // swagger:parameters startStopMessageSendingRequest
 type startStopMessageSendingRequest struct {
 	requestHeader
@@ -88,16 +108,440 @@ type startStopMessageSendingResponse struct {
 	}
 }
 
+// swagger:parameters getWorkerStatusRequest
+type getWorkerStatusRequest struct {
+	requestHeader
+	// number of recent batches, every kept batch is returned when not given
+	// in: query
+	// minimum: 1
+	// maximum: 20
+	Batches int `json:"batches"`
+}
+
+// Success
+// swagger:response getWorkerStatusResponse
+type getWorkerStatusResponse struct {
+	Body struct {
+		Status *sender.WorkerStatus `json:"status"`
+		Result *apiError            `json:"result"`
+	}
+}
+
+// swagger:parameters runWorkerBatchRequest
+type runWorkerBatchRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		DryRun bool `json:"dry_run"`
+	}
+}
+
+// Success
+// swagger:response runWorkerBatchResponse
+type runWorkerBatchResponse struct {
+	Body struct {
+		Status   string               `json:"status"`
+		Messages []sender.PlannedSend `json:"messages"`
+		Result   *apiError            `json:"result"`
+	}
+}
+
 // swagger:parameters retrieveSentMessagesRequest
 type retrieveSentMessagesRequest struct {
 	requestHeader
+	// next_cursor of the previous page
+	// in: query
+	Cursor string `json:"cursor"`
+	// in: query
+	// minimum: 1
+	// maximum: 100
+	// default: 20
+	Limit int64 `json:"limit"`
+	// in: query
+	Recipient string `json:"recipient"`
+	// in: query
+	// default: sent
+	Status []string `json:"status"`
+	// only messages having all of the tags
+	// in: query
+	Tags []string `json:"tag"`
+	// in: query
+	CreatedFrom *time.Time `json:"created_from"`
+	// in: query
+	CreatedTo *time.Time `json:"created_to"`
+	// in: query
+	SentFrom *time.Time `json:"sent_from"`
+	// in: query
+	SentTo *time.Time `json:"sent_to"`
+	// in: query
+	// enum: ["created_at", "sent_at"]
+	// default: created_at
+	SortBy string `json:"sort_by"`
+	// in: query
+	// enum: ["asc", "desc"]
+	// default: desc
+	SortOrder string `json:"sort_order"`
 }
 
 // Success
 // swagger:response retrieveSentMessagesResponse
 type retrieveSentMessagesResponse struct {
 	Body struct {
-		Messages []sender.MessageTransaction `json:"messages"`
-		Result   *apiError                   `json:"result"`
+		Messages   []sender.ResponseMessage `json:"messages"`
+		NextCursor string                   `json:"next_cursor"`
+		Result     *apiError                `json:"result"`
+	}
+}
+
+// swagger:parameters cancelMessageRequest
+type cancelMessageRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		// required: true
+		// example: 507f1f77bcf86cd799439011
+		ID string `json:"id"`
+	}
+}
+
+// Success
+// swagger:response cancelMessageResponse
+type cancelMessageResponse struct {
+	Body struct {
+		Message *sender.MessageTransaction `json:"message"`
+		Result  *apiError                  `json:"result"`
+	}
+}
+
+// swagger:parameters cancelMessagesRequest
+type cancelMessagesRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		IDs         []string   `json:"ids"`
+		Recipient   string     `json:"recipient"`
+		CreatedFrom *time.Time `json:"created_from"`
+		CreatedTo   *time.Time `json:"created_to"`
+	}
+}
+
+// Success
+// swagger:response cancelMessagesResponse
+type cancelMessagesResponse struct {
+	Body struct {
+		CancelledCount int64 `json:"cancelled_count"`
+		// messages matching filter which were claimed by a worker
+		SkippedCount int64     `json:"skipped_count"`
+		SkippedIDs   []string  `json:"skipped_ids"`
+		Result       *apiError `json:"result"`
+	}
+}
+
+// swagger:parameters requeueMessagesRequest
+type requeueMessagesRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		IDs []string `json:"ids"`
+		// failed and invalid when empty
+		Status      []string   `json:"status"`
+		Recipient   string     `json:"recipient"`
+		CreatedFrom *time.Time `json:"created_from"`
+		CreatedTo   *time.Time `json:"created_to"`
+	}
+}
+
+// Success
+// swagger:response requeueMessagesResponse
+type requeueMessagesResponse struct {
+	Body struct {
+		RequeuedCount int64     `json:"requeued_count"`
+		Result        *apiError `json:"result"`
+	}
+}
+
+// swagger:parameters createMessageRequest
+type createMessageRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		// required: true
+		// maximum: 1000
+		Content string `json:"content"`
+		// required: true
+		// example: +905551234567
+		Recipient string `json:"recipient"`
+		// message is not sent before send_at when given
+		SendAt *time.Time `json:"send_at"`
+		// minimum: 0
+		// maximum: 10
+		Priority int      `json:"priority"`
+		Tags     []string `json:"tags"`
+	}
+}
+
+// Success
+// swagger:response createMessageResponse
+type createMessageResponse struct {
+	Body struct {
+		Message *sender.MessageTransaction `json:"message"`
+		Result  *apiError                  `json:"result"`
+	}
+}
+
+// swagger:parameters updateMessageRequest
+type updateMessageRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		// required: true
+		// example: 507f1f77bcf86cd799439011
+		ID string `json:"id"`
+		// expected current version of the message
+		Version *int64 `json:"version"`
+		// maximum: 1000
+		Content   *string    `json:"content"`
+		Recipient *string    `json:"recipient"`
+		SendAt    *time.Time `json:"send_at"`
+		// clears send_at so that the message is sent by the next batch, can not be given with send_at
+		SendNow bool `json:"send_now"`
+		// minimum: 0
+		// maximum: 10
+		Priority *int `json:"priority"`
+	}
+}
+
+// Success
+// swagger:response updateMessageResponse
+type updateMessageResponse struct {
+	Body struct {
+		Message *sender.MessageTransaction `json:"message"`
+		Result  *apiError                  `json:"result"`
+	}
+}
+
+// swagger:parameters getMessageRequest
+type getMessageRequest struct {
+	requestHeader
+	// in: path
+	// required: true
+	// example: 507f1f77bcf86cd799439011
+	ID string `json:"id"`
+}
+
+// Success
+// swagger:response getMessageResponse
+type getMessageResponse struct {
+	Body struct {
+		Message *sender.MessageTransaction `json:"message"`
+		Result  *apiError                  `json:"result"`
+	}
+}
+
+// swagger:parameters getStatsRequest
+type getStatsRequest struct {
+	requestHeader
+	// length of the throughput, failure rate and latency window
+	// in: query
+	// minimum: 1
+	// maximum: 1440
+	// default: 15
+	WindowMinutes int `json:"window_minutes"`
+}
+
+// Success
+// swagger:response getStatsResponse
+type getStatsResponse struct {
+	Body struct {
+		Stats  *sender.MessageStats `json:"stats"`
+		Result *apiError            `json:"result"`
+	}
+}
+
+// swagger:parameters exportMessagesRequest
+type exportMessagesRequest struct {
+	requestHeader
+	// text/csv selects CSV when format is not given
+	// in: header
+	// example: text/csv
+	Accept string `json:"Accept"`
+	// in: query
+	// enum: ["csv", "ndjson"]
+	Format string `json:"format"`
+	// in: query
+	Recipient string `json:"recipient"`
+	// in: query
+	// default: sent
+	Status []string `json:"status"`
+	// only messages having all of the tags
+	// in: query
+	Tags []string `json:"tag"`
+	// in: query
+	CreatedFrom *time.Time `json:"created_from"`
+	// in: query
+	CreatedTo *time.Time `json:"created_to"`
+	// in: query
+	SentFrom *time.Time `json:"sent_from"`
+	// in: query
+	SentTo *time.Time `json:"sent_to"`
+}
+
+// Success, one message per line as CSV rows with a header row or as JSON objects
+// swagger:response exportMessagesResponse
+type exportMessagesResponse struct {
+	// in: body
+	Body sender.ResponseMessage
+}
+
+// swagger:parameters streamMessageEventsRequest
+type streamMessageEventsRequest struct {
+	requestHeader
+	// matched against masked recipients of events
+	// in: query
+	Recipient string `json:"recipient"`
+	// only messages tagged with the campaign
+	// in: query
+	Campaign string `json:"campaign"`
+	// in: query
+	Status []string `json:"status"`
+}
+
+// Success, each event is sent as "event: <status>" with the message event as data
+// swagger:response streamMessageEventsResponse
+type streamMessageEventsResponse struct {
+	// in: body
+	Body sender.MessageEvent
+}
+
+// swagger:parameters createAPIKeyRequest
+type createAPIKeyRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		// required: true
+		// maximum length: 100
+		// example: reporting
+		Name string `json:"name"`
+		// role whose scopes are granted, required unless scopes are given
+		// enum: ingestion,support,sre,admin
+		// example: support
+		Role string `json:"role"`
+		// scopes granted in addition to scopes of role, pii:read shows recipients and contents unmasked
+		// example: ["messages:write"]
+		Scopes []string `json:"scopes"`
+	}
+}
+
+// Success, key is returned only in this response
+// swagger:response createAPIKeyResponse
+type createAPIKeyResponse struct {
+	Body struct {
+		APIKey *sender.APIKey `json:"api_key"`
+		Key    string         `json:"key"`
+		Result *apiError      `json:"result"`
+	}
+}
+
+// swagger:parameters listAPIKeysRequest
+type listAPIKeysRequest struct {
+	requestHeader
+}
+
+// Success
+// swagger:response listAPIKeysResponse
+type listAPIKeysResponse struct {
+	Body struct {
+		APIKeys []sender.APIKey `json:"api_keys"`
+		Result  *apiError       `json:"result"`
+	}
+}
+
+// swagger:parameters revokeAPIKeyRequest
+type revokeAPIKeyRequest struct {
+	requestHeader
+	// in: body
+	Body struct {
+		// required: true
+		// example: 507f1f77bcf86cd799439011
+		ID string `json:"id"`
+	}
+}
+
+// Success
+// swagger:response revokeAPIKeyResponse
+type revokeAPIKeyResponse struct {
+	Body struct {
+		APIKey *sender.APIKey `json:"api_key"`
+		Result *apiError      `json:"result"`
+	}
+}
+
+// swagger:parameters deliveryReceiptRequest
+type deliveryReceiptRequest struct {
+	requestHeader
+	// in: header
+	// name: X-Signature-Key-Id
+	// required: true
+	// example: provider-2024
+	SignatureKeyID string `json:"X-Signature-Key-Id"`
+	// unix seconds the request is signed at
+	// in: header
+	// name: X-Signature-Timestamp
+	// required: true
+	// example: 1733011200
+	SignatureTimestamp string `json:"X-Signature-Timestamp"`
+	// random value unique to the request
+	// in: header
+	// name: X-Signature-Nonce
+	// required: true
+	// example: 6f1c0b8e2d4a4f7c9b3e5a1d2c8f0e47
+	SignatureNonce string `json:"X-Signature-Nonce"`
+	// hmac-sha256 of timestamp, nonce, method, path and body
+	// in: header
+	// name: X-Signature
+	// required: true
+	// example: v1=5d41402abc4b2a76b9719d911017c592
+	Signature string `json:"X-Signature"`
+	// in: body
+	Body struct {
+		// provider message id returned on send
+		// required: true
+		// example: 67f2f8a8-ea58-4ed0-a6f9-ff217df4d849
+		MessageID string `json:"messageId"`
+		// required: true
+		// enum: delivered
+		Status string `json:"status"`
+		// defaults to the time the receipt is received
+		// example: 2024-12-01T00:00:05Z
+		DeliveredAt *time.Time `json:"deliveredAt"`
+	}
+}
+
+// Success
+// swagger:response deliveryReceiptResponse
+type deliveryReceiptResponse struct {
+	Body struct {
+		Result *apiError `json:"result"`
+	}
+}
+
+// swagger:parameters getSandboxMessagesRequest
+type getSandboxMessagesRequest struct {
+	requestHeader
+	// in: query
+	Recipient string `json:"recipient"`
+	// in: query
+	// minimum: 1
+	// maximum: 100
+	// default: 20
+	Limit int64 `json:"limit"`
+}
+
+// Success
+// swagger:response getSandboxMessagesResponse
+type getSandboxMessagesResponse struct {
+	Body struct {
+		Messages []sender.SandboxMessage `json:"messages"`
+		Result   *apiError               `json:"result"`
 	}
 }
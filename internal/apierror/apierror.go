@@ -2,6 +2,7 @@ package apierror
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/mkaykisiz/sender/internal/localization"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -9,18 +10,28 @@ import (
 
 // error codes
 const (
-	CodeInternalServerError = 1
-	CodeValidationError     = 2
-	CodeBadRequestError     = 3
-	CodeUnauthorizedError   = 4
+	CodeInternalServerError     = 1
+	CodeValidationError         = 2
+	CodeBadRequestError         = 3
+	CodeUnauthorizedError       = 4
+	CodeConflictError           = 5
+	CodeNotFoundError           = 6
+	CodeForbiddenError          = 7
+	CodeTooManyRequestsError    = 8
+	CodeServiceUnavailableError = 9
 )
 
 // error names
 const (
-	NameInternalServerError = "InternalServerError"
-	NameValidationError     = "ValidationError"
-	NameUnauthorizedError   = "UnauthorizedError"
-	NameBadRequestError     = "BadRequestError"
+	NameInternalServerError     = "InternalServerError"
+	NameValidationError         = "ValidationError"
+	NameUnauthorizedError       = "UnauthorizedError"
+	NameBadRequestError         = "BadRequestError"
+	NameConflictError           = "ConflictError"
+	NameNotFoundError           = "NotFoundError"
+	NameForbiddenError          = "ForbiddenError"
+	NameTooManyRequestsError    = "TooManyRequestsError"
+	NameServiceUnavailableError = "ServiceUnavailableError"
 )
 
 // error actions
@@ -43,6 +54,9 @@ type APIError struct {
 	ErrorAction int    `json:"errorAction,omitempty"`
 	BaseError   error  `json:"-"`
 
+	// RetryAfter is the time to wait before retrying, it is sent as Retry-After header
+	RetryAfter time.Duration `json:"-"`
+
 	MessageLocalizerKey string `json:"-"`
 }
 
@@ -112,6 +126,84 @@ func NewBadRequestError(message string, messageLocalizerKey string) *APIError {
 	}
 }
 
+// NewConflictError returns conflict error
+func NewConflictError(message string, messageLocalizerKey string) *APIError {
+	return &APIError{
+		Message:             message,
+		Name:                NameConflictError,
+		Code:                CodeConflictError,
+		StatusCode:          http.StatusConflict,
+		MessageLocalizerKey: messageLocalizerKey,
+	}
+}
+
+// NewNotFoundError returns not found error
+func NewNotFoundError(message string, messageLocalizerKey string) *APIError {
+	return &APIError{
+		Message:             message,
+		Name:                NameNotFoundError,
+		Code:                CodeNotFoundError,
+		StatusCode:          http.StatusNotFound,
+		MessageLocalizerKey: messageLocalizerKey,
+	}
+}
+
+// NewUnauthorizedError returns copy of default unauthorized error wrapping base error
+func NewUnauthorizedError(baseError error) *APIError {
+	apiErr := *DefaultUnauthorizedError
+	apiErr.Message = baseError.Error()
+	apiErr.BaseError = baseError
+	return &apiErr
+}
+
+// NewForbiddenError returns forbidden error
+func NewForbiddenError(message string, messageLocalizerKey string) *APIError {
+	return &APIError{
+		Message:             message,
+		Name:                NameForbiddenError,
+		Code:                CodeForbiddenError,
+		StatusCode:          http.StatusForbidden,
+		MessageLocalizerKey: messageLocalizerKey,
+	}
+}
+
+// NewTooManyRequestsError returns too many requests error of a client allowed to retry after retryAfter
+func NewTooManyRequestsError(baseError error, retryAfter time.Duration) *APIError {
+	return &APIError{
+		Message:             baseError.Error(),
+		Name:                NameTooManyRequestsError,
+		Code:                CodeTooManyRequestsError,
+		StatusCode:          http.StatusTooManyRequests,
+		BaseError:           baseError,
+		RetryAfter:          retryAfter,
+		MessageLocalizerKey: "too-many-requests-error-message",
+	}
+}
+
+// NewServiceUnavailableError returns service unavailable error wrapping base error
+func NewServiceUnavailableError(baseError error) *APIError {
+	return &APIError{
+		Message:             baseError.Error(),
+		Name:                NameServiceUnavailableError,
+		Code:                CodeServiceUnavailableError,
+		StatusCode:          http.StatusServiceUnavailable,
+		BaseError:           baseError,
+		MessageLocalizerKey: "service-unavailable-error-message",
+	}
+}
+
+// NewInternalServerError returns internal server error wrapping base error
+func NewInternalServerError(baseError error) *APIError {
+	return &APIError{
+		Message:             baseError.Error(),
+		Name:                NameInternalServerError,
+		Code:                CodeInternalServerError,
+		StatusCode:          http.StatusInternalServerError,
+		BaseError:           baseError,
+		MessageLocalizerKey: DefaultInternalServerError.MessageLocalizerKey,
+	}
+}
+
 // Error returns api error's error message
 func (apiErr *APIError) Error() string {
 	return apiErr.Message
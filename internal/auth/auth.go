@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+)
+
+// api key format
+const (
+	keyPrefix        = "snd_"
+	keyRandomBytes   = 32
+	keyDisplayLength = 12
+)
+
+// Identity represents the api key a request is authenticated with
+type Identity struct {
+	KeyID  string
+	Name   string
+	Scopes []string
+}
+
+// HasScope reports whether identity is granted scope
+func (id Identity) HasScope(scope string) bool {
+	for _, s := range id.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScopes reports whether identity is granted every scope
+func (id Identity) HasScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !id.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+var identityKey = struct{ Key string }{"identity"}
+
+var apiKeyKey = struct{ Key string }{"apiKey"}
+
+// NewContext returns context carrying identity
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
+
+// FromContext returns identity of the authenticated request, ok is false when the request is not authenticated
+func FromContext(ctx context.Context) (id Identity, ok bool) {
+	id, ok = ctx.Value(identityKey).(Identity)
+	return id, ok
+}
+
+// GenerateKey returns a new random api key
+func GenerateKey() (string, error) {
+	b := make([]byte, keyRandomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating api key failed, %s", err.Error())
+	}
+
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashKey returns hex encoded sha256 hash of api key. Keys are long random strings, so an unsalted
+// hash is enough and lets keys be looked up by their hash.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey returns api key record of key granted scopes, only its hash and first characters are kept
+func NewAPIKey(key string, name string, scopes []string, createdBy string) sender.APIKey {
+	prefix := key
+	if len(prefix) > keyDisplayLength {
+		prefix = prefix[:keyDisplayLength]
+	}
+
+	return sender.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      HashKey(key),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+}
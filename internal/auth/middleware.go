@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	mongostore "github.com/mkaykisiz/sender/internal/store/mongo"
+	"google.golang.org/grpc/metadata"
+)
+
+// APIKeyHeader is the header api key is sent with, a bearer authorization header is accepted as well
+const APIKeyHeader = "X-API-Key"
+
+const bearerScheme = "bearer "
+
+// authentication errors
+var (
+	ErrMissingAPIKey = errors.New("api key is missing")
+	ErrInvalidAPIKey = errors.New("api key is invalid")
+	ErrRevokedAPIKey = errors.New("api key is revoked")
+	ErrMissingScope  = errors.New("api key is not granted scope")
+)
+
+// Middleware returns endpoint middleware authenticating requests and authorizing them for scope
+type Middleware func(scope string) endpoint.Middleware
+
+// KeyStore defines behaviors of api key store used by authentication middleware
+type KeyStore interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (sender.APIKey, error)
+}
+
+// NewMiddleware returns middleware authenticating requests by the api key put into context by HTTPToContext
+// or GRPCToContext. Keys which are not granted the scope of the endpoint are rejected with forbidden error.
+// Identity of the key is added to context passed to next endpoint.
+func NewMiddleware(ks KeyStore) Middleware {
+	return func(scope string) endpoint.Middleware {
+		return func(next endpoint.Endpoint) endpoint.Endpoint {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				key := KeyFromContext(ctx)
+				if key == "" {
+					return nil, apierror.NewUnauthorizedError(ErrMissingAPIKey)
+				}
+
+				k, err := ks.GetAPIKeyByHash(ctx, HashKey(key))
+				if errors.Is(err, mongostore.ErrAPIKeyNotFound) {
+					return nil, apierror.NewUnauthorizedError(ErrInvalidAPIKey)
+				}
+				if err != nil {
+					return nil, apierror.NewInternalServerError(err)
+				}
+
+				if k.IsRevoked() {
+					return nil, apierror.NewUnauthorizedError(ErrRevokedAPIKey)
+				}
+
+				id := Identity{KeyID: k.ID.Hex(), Name: k.Name, Scopes: k.Scopes}
+				if !id.HasScope(scope) {
+					return nil, newForbiddenError(fmt.Errorf("%w, key: %s, scope: %s", ErrMissingScope, id.Name, scope))
+				}
+
+				return next(NewContext(ctx, id), request)
+			}
+		}
+	}
+}
+
+// NopMiddleware returns endpoint middleware passing requests as they are, it is used when authentication is disabled
+func NopMiddleware(_ string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return next
+	}
+}
+
+// HTTPToContext adds api key of http request to context
+func HTTPToContext(ctx context.Context, r *http.Request) context.Context {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = bearerToken(r.Header.Get("Authorization"))
+	}
+
+	return context.WithValue(ctx, apiKeyKey, key)
+}
+
+// KeyFromContext returns api key put into context by HTTPToContext or GRPCToContext, it is empty when the
+// request has none. The key is not authenticated yet.
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyKey).(string)
+	return key
+}
+
+// GRPCToContext adds api key of grpc request metadata to context
+func GRPCToContext(ctx context.Context, md metadata.MD) context.Context {
+	var key string
+	if values := md.Get(strings.ToLower(APIKeyHeader)); len(values) > 0 {
+		key = values[0]
+	} else if values := md.Get("authorization"); len(values) > 0 {
+		key = bearerToken(values[0])
+	}
+
+	return context.WithValue(ctx, apiKeyKey, key)
+}
+
+func bearerToken(authorization string) string {
+	if len(authorization) <= len(bearerScheme) || !strings.EqualFold(authorization[:len(bearerScheme)], bearerScheme) {
+		return ""
+	}
+
+	return strings.TrimSpace(authorization[len(bearerScheme):])
+}
+
+// newForbiddenError returns forbidden error wrapping err
+func newForbiddenError(err error) *apierror.APIError {
+	apiErr := apierror.NewForbiddenError(err.Error(), "missing-scope-forbidden-error-message")
+	apiErr.BaseError = err
+	return apiErr
+}
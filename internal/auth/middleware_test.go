@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	mockmongostore "github.com/mkaykisiz/sender/internal/mock/store/mongo"
+	mongostore "github.com/mkaykisiz/sender/internal/store/mongo"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNewMiddleware(t *testing.T) {
+	var got Identity
+	next := func(ctx context.Context, request interface{}) (interface{}, error) {
+		got, _ = FromContext(ctx)
+		return request, nil
+	}
+
+	newRequestContext := func(header string, value string) context.Context {
+		r := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return HTTPToContext(context.Background(), r)
+	}
+
+	t.Run("valid key", func(t *testing.T) {
+		got = Identity{}
+		ms := mockmongostore.NewStore()
+		k := NewAPIKey("snd_valid", "reporting", []string{sender.ScopeMessagesRead}, "")
+		k.ID = primitive.NewObjectID()
+		ctx := newRequestContext(APIKeyHeader, "snd_valid")
+
+		ms.On("GetAPIKeyByHash", ctx, HashKey("snd_valid")).Return(k, nil).Once()
+
+		res, err := NewMiddleware(ms)(sender.ScopeMessagesRead)(next)(ctx, "request")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "request", res)
+		assert.Equal(t, Identity{KeyID: k.ID.Hex(), Name: "reporting", Scopes: []string{sender.ScopeMessagesRead}}, got)
+		ms.AssertExpectations(t)
+	})
+
+	t.Run("bearer key", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ctx := newRequestContext("Authorization", "Bearer snd_valid")
+
+		ms.On("GetAPIKeyByHash", ctx, HashKey("snd_valid")).Return(NewAPIKey("snd_valid", "reporting", []string{sender.ScopeMessagesRead}, ""), nil).Once()
+
+		_, err := NewMiddleware(ms)(sender.ScopeMessagesRead)(next)(ctx, "request")
+
+		assert.NoError(t, err)
+		ms.AssertExpectations(t)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+
+		_, err := NewMiddleware(ms)(sender.ScopeMessagesRead)(next)(newRequestContext("Authorization", "Basic dXNlcjpwYXNz"), "request")
+
+		assertUnauthorized(t, err, ErrMissingAPIKey)
+		ms.AssertNotCalled(t, "GetAPIKeyByHash")
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ctx := newRequestContext(APIKeyHeader, "snd_unknown")
+
+		ms.On("GetAPIKeyByHash", ctx, HashKey("snd_unknown")).Return(sender.APIKey{}, mongostore.ErrAPIKeyNotFound).Once()
+
+		_, err := NewMiddleware(ms)(sender.ScopeMessagesRead)(next)(ctx, "request")
+
+		assertUnauthorized(t, err, ErrInvalidAPIKey)
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ctx := newRequestContext(APIKeyHeader, "snd_revoked")
+		k := NewAPIKey("snd_revoked", "reporting", []string{sender.ScopeMessagesRead}, "")
+		revokedAt := time.Now()
+		k.RevokedAt = &revokedAt
+
+		ms.On("GetAPIKeyByHash", ctx, HashKey("snd_revoked")).Return(k, nil).Once()
+
+		_, err := NewMiddleware(ms)(sender.ScopeMessagesRead)(next)(ctx, "request")
+
+		assertUnauthorized(t, err, ErrRevokedAPIKey)
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ctx := newRequestContext(APIKeyHeader, "snd_ingestion")
+
+		ms.On("GetAPIKeyByHash", ctx, HashKey("snd_ingestion")).Return(NewAPIKey("snd_ingestion", "ingestion", sender.RoleScopes[sender.RoleIngestion], ""), nil).Once()
+
+		_, err := NewMiddleware(ms)(sender.ScopeWorkerControl)(next)(ctx, "request")
+
+		apiErr, ok := err.(*apierror.APIError)
+		assert.True(t, ok)
+		assert.Equal(t, apierror.CodeForbiddenError, apiErr.Code)
+		assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+		assert.ErrorIs(t, apiErr.BaseError, ErrMissingScope)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ctx := newRequestContext(APIKeyHeader, "snd_valid")
+
+		ms.On("GetAPIKeyByHash", ctx, HashKey("snd_valid")).Return(sender.APIKey{}, errors.New("db error")).Once()
+
+		_, err := NewMiddleware(ms)(sender.ScopeMessagesRead)(next)(ctx, "request")
+
+		apiErr, ok := err.(*apierror.APIError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	})
+}
+
+func TestGRPCToContext(t *testing.T) {
+	ctx := GRPCToContext(context.Background(), metadata.Pairs("authorization", "bearer snd_grpc"))
+	assert.Equal(t, "snd_grpc", ctx.Value(apiKeyKey))
+
+	ctx = GRPCToContext(context.Background(), metadata.Pairs("x-api-key", "snd_header"))
+	assert.Equal(t, "snd_header", ctx.Value(apiKeyKey))
+}
+
+func assertUnauthorized(t *testing.T, err error, baseError error) {
+	apiErr, ok := err.(*apierror.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, apierror.CodeUnauthorizedError, apiErr.Code)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, baseError, apiErr.BaseError)
+	// shared default error must stay untouched
+	assert.Nil(t, apierror.DefaultUnauthorizedError.BaseError)
+}
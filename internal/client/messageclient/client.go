@@ -6,9 +6,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/mkaykisiz/sender/internal/requestid"
+	"github.com/mkaykisiz/sender/internal/signing"
+	"github.com/mkaykisiz/sender/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Provider is the name of webhook provider
+const Provider = "webhook"
+
 type MessageRequest struct {
 	To      string `json:"to"`
 	Content string `json:"content"`
@@ -19,9 +33,19 @@ type MessageResponse struct {
 	MessageID string `json:"messageId"`
 }
 
+// statusError labels provider requests which fail without a response
+const statusError = "error"
+
+// ClientMetrics holds instruments of provider requests, they are labeled by provider and http status
+type ClientMetrics struct {
+	Requests metrics.Counter
+	Latency  metrics.Histogram
+}
+
 // MessageClient defines behaviors of message client
 type MessageClient interface {
 	SendMessage(ctx context.Context, to, content string) (*MessageResponse, error)
+	Name() string
 }
 
 type messageClient struct {
@@ -29,24 +53,43 @@ type messageClient struct {
 	authKey    string
 	maxRetries int
 	retryDelay time.Duration
+	signer     *signing.Signer
 	c          *http.Client
+	metrics    *ClientMetrics
 }
 
-// NewClient creates and returns client
-func NewClient(url, authKey string, maxRetries int, retryDelay time.Duration) *messageClient {
+// NewClient creates and returns client, requests are signed with signer unless it is nil
+func NewClient(url, authKey string, maxRetries int, retryDelay time.Duration, signer *signing.Signer) *messageClient {
 	cli := &messageClient{
 		url:        url,
 		authKey:    authKey,
 		maxRetries: maxRetries,
 		retryDelay: retryDelay,
+		signer:     signer,
 		c:          http.DefaultClient,
 	}
 
 	return cli
 }
 
-// SendMessage returns sent message response
-func (c *messageClient) SendMessage(ctx context.Context, to, content string) (*MessageResponse, error) {
+// SetMetrics makes client report its provider requests to m
+func (c *messageClient) SetMetrics(m *ClientMetrics) {
+	c.metrics = m
+}
+
+// Name returns provider name
+func (c *messageClient) Name() string {
+	return Provider
+}
+
+// SendMessage returns sent message response, request id and trace context of ctx are propagated to provider
+func (c *messageClient) SendMessage(ctx context.Context, to, content string) (_ *MessageResponse, err error) {
+	ctx, span := tracing.Start(ctx, "messageclient.SendMessage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("provider", Provider)),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	hookRes := MessageResponse{}
 
 	payload := MessageRequest{
@@ -65,11 +108,23 @@ func (c *messageClient) SendMessage(ctx context.Context, to, content string) (*M
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-ins-auth-key", c.authKey)
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if c.signer != nil {
+		if err := c.signer.SignRequest(req, jsonData); err != nil {
+			return nil, err
+		}
+	}
 
+	begin := time.Now()
 	res, err := c.c.Do(req)
+	c.observe(begin, res)
 	if err != nil {
 		return nil, fmt.Errorf("getting support url config failed while doing http request, %s", err.Error())
 	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
 	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
 		return nil, fmt.Errorf("getting support url config failed while doing http request, %s", res.Status)
 	}
@@ -81,3 +136,19 @@ func (c *messageClient) SendMessage(ctx context.Context, to, content string) (*M
 
 	return &hookRes, nil
 }
+
+// observe counts provider request started at begin by status of res and observes its latency
+func (c *messageClient) observe(begin time.Time, res *http.Response) {
+	if c.metrics == nil {
+		return
+	}
+
+	status := statusError
+	if res != nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+
+	lvs := []string{"provider", Provider, "status", status}
+	c.metrics.Requests.With(lvs...).Add(1)
+	c.metrics.Latency.With(lvs...).Observe(time.Since(begin).Seconds())
+}
@@ -3,11 +3,20 @@ package messageclient
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/mkaykisiz/sender/internal/requestid"
+	"github.com/mkaykisiz/sender/internal/signing"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"github.com/stretchr/testify/assert"
 )
 var ClientHost = "http://localhost:8080"
@@ -17,7 +26,7 @@ var Message = "Test message"
 
 func TestNewClient(t *testing.T) {
 	t.Run("with default http client", func(t *testing.T) {
-		client := NewClient(ClientHost, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(ClientHost, ClientAuthKey, 3, 1*time.Second, nil)
 
 		assert.NotNil(t, client)
 		assert.Equal(t, ClientHost, client.url)
@@ -56,7 +65,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		response, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -79,7 +88,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		response, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -95,7 +104,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		response, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -111,7 +120,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		response, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -128,7 +137,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		response, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -139,7 +148,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 	})
 
 	t.Run("failed send with network error", func(t *testing.T) {
-		client := NewClient("http://invalid-url-that-does-not-exist.local", ClientAuthKey, 3, 1*time.Second)
+		client := NewClient("http://invalid-url-that-does-not-exist.local", ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		response, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -156,7 +165,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
 		defer cancel()
 
@@ -176,7 +185,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		_, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -195,7 +204,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		_, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -214,7 +223,7 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		}))
 		defer server.Close()
 
-		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second)
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
 		ctx := context.Background()
 
 		_, err := client.SendMessage(ctx, PhoneNumber, Message)
@@ -223,4 +232,100 @@ func TestMessageClient_SendMessage(t *testing.T) {
 		assert.Equal(t, PhoneNumber, receivedRequest.To)
 		assert.Equal(t, Message, receivedRequest.Content)
 	})
+	t.Run("signed request", func(t *testing.T) {
+		verifier := signing.NewVerifier(signing.Keys{"2024-01": "secret"}, time.Minute, nonceStore{})
+		var verifyErr, replayErr error
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			verifyErr = verifier.Verify(r.Context(), r, body)
+			replayErr = verifier.Verify(r.Context(), r, body)
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(MessageResponse{MessageID: "msg-888"})
+		}))
+		defer server.Close()
+
+		client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, signing.NewSigner("2024-01", "secret"))
+
+		_, err := client.SendMessage(context.Background(), PhoneNumber, Message)
+
+		assert.NoError(t, err)
+		assert.NoError(t, verifyErr)
+		assert.ErrorIs(t, replayErr, signing.ErrReplayedRequest)
+	})
+}
+
+func TestMessageClient_TracePropagation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(MessageResponse{MessageID: "msg-666"})
+	}))
+	defer server.Close()
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "Worker.send")
+	defer span.End()
+
+	_, err := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil).SendMessage(ctx, PhoneNumber, Message)
+
+	assert.NoError(t, err)
+	assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+}
+
+func TestMessageClient_RequestID(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(requestid.Header)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(MessageResponse{MessageID: "msg-555"})
+	}))
+	defer server.Close()
+
+	ctx := requestid.NewContext(context.Background(), "req-123")
+
+	_, err := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil).SendMessage(ctx, PhoneNumber, Message)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "req-123", got)
+}
+
+func TestMessageClient_Metrics(t *testing.T) {
+	statuses := []int{http.StatusOK, http.StatusInternalServerError}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[0])
+		statuses = statuses[1:]
+		json.NewEncoder(w).Encode(MessageResponse{MessageID: "msg-777"})
+	}))
+	defer server.Close()
+
+	requests := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{Name: "requests_total"}, []string{"provider", "status"})
+	latency := stdprometheus.NewHistogramVec(stdprometheus.HistogramOpts{Name: "request_duration_seconds"}, []string{"provider", "status"})
+
+	client := NewClient(server.URL, ClientAuthKey, 3, 1*time.Second, nil)
+	client.SetMetrics(&ClientMetrics{Requests: kitprometheus.NewCounter(requests), Latency: kitprometheus.NewHistogram(latency)})
+
+	_, err := client.SendMessage(context.Background(), PhoneNumber, Message)
+	assert.NoError(t, err)
+	_, err = client.SendMessage(context.Background(), PhoneNumber, Message)
+	assert.Error(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues(Provider, "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues(Provider, "500")))
+	assert.Equal(t, 2, testutil.CollectAndCount(latency))
+}
+
+// nonceStore represents in memory nonce store
+type nonceStore map[string]bool
+
+// ClaimNonce claims nonce unless it is claimed already
+func (s nonceStore) ClaimNonce(_ context.Context, keyID, nonce string, _ time.Duration) (bool, error) {
+	if s[keyID+nonce] {
+		return false, nil
+	}
+	s[keyID+nonce] = true
+	return true, nil
 }
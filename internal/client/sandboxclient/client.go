@@ -0,0 +1,112 @@
+package sandboxclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/client/messageclient"
+	"github.com/mkaykisiz/sender/internal/requestid"
+	"github.com/mkaykisiz/sender/internal/tracing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Provider is the name of sandbox provider
+const Provider = "sandbox"
+
+// providerMessageIDPrefix tells fake provider message ids apart from ids of real providers
+const providerMessageIDPrefix = "sandbox-"
+
+// ErrSimulatedFailure is returned when sandbox fails a send on purpose
+var ErrSimulatedFailure = errors.New("sandbox simulated failure")
+
+// compile-time proof of interface implementation
+var _ messageclient.MessageClient = (*sandboxClient)(nil)
+
+// Recorder defines behaviors of sandbox message store
+type Recorder interface {
+	InsertSandboxMessage(ctx context.Context, m sender.SandboxMessage) (sender.SandboxMessage, error)
+}
+
+type sandboxClient struct {
+	r              Recorder
+	failureRate    float64
+	failRecipients map[string]struct{}
+	random         func() float64
+}
+
+// NewClient creates and returns client recording messages with r instead of delivering them. Sends to
+// comma separated failRecipients always fail, other sends fail with probability of failureRate.
+func NewClient(r Recorder, failureRate float64, failRecipients string) (*sandboxClient, error) {
+	if failureRate < 0 || failureRate > 1 {
+		return nil, fmt.Errorf("sandbox failure rate %v is not between 0 and 1", failureRate)
+	}
+
+	c := &sandboxClient{
+		r:              r,
+		failureRate:    failureRate,
+		failRecipients: make(map[string]struct{}),
+		random:         rand.Float64,
+	}
+	for _, recipient := range strings.Split(failRecipients, ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			c.failRecipients[recipient] = struct{}{}
+		}
+	}
+
+	return c, nil
+}
+
+// Name returns provider name
+func (c *sandboxClient) Name() string {
+	return Provider
+}
+
+// SendMessage records message and returns a fake provider message id, a simulated failure is recorded
+// as well so that failed sends can be inspected
+func (c *sandboxClient) SendMessage(ctx context.Context, to, content string) (_ *messageclient.MessageResponse, err error) {
+	ctx, span := tracing.Start(ctx, "sandboxclient.SendMessage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("provider", Provider)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	m := sender.SandboxMessage{
+		ID:        primitive.NewObjectID(),
+		Recipient: to,
+		Content:   content,
+		Status:    sender.SandboxStatusAccepted,
+		RequestID: requestid.FromContext(ctx),
+		CreatedAt: time.Now(),
+	}
+	if c.fails(to) {
+		m.Status = sender.SandboxStatusFailed
+		m.Error = ErrSimulatedFailure.Error()
+	} else {
+		m.ProviderMessageID = providerMessageIDPrefix + m.ID.Hex()
+	}
+
+	if _, err := c.r.InsertSandboxMessage(ctx, m); err != nil {
+		return nil, fmt.Errorf("recording sandbox message failed, %s", err.Error())
+	}
+	if m.Status == sender.SandboxStatusFailed {
+		return nil, ErrSimulatedFailure
+	}
+
+	return &messageclient.MessageResponse{Message: "Accepted", MessageID: m.ProviderMessageID}, nil
+}
+
+// fails reports whether send to recipient should fail
+func (c *sandboxClient) fails(recipient string) bool {
+	if _, ok := c.failRecipients[recipient]; ok {
+		return true
+	}
+
+	return c.failureRate > 0 && c.random() < c.failureRate
+}
@@ -0,0 +1,91 @@
+package sandboxclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mkaykisiz/sender"
+	mockmongostore "github.com/mkaykisiz/sender/internal/mock/store/mongo"
+	"github.com/mkaykisiz/sender/internal/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var PhoneNumber = "+905551234567"
+var Message = "Test message"
+
+func TestNewClient(t *testing.T) {
+	t.Run("parses fail recipients", func(t *testing.T) {
+		c, err := NewClient(mockmongostore.NewStore(), 0.5, " +905550000001, ,+905550000002")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0.5, c.failureRate)
+		assert.Len(t, c.failRecipients, 2)
+		assert.Contains(t, c.failRecipients, "+905550000001")
+		assert.Contains(t, c.failRecipients, "+905550000002")
+	})
+
+	t.Run("rejects failure rate out of range", func(t *testing.T) {
+		_, err := NewClient(mockmongostore.NewStore(), 1.5, "")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestSandboxClient_SendMessage(t *testing.T) {
+	t.Run("records accepted message with fake provider id", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ms.On("InsertSandboxMessage", mock.Anything, mock.MatchedBy(func(m sender.SandboxMessage) bool {
+			return m.Status == sender.SandboxStatusAccepted && m.Recipient == PhoneNumber && m.Content == Message &&
+				m.RequestID == "req-1" && m.ProviderMessageID == providerMessageIDPrefix+m.ID.Hex()
+		})).Return(sender.SandboxMessage{}, nil).Once()
+
+		c, _ := NewClient(ms, 0, "")
+		res, err := c.SendMessage(requestid.NewContext(context.Background(), "req-1"), PhoneNumber, Message)
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(res.MessageID, providerMessageIDPrefix))
+		ms.AssertExpectations(t)
+	})
+
+	t.Run("fails sends to fail recipients", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ms.On("InsertSandboxMessage", mock.Anything, mock.MatchedBy(func(m sender.SandboxMessage) bool {
+			return m.Status == sender.SandboxStatusFailed && m.ProviderMessageID == "" && m.Error == ErrSimulatedFailure.Error()
+		})).Return(sender.SandboxMessage{}, nil).Once()
+
+		c, _ := NewClient(ms, 0, PhoneNumber)
+		res, err := c.SendMessage(context.Background(), PhoneNumber, Message)
+
+		assert.ErrorIs(t, err, ErrSimulatedFailure)
+		assert.Nil(t, res)
+		ms.AssertExpectations(t)
+	})
+
+	t.Run("fails sends by failure rate", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ms.On("InsertSandboxMessage", mock.Anything, mock.Anything).Return(sender.SandboxMessage{}, nil)
+
+		c, _ := NewClient(ms, 0.3, "")
+		c.random = func() float64 { return 0.2 }
+		_, err := c.SendMessage(context.Background(), PhoneNumber, Message)
+		assert.ErrorIs(t, err, ErrSimulatedFailure)
+
+		c.random = func() float64 { return 0.3 }
+		_, err = c.SendMessage(context.Background(), PhoneNumber, Message)
+		assert.NoError(t, err)
+	})
+
+	t.Run("fails when message cannot be recorded", func(t *testing.T) {
+		ms := mockmongostore.NewStore()
+		ms.On("InsertSandboxMessage", mock.Anything, mock.Anything).Return(sender.SandboxMessage{}, errors.New("connection refused"))
+
+		c, _ := NewClient(ms, 0, "")
+		_, err := c.SendMessage(context.Background(), PhoneNumber, Message)
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrSimulatedFailure)
+	})
+}
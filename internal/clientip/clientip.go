@@ -0,0 +1,131 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+var clientIPKey = struct{ Key string }{"clientIP"}
+
+// Resolver resolves ip address of clients whose requests may pass through trusted proxies
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver creates and returns resolver trusting comma separated proxy ip addresses or cidr ranges
+func NewResolver(trustedProxies string) (*Resolver, error) {
+	r := &Resolver{}
+	for i, proxy := range strings.Split(trustedProxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing trusted proxies failed, entry %d is not an ip address or cidr range", i+1)
+		}
+		r.trusted = append(r.trusted, n)
+	}
+
+	return r, nil
+}
+
+// Resolve returns ip address of the client of request. Forwarding headers are used only when the request
+// is received from a trusted proxy. X-Forwarded-For is read from right to left and the first address which
+// is not a trusted proxy is the client, since addresses on its left are given by the client itself.
+func (r *Resolver) Resolve(req *http.Request) string {
+	remote := host(req.RemoteAddr)
+	if !r.isTrusted(remote) {
+		return remote
+	}
+
+	var forwarded []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(header, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				forwarded = append(forwarded, host(address))
+			}
+		}
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if !r.isTrusted(forwarded[i]) {
+			return forwarded[i]
+		}
+	}
+
+	// every forwarding hop is trusted
+	if len(forwarded) > 0 {
+		return forwarded[0]
+	}
+	if realIP := strings.TrimSpace(req.Header.Get("X-Real-Ip")); realIP != "" {
+		return realIP
+	}
+
+	return remote
+}
+
+// Handler returns handler adding client ip address to context of requests passed to next
+func (r *Resolver) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(rw, req.WithContext(NewContext(req.Context(), r.Resolve(req))))
+	})
+}
+
+func (r *Resolver) isTrusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range r.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCToContext adds ip address of grpc peer to context
+func GRPCToContext(ctx context.Context, _ metadata.MD) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ctx
+	}
+
+	return NewContext(ctx, host(p.Addr.String()))
+}
+
+// NewContext returns context carrying client ip address
+func NewContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// FromContext returns client ip address of request, it is empty when unknown
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
+
+// host returns host of address with or without port
+func host(address string) string {
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		return h
+	}
+	return strings.Trim(address, "[]")
+}
@@ -0,0 +1,59 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	r, err := NewResolver("10.0.0.0/8, 192.168.1.10")
+	assert.NoError(t, err)
+
+	newRequest := func(remoteAddr string, forwardedFor ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		req.RemoteAddr = remoteAddr
+		for _, f := range forwardedFor {
+			req.Header.Add("X-Forwarded-For", f)
+		}
+		return req
+	}
+
+	t.Run("untrusted remote ignores forwarding headers", func(t *testing.T) {
+		req := newRequest("203.0.113.7:51234", "198.51.100.1")
+		req.Header.Set("X-Real-Ip", "198.51.100.2")
+
+		assert.Equal(t, "203.0.113.7", r.Resolve(req))
+	})
+
+	t.Run("trusted proxy chain", func(t *testing.T) {
+		req := newRequest("10.0.0.5:443", "198.51.100.1, 203.0.113.7, 192.168.1.10")
+
+		assert.Equal(t, "203.0.113.7", r.Resolve(req))
+	})
+
+	t.Run("spoofed forwarded for on the left is ignored", func(t *testing.T) {
+		req := newRequest("10.0.0.5:443", "1.2.3.4", "203.0.113.7")
+
+		assert.Equal(t, "203.0.113.7", r.Resolve(req))
+	})
+
+	t.Run("real ip of trusted proxy", func(t *testing.T) {
+		req := newRequest("192.168.1.10:443")
+		req.Header.Set("X-Real-Ip", "203.0.113.7")
+
+		assert.Equal(t, "203.0.113.7", r.Resolve(req))
+	})
+
+	t.Run("trusted proxy without forwarding headers", func(t *testing.T) {
+		assert.Equal(t, "10.0.0.5", r.Resolve(newRequest("10.0.0.5:443")))
+	})
+}
+
+func TestNewResolver(t *testing.T) {
+	_, err := NewResolver("10.0.0.0/8,proxy.local")
+
+	assert.EqualError(t, err, "parsing trusted proxies failed, entry 2 is not an ip address or cidr range")
+}
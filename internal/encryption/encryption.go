@@ -0,0 +1,172 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix starts encrypted values. It does not tell encrypted values from plaintext, since
+// plaintext may start with it as well. Stores keep encrypted values in fields of their own instead.
+const encryptedPrefix = "enc:"
+
+// keySize is the key size of aes-256
+const keySize = 32
+
+// encryption errors
+var (
+	ErrUnknownKey       = errors.New("encryption key is unknown")
+	ErrMalformedValue   = errors.New("encrypted value is malformed")
+	ErrDisabled         = errors.New("value is encrypted but encryption is not configured")
+	ErrMissingIndexKey  = errors.New("blind index key is missing")
+	ErrMissingActiveKey = errors.New("active encryption key is missing")
+)
+
+// Keys holds aes-256 keys by key id
+type Keys map[string][]byte
+
+// ParseKeys parses comma separated id:key pairs whose keys are base64 encoded 32 bytes
+func ParseKeys(s string) (Keys, error) {
+	keys := Keys{}
+	for i, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || encoded == "" {
+			return nil, fmt.Errorf("parsing encryption keys failed, entry %d is not an id:key pair", i+1)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("parsing encryption keys failed, key %s is not base64 encoded %d bytes", id, keySize)
+		}
+		keys[id] = key
+	}
+
+	return keys, nil
+}
+
+// Cipher encrypts values with the active key and decrypts values encrypted with any of its keys.
+// Keys are rotated by adding the new key, making it active and re-encrypting stored values, the old
+// key can be removed once nothing is encrypted with it anymore.
+type Cipher struct {
+	keys        map[string]cipher.AEAD
+	activeKeyID string
+	indexKey    []byte
+}
+
+// NewCipher creates and returns cipher encrypting with key of activeKeyID. indexKey is the key of
+// blind indexes, it can not be rotated without rebuilding stored indexes.
+func NewCipher(keys Keys, activeKeyID string, indexKey []byte) (*Cipher, error) {
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, ErrMissingActiveKey
+	}
+	if len(indexKey) == 0 {
+		return nil, ErrMissingIndexKey
+	}
+
+	c := &Cipher{
+		keys:        make(map[string]cipher.AEAD, len(keys)),
+		activeKeyID: activeKeyID,
+		indexKey:    indexKey,
+	}
+	for id, key := range keys {
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("creating cipher failed, key id %s contains ':'", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("creating cipher of key %s failed, %s", id, err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("creating gcm of key %s failed, %s", id, err.Error())
+		}
+		c.keys[id] = aead
+	}
+
+	return c, nil
+}
+
+// Encrypt encrypts value with the active key, field is authenticated with the value so that values
+// of different fields can not be swapped. Empty values are kept empty.
+func (c *Cipher) Encrypt(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	aead := c.keys[c.activeKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce failed, %s", err.Error())
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(field))
+
+	return encryptedPrefix + c.activeKeyID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts value encrypted by Encrypt, empty values are kept empty
+func (c *Cipher) Decrypt(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if c == nil {
+		return "", ErrDisabled
+	}
+
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", ErrMalformedValue
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", ErrMalformedValue
+	}
+
+	aead, ok := c.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w, key: %s", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformedValue
+	}
+
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("decrypting value failed, %s", err.Error())
+	}
+
+	return string(plain), nil
+}
+
+// IsCurrent reports whether value is encrypted with the active key
+func (c *Cipher) IsCurrent(value string) bool {
+	return value == "" || strings.HasPrefix(value, encryptedPrefix+c.activeKeyID+":")
+}
+
+// BlindIndex returns hex encoded hmac-sha256 of value, equal values have equal indexes so that
+// encrypted values can be looked up without being decrypted
+func (c *Cipher) BlindIndex(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, c.indexKey)
+	_, _ = mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,88 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCipher(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, keySize)
+	newKey := bytes.Repeat([]byte{2}, keySize)
+	indexKey := []byte("index-key")
+
+	keys, err := ParseKeys("old:" + base64.StdEncoding.EncodeToString(oldKey) + ",new:" + base64.StdEncoding.EncodeToString(newKey))
+	assert.NoError(t, err)
+
+	oldCipher, err := NewCipher(keys, "old", indexKey)
+	assert.NoError(t, err)
+	newCipher, err := NewCipher(keys, "new", indexKey)
+	assert.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		encrypted, err := newCipher.Encrypt("content", "hello")
+		assert.NoError(t, err)
+		assert.NotContains(t, encrypted, "hello")
+
+		decrypted, err := newCipher.Decrypt("content", encrypted)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", decrypted)
+	})
+
+	t.Run("rotated key", func(t *testing.T) {
+		encrypted, err := oldCipher.Encrypt("content", "hello")
+		assert.NoError(t, err)
+		assert.False(t, newCipher.IsCurrent(encrypted))
+
+		decrypted, err := newCipher.Decrypt("content", encrypted)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", decrypted)
+	})
+
+	t.Run("swapped field", func(t *testing.T) {
+		encrypted, err := newCipher.Encrypt("content", "hello")
+		assert.NoError(t, err)
+
+		_, err = newCipher.Decrypt("recipient", encrypted)
+		assert.Error(t, err)
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		_, err := newCipher.Decrypt("content", "hello")
+		assert.ErrorIs(t, err, ErrMalformedValue)
+		assert.False(t, newCipher.IsCurrent("hello"))
+
+		// plaintext written to look encrypted is not decrypted either
+		_, err = newCipher.Decrypt("content", "enc:new:aGVsbG8")
+		assert.ErrorIs(t, err, ErrMalformedValue)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		encrypted, err := newCipher.Encrypt("content", "hello")
+		assert.NoError(t, err)
+
+		var c *Cipher
+		_, err = c.Decrypt("content", encrypted)
+		assert.ErrorIs(t, err, ErrDisabled)
+	})
+
+	t.Run("blind index", func(t *testing.T) {
+		assert.Equal(t, oldCipher.BlindIndex("+905551111111"), newCipher.BlindIndex("+905551111111"))
+		assert.NotEqual(t, newCipher.BlindIndex("+905551111111"), newCipher.BlindIndex("+905552222222"))
+	})
+}
+
+func TestNewCipher(t *testing.T) {
+	keys := Keys{"k1": bytes.Repeat([]byte{1}, keySize)}
+
+	_, err := NewCipher(keys, "k2", []byte("index-key"))
+	assert.ErrorIs(t, err, ErrMissingActiveKey)
+
+	_, err = NewCipher(keys, "k1", nil)
+	assert.ErrorIs(t, err, ErrMissingIndexKey)
+
+	_, err = ParseKeys("k1:c2hvcnQ=")
+	assert.Error(t, err)
+}
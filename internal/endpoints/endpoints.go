@@ -5,21 +5,61 @@ import (
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/auth"
 )
 
 // Endpoints represents service endpoints
 type Endpoints struct {
 	HealthEndpoint                  endpoint.Endpoint
+	LivenessEndpoint                endpoint.Endpoint
+	ReadinessEndpoint               endpoint.Endpoint
 	StartStopMessageSendingEndpoint endpoint.Endpoint
+	GetWorkerStatusEndpoint         endpoint.Endpoint
+	RunWorkerBatchEndpoint          endpoint.Endpoint
+	CreateMessageEndpoint           endpoint.Endpoint
 	RetrieveSentMessagesEndpoint    endpoint.Endpoint
+	CancelMessageEndpoint           endpoint.Endpoint
+	CancelMessagesEndpoint          endpoint.Endpoint
+	RequeueMessagesEndpoint         endpoint.Endpoint
+	UpdateMessageEndpoint           endpoint.Endpoint
+	GetMessageEndpoint              endpoint.Endpoint
+	GetStatsEndpoint                endpoint.Endpoint
+	ExportMessagesEndpoint          endpoint.Endpoint
+	StreamMessageEventsEndpoint     endpoint.Endpoint
+	CreateAPIKeyEndpoint            endpoint.Endpoint
+	ListAPIKeysEndpoint             endpoint.Endpoint
+	RevokeAPIKeyEndpoint            endpoint.Endpoint
+	DeliveryReceiptEndpoint         endpoint.Endpoint
+	GetSandboxMessagesEndpoint      endpoint.Endpoint
 }
 
-// MakeEndpoints makes and returns endpoints
-func MakeEndpoints(s sender.Service) Endpoints {
+// MakeEndpoints makes and returns endpoints, every endpoint but health checks and provider callbacks is wrapped
+// with authentication middleware for the scope it requires and with rate limiting middleware rl around it, so
+// that requests with missing or invalid api keys are limited as well.
+// Provider callbacks are authenticated by their signature in transport.
+func MakeEndpoints(s sender.Service, am auth.Middleware, rl endpoint.Middleware) Endpoints {
 	return Endpoints{
 		HealthEndpoint:                  MakeHealthEndpoint(s),
-		StartStopMessageSendingEndpoint: MakeStartStopMessageSendingEndpoint(s),
-		RetrieveSentMessagesEndpoint:    MakeRetrieveSentMessagesEndpoint(s),
+		LivenessEndpoint:                MakeLivenessEndpoint(s),
+		ReadinessEndpoint:               MakeReadinessEndpoint(s),
+		StartStopMessageSendingEndpoint: rl(am(sender.ScopeWorkerControl)(MakeStartStopMessageSendingEndpoint(s))),
+		GetWorkerStatusEndpoint:         rl(am(sender.ScopeWorkerControl)(MakeGetWorkerStatusEndpoint(s))),
+		RunWorkerBatchEndpoint:          rl(am(sender.ScopeWorkerControl)(MakeRunWorkerBatchEndpoint(s))),
+		CreateMessageEndpoint:           rl(am(sender.ScopeMessagesCreate)(MakeCreateMessageEndpoint(s))),
+		RetrieveSentMessagesEndpoint:    rl(am(sender.ScopeMessagesRead)(MakeRetrieveSentMessagesEndpoint(s))),
+		CancelMessageEndpoint:           rl(am(sender.ScopeMessagesWrite)(MakeCancelMessageEndpoint(s))),
+		CancelMessagesEndpoint:          rl(am(sender.ScopeMessagesWrite)(MakeCancelMessagesEndpoint(s))),
+		RequeueMessagesEndpoint:         rl(am(sender.ScopeWorkerControl)(MakeRequeueMessagesEndpoint(s))),
+		UpdateMessageEndpoint:           rl(am(sender.ScopeMessagesWrite)(MakeUpdateMessageEndpoint(s))),
+		GetMessageEndpoint:              rl(am(sender.ScopeMessagesRead)(MakeGetMessageEndpoint(s))),
+		GetStatsEndpoint:                rl(am(sender.ScopeMessagesRead)(MakeGetStatsEndpoint(s))),
+		ExportMessagesEndpoint:          rl(am(sender.ScopeMessagesRead)(MakeExportMessagesEndpoint(s))),
+		StreamMessageEventsEndpoint:     rl(am(sender.ScopeMessagesRead)(MakeStreamMessageEventsEndpoint(s))),
+		CreateAPIKeyEndpoint:            rl(am(sender.ScopeKeysAdmin)(MakeCreateAPIKeyEndpoint(s))),
+		ListAPIKeysEndpoint:             rl(am(sender.ScopeKeysAdmin)(MakeListAPIKeysEndpoint(s))),
+		RevokeAPIKeyEndpoint:            rl(am(sender.ScopeKeysAdmin)(MakeRevokeAPIKeyEndpoint(s))),
+		DeliveryReceiptEndpoint:         MakeDeliveryReceiptEndpoint(s),
+		GetSandboxMessagesEndpoint:      rl(am(sender.ScopeMessagesRead)(MakeGetSandboxMessagesEndpoint(s))),
 	}
 }
 
@@ -34,6 +74,28 @@ func MakeHealthEndpoint(s sender.Service) endpoint.Endpoint {
 	}
 }
 
+// MakeLivenessEndpoint makes and returns liveness endpoint
+func MakeLivenessEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.LivenessRequest)
+
+		res := s.Liveness(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeReadinessEndpoint makes and returns readiness endpoint
+func MakeReadinessEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.ReadinessRequest)
+
+		res := s.Readiness(ctx, *req)
+
+		return res, nil
+	}
+}
+
 // MakeStartStopMessageSendingEndpoint makes and returns start stop message sending endpoint
 func MakeStartStopMessageSendingEndpoint(s sender.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -45,6 +107,39 @@ func MakeStartStopMessageSendingEndpoint(s sender.Service) endpoint.Endpoint {
 	}
 }
 
+// MakeGetWorkerStatusEndpoint makes and returns get worker status endpoint
+func MakeGetWorkerStatusEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.GetWorkerStatusRequest)
+
+		res := s.GetWorkerStatus(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeRunWorkerBatchEndpoint makes and returns run worker batch endpoint
+func MakeRunWorkerBatchEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.RunWorkerBatchRequest)
+
+		res := s.RunWorkerBatch(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeCreateMessageEndpoint makes and returns create message endpoint
+func MakeCreateMessageEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.CreateMessageRequest)
+
+		res := s.CreateMessage(ctx, *req)
+
+		return res, nil
+	}
+}
+
 // MakeRetrieveSentMessagesEndpoint makes and returns retrieve sent messages endpoint
 func MakeRetrieveSentMessagesEndpoint(s sender.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -55,3 +150,146 @@ func MakeRetrieveSentMessagesEndpoint(s sender.Service) endpoint.Endpoint {
 		return res, nil
 	}
 }
+
+// MakeCancelMessageEndpoint makes and returns cancel message endpoint
+func MakeCancelMessageEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.CancelMessageRequest)
+
+		res := s.CancelMessage(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeCancelMessagesEndpoint makes and returns cancel messages endpoint
+func MakeCancelMessagesEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.CancelMessagesRequest)
+
+		res := s.CancelMessages(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeRequeueMessagesEndpoint makes and returns requeue messages endpoint
+func MakeRequeueMessagesEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.RequeueMessagesRequest)
+
+		res := s.RequeueMessages(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeUpdateMessageEndpoint makes and returns update message endpoint
+func MakeUpdateMessageEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.UpdateMessageRequest)
+
+		res := s.UpdateMessage(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeGetMessageEndpoint makes and returns get message endpoint
+func MakeGetMessageEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.GetMessageRequest)
+
+		res := s.GetMessage(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeGetStatsEndpoint makes and returns get stats endpoint
+func MakeGetStatsEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.GetStatsRequest)
+
+		res := s.GetStats(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeExportMessagesEndpoint makes and returns export messages endpoint
+func MakeExportMessagesEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.ExportMessagesRequest)
+
+		res := s.ExportMessages(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeStreamMessageEventsEndpoint makes and returns stream message events endpoint
+func MakeStreamMessageEventsEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.StreamMessageEventsRequest)
+
+		res := s.StreamMessageEvents(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeCreateAPIKeyEndpoint makes and returns create api key endpoint
+func MakeCreateAPIKeyEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.CreateAPIKeyRequest)
+
+		res := s.CreateAPIKey(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeListAPIKeysEndpoint makes and returns list api keys endpoint
+func MakeListAPIKeysEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.ListAPIKeysRequest)
+
+		res := s.ListAPIKeys(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeRevokeAPIKeyEndpoint makes and returns revoke api key endpoint
+func MakeRevokeAPIKeyEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.RevokeAPIKeyRequest)
+
+		res := s.RevokeAPIKey(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeDeliveryReceiptEndpoint makes and returns delivery receipt endpoint
+func MakeDeliveryReceiptEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.DeliveryReceiptRequest)
+
+		res := s.ReceiveDeliveryReceipt(ctx, *req)
+
+		return res, nil
+	}
+}
+
+// MakeGetSandboxMessagesEndpoint makes and returns get sandbox messages endpoint
+func MakeGetSandboxMessagesEndpoint(s sender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*sender.GetSandboxMessagesRequest)
+
+		res := s.GetSandboxMessages(ctx, *req)
+
+		return res, nil
+	}
+}
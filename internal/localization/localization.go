@@ -9,6 +9,7 @@ import (
 	envvars "github.com/mkaykisiz/sender/configs/env-vars"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"golang.org/x/text/language"
+	"google.golang.org/grpc/metadata"
 )
 
 // global bundle
@@ -56,6 +57,18 @@ func AddLocalizerToContext(ctx context.Context, r *http.Request) context.Context
 	return context.WithValue(ctx, localizerKey, *l)
 }
 
+// AddLocalizerToGRPCContext creates and adds new localizer to context of grpc request
+func AddLocalizerToGRPCContext(ctx context.Context, md metadata.MD) context.Context {
+	var acceptLanguage string
+	if values := md.Get("accept-language"); len(values) > 0 {
+		acceptLanguage = values[0]
+	}
+
+	l := i18n.NewLocalizer(bundle, acceptLanguage)
+
+	return context.WithValue(ctx, localizerKey, *l)
+}
+
 // GetLocalizerFromContext gets and returns localizer from context
 func GetLocalizerFromContext(ctx context.Context) *i18n.Localizer {
 	l, ok := ctx.Value(localizerKey).(i18n.Localizer)
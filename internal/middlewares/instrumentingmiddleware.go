@@ -0,0 +1,232 @@
+package middlewares
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/mkaykisiz/sender"
+)
+
+// InstrumentingMiddleware represents instrumenting middleware counting requests and observing their
+// latencies per method
+type InstrumentingMiddleware struct {
+	requestCount   metrics.Counter
+	requestLatency metrics.Histogram
+	next           sender.Service
+}
+
+// NewInstrumentingMiddleware creates and returns instrumenting middleware, requestCount and requestLatency
+// are labeled by method and error
+func NewInstrumentingMiddleware(requestCount metrics.Counter, requestLatency metrics.Histogram) Middleware {
+	return func(next sender.Service) sender.Service {
+		return &InstrumentingMiddleware{
+			requestCount:   requestCount,
+			requestLatency: requestLatency,
+			next:           next,
+		}
+	}
+}
+
+// Health represents instrumenting middleware for Health method
+func (m *InstrumentingMiddleware) Health(ctx context.Context, req sender.HealthRequest) (res sender.HealthResponse) {
+	defer func(begin time.Time) {
+		m.observe("Health", begin, res.APIError() != nil)
+	}(time.Now())
+
+	return m.next.Health(ctx, req)
+}
+
+// Liveness represents instrumenting middleware for Liveness method
+func (m *InstrumentingMiddleware) Liveness(ctx context.Context, req sender.LivenessRequest) (res sender.LivenessResponse) {
+	defer func(begin time.Time) {
+		m.observe("Liveness", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.Liveness(ctx, req)
+}
+
+// Readiness represents instrumenting middleware for Readiness method
+func (m *InstrumentingMiddleware) Readiness(ctx context.Context, req sender.ReadinessRequest) (res sender.ReadinessResponse) {
+	defer func(begin time.Time) {
+		m.observe("Readiness", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.Readiness(ctx, req)
+}
+
+// StartStopMessageSending represents instrumenting middleware for StartStopMessageSending method
+func (m *InstrumentingMiddleware) StartStopMessageSending(ctx context.Context, req sender.StartStopMessageSendingRequest) (res sender.StartStopMessageSendingResponse) {
+	defer func(begin time.Time) {
+		m.observe("StartStopMessageSending", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.StartStopMessageSending(ctx, req)
+}
+
+// GetWorkerStatus represents instrumenting middleware for GetWorkerStatus method
+func (m *InstrumentingMiddleware) GetWorkerStatus(ctx context.Context, req sender.GetWorkerStatusRequest) (res sender.GetWorkerStatusResponse) {
+	defer func(begin time.Time) {
+		m.observe("GetWorkerStatus", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.GetWorkerStatus(ctx, req)
+}
+
+// RunWorkerBatch represents instrumenting middleware for RunWorkerBatch method
+func (m *InstrumentingMiddleware) RunWorkerBatch(ctx context.Context, req sender.RunWorkerBatchRequest) (res sender.RunWorkerBatchResponse) {
+	defer func(begin time.Time) {
+		m.observe("RunWorkerBatch", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.RunWorkerBatch(ctx, req)
+}
+
+// CreateMessage represents instrumenting middleware for CreateMessage method
+func (m *InstrumentingMiddleware) CreateMessage(ctx context.Context, req sender.CreateMessageRequest) (res sender.CreateMessageResponse) {
+	defer func(begin time.Time) {
+		m.observe("CreateMessage", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.CreateMessage(ctx, req)
+}
+
+// RetrieveSentMessages represents instrumenting middleware for RetrieveSentMessages method
+func (m *InstrumentingMiddleware) RetrieveSentMessages(ctx context.Context, req sender.RetrieveSentMessagesRequest) (res sender.RetrieveSentMessagesResponse) {
+	defer func(begin time.Time) {
+		m.observe("RetrieveSentMessages", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.RetrieveSentMessages(ctx, req)
+}
+
+// CancelMessage represents instrumenting middleware for CancelMessage method
+func (m *InstrumentingMiddleware) CancelMessage(ctx context.Context, req sender.CancelMessageRequest) (res sender.CancelMessageResponse) {
+	defer func(begin time.Time) {
+		m.observe("CancelMessage", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.CancelMessage(ctx, req)
+}
+
+// CancelMessages represents instrumenting middleware for CancelMessages method
+func (m *InstrumentingMiddleware) CancelMessages(ctx context.Context, req sender.CancelMessagesRequest) (res sender.CancelMessagesResponse) {
+	defer func(begin time.Time) {
+		m.observe("CancelMessages", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.CancelMessages(ctx, req)
+}
+
+// RequeueMessages represents instrumenting middleware for RequeueMessages method
+func (m *InstrumentingMiddleware) RequeueMessages(ctx context.Context, req sender.RequeueMessagesRequest) (res sender.RequeueMessagesResponse) {
+	defer func(begin time.Time) {
+		m.observe("RequeueMessages", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.RequeueMessages(ctx, req)
+}
+
+// UpdateMessage represents instrumenting middleware for UpdateMessage method
+func (m *InstrumentingMiddleware) UpdateMessage(ctx context.Context, req sender.UpdateMessageRequest) (res sender.UpdateMessageResponse) {
+	defer func(begin time.Time) {
+		m.observe("UpdateMessage", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.UpdateMessage(ctx, req)
+}
+
+// GetMessage represents instrumenting middleware for GetMessage method
+func (m *InstrumentingMiddleware) GetMessage(ctx context.Context, req sender.GetMessageRequest) (res sender.GetMessageResponse) {
+	defer func(begin time.Time) {
+		m.observe("GetMessage", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.GetMessage(ctx, req)
+}
+
+// GetStats represents instrumenting middleware for GetStats method
+func (m *InstrumentingMiddleware) GetStats(ctx context.Context, req sender.GetStatsRequest) (res sender.GetStatsResponse) {
+	defer func(begin time.Time) {
+		m.observe("GetStats", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.GetStats(ctx, req)
+}
+
+// ExportMessages represents instrumenting middleware for ExportMessages method
+func (m *InstrumentingMiddleware) ExportMessages(ctx context.Context, req sender.ExportMessagesRequest) (res sender.ExportMessagesResponse) {
+	defer func(begin time.Time) {
+		m.observe("ExportMessages", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.ExportMessages(ctx, req)
+}
+
+// StreamMessageEvents represents instrumenting middleware for StreamMessageEvents method
+func (m *InstrumentingMiddleware) StreamMessageEvents(ctx context.Context, req sender.StreamMessageEventsRequest) (res sender.StreamMessageEventsResponse) {
+	defer func(begin time.Time) {
+		m.observe("StreamMessageEvents", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.StreamMessageEvents(ctx, req)
+}
+
+// CreateAPIKey represents instrumenting middleware for CreateAPIKey method
+func (m *InstrumentingMiddleware) CreateAPIKey(ctx context.Context, req sender.CreateAPIKeyRequest) (res sender.CreateAPIKeyResponse) {
+	defer func(begin time.Time) {
+		m.observe("CreateAPIKey", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.CreateAPIKey(ctx, req)
+}
+
+// ListAPIKeys represents instrumenting middleware for ListAPIKeys method
+func (m *InstrumentingMiddleware) ListAPIKeys(ctx context.Context, req sender.ListAPIKeysRequest) (res sender.ListAPIKeysResponse) {
+	defer func(begin time.Time) {
+		m.observe("ListAPIKeys", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.ListAPIKeys(ctx, req)
+}
+
+// RevokeAPIKey represents instrumenting middleware for RevokeAPIKey method
+func (m *InstrumentingMiddleware) RevokeAPIKey(ctx context.Context, req sender.RevokeAPIKeyRequest) (res sender.RevokeAPIKeyResponse) {
+	defer func(begin time.Time) {
+		m.observe("RevokeAPIKey", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.RevokeAPIKey(ctx, req)
+}
+
+// ReceiveDeliveryReceipt represents instrumenting middleware for ReceiveDeliveryReceipt method
+func (m *InstrumentingMiddleware) ReceiveDeliveryReceipt(ctx context.Context, req sender.DeliveryReceiptRequest) (res sender.DeliveryReceiptResponse) {
+	defer func(begin time.Time) {
+		m.observe("ReceiveDeliveryReceipt", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.ReceiveDeliveryReceipt(ctx, req)
+}
+
+// GetSandboxMessages represents instrumenting middleware for GetSandboxMessages method
+func (m *InstrumentingMiddleware) GetSandboxMessages(ctx context.Context, req sender.GetSandboxMessagesRequest) (res sender.GetSandboxMessagesResponse) {
+	defer func(begin time.Time) {
+		m.observe("GetSandboxMessages", begin, res.Result != nil)
+	}(time.Now())
+
+	return m.next.GetSandboxMessages(ctx, req)
+}
+
+// StartSendMessage represents instrumenting middleware for StartSendMessage method
+func (m *InstrumentingMiddleware) StartSendMessage(count int, delay time.Duration) {
+	m.next.StartSendMessage(count, delay)
+}
+
+// observe counts request of method and observes its latency. Latencies of streaming methods cover
+// only opening the stream.
+func (m *InstrumentingMiddleware) observe(method string, begin time.Time, failed bool) {
+	lvs := []string{"method", method, "error", strconv.FormatBool(failed)}
+	m.requestCount.With(lvs...).Add(1)
+	m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/go-kit/kit/log"
 	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/auth"
+	"github.com/mkaykisiz/sender/internal/requestid"
 	"time"
 )
 
@@ -28,11 +30,33 @@ func (m *LoggingMiddleware) Health(ctx context.Context, req sender.HealthRequest
 	return m.next.Health(ctx, req)
 }
 
+// Liveness represents logging middleware for Liveness method
+func (m *LoggingMiddleware) Liveness(ctx context.Context, req sender.LivenessRequest) sender.LivenessResponse {
+	res := m.next.Liveness(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method": "Liveness",
+		})
+	}
+	return res
+}
+
+// Readiness represents logging middleware for Readiness method
+func (m *LoggingMiddleware) Readiness(ctx context.Context, req sender.ReadinessRequest) sender.ReadinessResponse {
+	res := m.next.Readiness(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method": "Readiness",
+		})
+	}
+	return res
+}
+
 // StartStopMessageSending represents logging middleware for StartStopMessageSending method
 func (m *LoggingMiddleware) StartStopMessageSending(ctx context.Context, req sender.StartStopMessageSendingRequest) sender.StartStopMessageSendingResponse {
 	res := m.next.StartStopMessageSending(ctx, req)
 	if res.Result != nil {
-		m.logWithLogger(res.Result.BaseError, map[string]interface{}{
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
 			"method":    "StartStopMessageSending",
 			"action":    req.Action,
 			"ipAddress": req.IPAddress,
@@ -41,12 +65,223 @@ func (m *LoggingMiddleware) StartStopMessageSending(ctx context.Context, req sen
 	return res
 }
 
+// GetWorkerStatus represents logging middleware for GetWorkerStatus method
+func (m *LoggingMiddleware) GetWorkerStatus(ctx context.Context, req sender.GetWorkerStatusRequest) sender.GetWorkerStatusResponse {
+	res := m.next.GetWorkerStatus(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "GetWorkerStatus",
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// RunWorkerBatch represents logging middleware for RunWorkerBatch method
+func (m *LoggingMiddleware) RunWorkerBatch(ctx context.Context, req sender.RunWorkerBatchRequest) sender.RunWorkerBatchResponse {
+	res := m.next.RunWorkerBatch(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "RunWorkerBatch",
+			"dryRun":    req.DryRun,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// CreateMessage represents logging middleware for CreateMessage method
+func (m *LoggingMiddleware) CreateMessage(ctx context.Context, req sender.CreateMessageRequest) sender.CreateMessageResponse {
+	res := m.next.CreateMessage(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "CreateMessage",
+			"recipient": req.Recipient,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
 // RetrieveSentMessages represents logging middleware for RetrieveSentMessages method
 func (m *LoggingMiddleware) RetrieveSentMessages(ctx context.Context, req sender.RetrieveSentMessagesRequest) sender.RetrieveSentMessagesResponse {
 	res := m.next.RetrieveSentMessages(ctx, req)
 	if res.Result != nil {
-		m.logWithLogger(res.Result.BaseError, map[string]interface{}{
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
 			"method":    "RetrieveSentMessages",
+			"recipient": req.Recipient,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// CancelMessage represents logging middleware for CancelMessage method
+func (m *LoggingMiddleware) CancelMessage(ctx context.Context, req sender.CancelMessageRequest) sender.CancelMessageResponse {
+	res := m.next.CancelMessage(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "CancelMessage",
+			"id":        req.ID,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// CancelMessages represents logging middleware for CancelMessages method
+func (m *LoggingMiddleware) CancelMessages(ctx context.Context, req sender.CancelMessagesRequest) sender.CancelMessagesResponse {
+	res := m.next.CancelMessages(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "CancelMessages",
+			"ids":       req.IDs,
+			"recipient": req.Recipient,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// RequeueMessages represents logging middleware for RequeueMessages method
+func (m *LoggingMiddleware) RequeueMessages(ctx context.Context, req sender.RequeueMessagesRequest) sender.RequeueMessagesResponse {
+	res := m.next.RequeueMessages(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "RequeueMessages",
+			"ids":       req.IDs,
+			"status":    req.Status,
+			"recipient": req.Recipient,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// UpdateMessage represents logging middleware for UpdateMessage method
+func (m *LoggingMiddleware) UpdateMessage(ctx context.Context, req sender.UpdateMessageRequest) sender.UpdateMessageResponse {
+	res := m.next.UpdateMessage(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "UpdateMessage",
+			"id":        req.ID,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// GetMessage represents logging middleware for GetMessage method
+func (m *LoggingMiddleware) GetMessage(ctx context.Context, req sender.GetMessageRequest) sender.GetMessageResponse {
+	res := m.next.GetMessage(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "GetMessage",
+			"id":        req.ID,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// GetStats represents logging middleware for GetStats method
+func (m *LoggingMiddleware) GetStats(ctx context.Context, req sender.GetStatsRequest) sender.GetStatsResponse {
+	res := m.next.GetStats(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":        "GetStats",
+			"windowMinutes": req.WindowMinutes,
+			"ipAddress":     req.IPAddress,
+		})
+	}
+	return res
+}
+
+// ExportMessages represents logging middleware for ExportMessages method
+func (m *LoggingMiddleware) ExportMessages(ctx context.Context, req sender.ExportMessagesRequest) sender.ExportMessagesResponse {
+	res := m.next.ExportMessages(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "ExportMessages",
+			"format":    req.Format,
+			"recipient": req.Recipient,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// StreamMessageEvents represents logging middleware for StreamMessageEvents method
+func (m *LoggingMiddleware) StreamMessageEvents(ctx context.Context, req sender.StreamMessageEventsRequest) sender.StreamMessageEventsResponse {
+	res := m.next.StreamMessageEvents(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "StreamMessageEvents",
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// CreateAPIKey represents logging middleware for CreateAPIKey method
+func (m *LoggingMiddleware) CreateAPIKey(ctx context.Context, req sender.CreateAPIKeyRequest) sender.CreateAPIKeyResponse {
+	res := m.next.CreateAPIKey(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "CreateAPIKey",
+			"name":      req.Name,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// ListAPIKeys represents logging middleware for ListAPIKeys method
+func (m *LoggingMiddleware) ListAPIKeys(ctx context.Context, req sender.ListAPIKeysRequest) sender.ListAPIKeysResponse {
+	res := m.next.ListAPIKeys(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "ListAPIKeys",
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// RevokeAPIKey represents logging middleware for RevokeAPIKey method
+func (m *LoggingMiddleware) RevokeAPIKey(ctx context.Context, req sender.RevokeAPIKeyRequest) sender.RevokeAPIKeyResponse {
+	res := m.next.RevokeAPIKey(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "RevokeAPIKey",
+			"id":        req.ID,
+			"ipAddress": req.IPAddress,
+		})
+	}
+	return res
+}
+
+// ReceiveDeliveryReceipt represents logging middleware for ReceiveDeliveryReceipt method
+func (m *LoggingMiddleware) ReceiveDeliveryReceipt(ctx context.Context, req sender.DeliveryReceiptRequest) sender.DeliveryReceiptResponse {
+	res := m.next.ReceiveDeliveryReceipt(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":            "ReceiveDeliveryReceipt",
+			"providerMessageID": req.ProviderMessageID,
+			"ipAddress":         req.IPAddress,
+		})
+	}
+	return res
+}
+
+// GetSandboxMessages represents logging middleware for GetSandboxMessages method
+func (m *LoggingMiddleware) GetSandboxMessages(ctx context.Context, req sender.GetSandboxMessagesRequest) sender.GetSandboxMessagesResponse {
+	res := m.next.GetSandboxMessages(ctx, req)
+	if res.Result != nil {
+		m.logWithLogger(ctx, res.Result.BaseError, map[string]interface{}{
+			"method":    "GetSandboxMessages",
+			"recipient": req.Recipient,
+			"limit":     req.Limit,
 			"ipAddress": req.IPAddress,
 		})
 	}
@@ -58,13 +293,21 @@ func (m *LoggingMiddleware) StartSendMessage(count int, delay time.Duration) {
 
 }
 
-func (m *LoggingMiddleware) logWithLogger(err error, additionalParams map[string]interface{}) {
-	logParams := make([]interface{}, 0, 2+len(additionalParams)*2)
+func (m *LoggingMiddleware) logWithLogger(ctx context.Context, err error, additionalParams map[string]interface{}) {
+	logParams := make([]interface{}, 0, 8+len(additionalParams)*2)
 
 	for k, v := range additionalParams {
 		logParams = append(logParams, k, v)
 	}
 
+	if id := requestid.FromContext(ctx); id != "" {
+		logParams = append(logParams, "requestID", id)
+	}
+
+	if id, ok := auth.FromContext(ctx); ok {
+		logParams = append(logParams, "apiKeyID", id.KeyID, "apiKeyName", id.Name)
+	}
+
 	logParams = append(logParams, "error", err.Error())
 
 	_ = m.l.Log(logParams...)
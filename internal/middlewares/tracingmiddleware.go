@@ -0,0 +1,206 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware represents tracing middleware starting a span for every method call
+type TracingMiddleware struct {
+	next sender.Service
+}
+
+// NewTracingMiddleware creates and returns tracing middleware
+func NewTracingMiddleware() Middleware {
+	return func(next sender.Service) sender.Service {
+		return &TracingMiddleware{
+			next: next,
+		}
+	}
+}
+
+// Health represents tracing middleware for Health method, health checks are not traced
+func (m *TracingMiddleware) Health(ctx context.Context, req sender.HealthRequest) sender.HealthResponse {
+	return m.next.Health(ctx, req)
+}
+
+// Liveness represents tracing middleware for Liveness method, probes are not traced
+func (m *TracingMiddleware) Liveness(ctx context.Context, req sender.LivenessRequest) sender.LivenessResponse {
+	return m.next.Liveness(ctx, req)
+}
+
+// Readiness represents tracing middleware for Readiness method, probes are not traced
+func (m *TracingMiddleware) Readiness(ctx context.Context, req sender.ReadinessRequest) sender.ReadinessResponse {
+	return m.next.Readiness(ctx, req)
+}
+
+// StartStopMessageSending represents tracing middleware for StartStopMessageSending method
+func (m *TracingMiddleware) StartStopMessageSending(ctx context.Context, req sender.StartStopMessageSendingRequest) (res sender.StartStopMessageSendingResponse) {
+	ctx, span := m.start(ctx, "StartStopMessageSending")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.StartStopMessageSending(ctx, req)
+}
+
+// GetWorkerStatus represents tracing middleware for GetWorkerStatus method
+func (m *TracingMiddleware) GetWorkerStatus(ctx context.Context, req sender.GetWorkerStatusRequest) (res sender.GetWorkerStatusResponse) {
+	ctx, span := m.start(ctx, "GetWorkerStatus")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.GetWorkerStatus(ctx, req)
+}
+
+// RunWorkerBatch represents tracing middleware for RunWorkerBatch method
+func (m *TracingMiddleware) RunWorkerBatch(ctx context.Context, req sender.RunWorkerBatchRequest) (res sender.RunWorkerBatchResponse) {
+	ctx, span := m.start(ctx, "RunWorkerBatch")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.RunWorkerBatch(ctx, req)
+}
+
+// CreateMessage represents tracing middleware for CreateMessage method
+func (m *TracingMiddleware) CreateMessage(ctx context.Context, req sender.CreateMessageRequest) (res sender.CreateMessageResponse) {
+	ctx, span := m.start(ctx, "CreateMessage")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.CreateMessage(ctx, req)
+}
+
+// RetrieveSentMessages represents tracing middleware for RetrieveSentMessages method
+func (m *TracingMiddleware) RetrieveSentMessages(ctx context.Context, req sender.RetrieveSentMessagesRequest) (res sender.RetrieveSentMessagesResponse) {
+	ctx, span := m.start(ctx, "RetrieveSentMessages")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.RetrieveSentMessages(ctx, req)
+}
+
+// CancelMessage represents tracing middleware for CancelMessage method
+func (m *TracingMiddleware) CancelMessage(ctx context.Context, req sender.CancelMessageRequest) (res sender.CancelMessageResponse) {
+	ctx, span := m.start(ctx, "CancelMessage")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.CancelMessage(ctx, req)
+}
+
+// CancelMessages represents tracing middleware for CancelMessages method
+func (m *TracingMiddleware) CancelMessages(ctx context.Context, req sender.CancelMessagesRequest) (res sender.CancelMessagesResponse) {
+	ctx, span := m.start(ctx, "CancelMessages")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.CancelMessages(ctx, req)
+}
+
+// RequeueMessages represents tracing middleware for RequeueMessages method
+func (m *TracingMiddleware) RequeueMessages(ctx context.Context, req sender.RequeueMessagesRequest) (res sender.RequeueMessagesResponse) {
+	ctx, span := m.start(ctx, "RequeueMessages")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.RequeueMessages(ctx, req)
+}
+
+// UpdateMessage represents tracing middleware for UpdateMessage method
+func (m *TracingMiddleware) UpdateMessage(ctx context.Context, req sender.UpdateMessageRequest) (res sender.UpdateMessageResponse) {
+	ctx, span := m.start(ctx, "UpdateMessage")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.UpdateMessage(ctx, req)
+}
+
+// GetMessage represents tracing middleware for GetMessage method
+func (m *TracingMiddleware) GetMessage(ctx context.Context, req sender.GetMessageRequest) (res sender.GetMessageResponse) {
+	ctx, span := m.start(ctx, "GetMessage")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.GetMessage(ctx, req)
+}
+
+// GetStats represents tracing middleware for GetStats method
+func (m *TracingMiddleware) GetStats(ctx context.Context, req sender.GetStatsRequest) (res sender.GetStatsResponse) {
+	ctx, span := m.start(ctx, "GetStats")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.GetStats(ctx, req)
+}
+
+// ExportMessages represents tracing middleware for ExportMessages method
+func (m *TracingMiddleware) ExportMessages(ctx context.Context, req sender.ExportMessagesRequest) (res sender.ExportMessagesResponse) {
+	ctx, span := m.start(ctx, "ExportMessages")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.ExportMessages(ctx, req)
+}
+
+// StreamMessageEvents represents tracing middleware for StreamMessageEvents method
+func (m *TracingMiddleware) StreamMessageEvents(ctx context.Context, req sender.StreamMessageEventsRequest) (res sender.StreamMessageEventsResponse) {
+	ctx, span := m.start(ctx, "StreamMessageEvents")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.StreamMessageEvents(ctx, req)
+}
+
+// CreateAPIKey represents tracing middleware for CreateAPIKey method
+func (m *TracingMiddleware) CreateAPIKey(ctx context.Context, req sender.CreateAPIKeyRequest) (res sender.CreateAPIKeyResponse) {
+	ctx, span := m.start(ctx, "CreateAPIKey")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.CreateAPIKey(ctx, req)
+}
+
+// ListAPIKeys represents tracing middleware for ListAPIKeys method
+func (m *TracingMiddleware) ListAPIKeys(ctx context.Context, req sender.ListAPIKeysRequest) (res sender.ListAPIKeysResponse) {
+	ctx, span := m.start(ctx, "ListAPIKeys")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.ListAPIKeys(ctx, req)
+}
+
+// RevokeAPIKey represents tracing middleware for RevokeAPIKey method
+func (m *TracingMiddleware) RevokeAPIKey(ctx context.Context, req sender.RevokeAPIKeyRequest) (res sender.RevokeAPIKeyResponse) {
+	ctx, span := m.start(ctx, "RevokeAPIKey")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.RevokeAPIKey(ctx, req)
+}
+
+// ReceiveDeliveryReceipt represents tracing middleware for ReceiveDeliveryReceipt method
+func (m *TracingMiddleware) ReceiveDeliveryReceipt(ctx context.Context, req sender.DeliveryReceiptRequest) (res sender.DeliveryReceiptResponse) {
+	ctx, span := m.start(ctx, "ReceiveDeliveryReceipt")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.ReceiveDeliveryReceipt(ctx, req)
+}
+
+// GetSandboxMessages represents tracing middleware for GetSandboxMessages method
+func (m *TracingMiddleware) GetSandboxMessages(ctx context.Context, req sender.GetSandboxMessagesRequest) (res sender.GetSandboxMessagesResponse) {
+	ctx, span := m.start(ctx, "GetSandboxMessages")
+	defer func() { m.end(span, res.Result) }()
+
+	return m.next.GetSandboxMessages(ctx, req)
+}
+
+// StartSendMessage represents tracing middleware for StartSendMessage method
+func (m *TracingMiddleware) StartSendMessage(count int, delay time.Duration) {
+	m.next.StartSendMessage(count, delay)
+}
+
+// start starts span of method. Spans of streaming methods cover only opening the stream.
+func (m *TracingMiddleware) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "sender.Service/"+method)
+}
+
+// end ends span, result is recorded as the error of span unless it is nil
+func (m *TracingMiddleware) end(span trace.Span, result *apierror.APIError) {
+	if result == nil {
+		tracing.End(span, nil)
+		return
+	}
+
+	span.SetAttributes(attribute.String("api_error.name", result.Name))
+	tracing.End(span, result)
+}
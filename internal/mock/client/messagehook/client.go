@@ -26,3 +26,8 @@ func (c *Client) SendMessage(ctx context.Context, to, content string) (*messageh
 
 	return args.Get(0).(*messagehookclient.MessageResponse), args.Error(1)
 }
+
+// Name returns mock provider name
+func (c *Client) Name() string {
+	return "mock"
+}
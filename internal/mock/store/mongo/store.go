@@ -2,7 +2,6 @@ package mockmongostore
 
 import (
 	"context"
-	"fmt"
 	"github.com/mkaykisiz/sender"
 	"github.com/stretchr/testify/mock"
 	"time"
@@ -30,11 +29,39 @@ func (s *Store) GetMessages(ctx context.Context, f mongostore.MessageFilter, o m
 	return args.Get(0).([]sender.MessageTransaction), args.Error(1)
 }
 
+// StreamMessages mocks stream messages, fn is called for each message given as the first return value
+func (s *Store) StreamMessages(ctx context.Context, f mongostore.MessageFilter, o mongostore.MessageOptions, fn func(sender.MessageTransaction) error) error {
+	args := s.Called(ctx, f, o, fn)
+	for _, mt := range args.Get(0).([]sender.MessageTransaction) {
+		if err := fn(mt); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+// GetMessage mocks get message
+func (s *Store) GetMessage(ctx context.Context, id primitive.ObjectID) (sender.MessageTransaction, error) {
+	args := s.Called(ctx, id)
+	return args.Get(0).(sender.MessageTransaction), args.Error(1)
+}
+
+// GetMessageByProviderMessageID mocks get message by provider message id
+func (s *Store) GetMessageByProviderMessageID(ctx context.Context, providerMessageID string) (sender.MessageTransaction, error) {
+	args := s.Called(ctx, providerMessageID)
+	return args.Get(0).(sender.MessageTransaction), args.Error(1)
+}
+
 // UpdateMessageStatus mocks update message status
-func (s *Store) UpdateMessageStatus(ctx context.Context, id primitive.ObjectID, status string, sentAt *time.Time) error {
-	fmt.Printf("Mock called with: id=%v, status=%v, sentAt=%v\n", id, status, sentAt)
-	args := s.Called(ctx, id, status, sentAt)
-	return args.Error(0)
+func (s *Store) UpdateMessageStatus(ctx context.Context, mt sender.MessageTransaction, status string, d mongostore.StatusDetails) (sender.MessageTransaction, error) {
+	args := s.Called(ctx, mt, status, d)
+	return args.Get(0).(sender.MessageTransaction), args.Error(1)
+}
+
+// UpdateMessage mocks update message
+func (s *Store) UpdateMessage(ctx context.Context, current sender.MessageTransaction, updated sender.MessageTransaction) (sender.MessageTransaction, error) {
+	args := s.Called(ctx, current, updated)
+	return args.Get(0).(sender.MessageTransaction), args.Error(1)
 }
 
 // Count mocks count
@@ -55,6 +82,61 @@ func (s *Store) InsertMany(ctx context.Context, mts []sender.MessageTransaction)
 	return args.Error(0)
 }
 
+// GetMessageStats mocks get message stats
+func (s *Store) GetMessageStats(ctx context.Context, since time.Time) (sender.MessageStats, error) {
+	args := s.Called(ctx, since)
+	return args.Get(0).(sender.MessageStats), args.Error(1)
+}
+
+// InsertAPIKey mocks insert api key
+func (s *Store) InsertAPIKey(ctx context.Context, k sender.APIKey) (sender.APIKey, error) {
+	args := s.Called(ctx, k)
+	return args.Get(0).(sender.APIKey), args.Error(1)
+}
+
+// GetAPIKeyByHash mocks get api key by hash
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (sender.APIKey, error) {
+	args := s.Called(ctx, hash)
+	return args.Get(0).(sender.APIKey), args.Error(1)
+}
+
+// GetAPIKeys mocks get api keys
+func (s *Store) GetAPIKeys(ctx context.Context) ([]sender.APIKey, error) {
+	args := s.Called(ctx)
+	return args.Get(0).([]sender.APIKey), args.Error(1)
+}
+
+// RevokeAPIKey mocks revoke api key
+func (s *Store) RevokeAPIKey(ctx context.Context, id primitive.ObjectID) (sender.APIKey, error) {
+	args := s.Called(ctx, id)
+	return args.Get(0).(sender.APIKey), args.Error(1)
+}
+
+// ReencryptMessages mocks re-encrypt messages
+func (s *Store) ReencryptMessages(ctx context.Context) (int64, int64, error) {
+	args := s.Called(ctx)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+// InsertSandboxMessage mocks insert sandbox message
+func (s *Store) InsertSandboxMessage(ctx context.Context, m sender.SandboxMessage) (sender.SandboxMessage, error) {
+	args := s.Called(ctx, m)
+	return args.Get(0).(sender.SandboxMessage), args.Error(1)
+}
+
+// GetSandboxMessages mocks get sandbox messages
+func (s *Store) GetSandboxMessages(ctx context.Context, recipient string, limit int64) ([]sender.SandboxMessage, error) {
+	args := s.Called(ctx, recipient, limit)
+	return args.Get(0).([]sender.SandboxMessage), args.Error(1)
+}
+
+// Ping mocks to ping method
+func (s *Store) Ping(ctx context.Context) error {
+	args := s.Called(ctx)
+
+	return args.Error(0)
+}
+
 // Close mocks to close method
 func (s *Store) Close() error {
 	args := s.Called()
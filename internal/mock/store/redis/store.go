@@ -2,6 +2,9 @@ package mockredisstore
 
 import (
 	"context"
+	"time"
+
+	"github.com/mkaykisiz/sender"
 	"github.com/stretchr/testify/mock"
 
 	redisstore "github.com/mkaykisiz/sender/internal/store/redis"
@@ -26,6 +29,50 @@ func (s *Store) CacheMessageID(ctx context.Context, id string) error {
 	return args.Error(0)
 }
 
+// GetMessageStats mocks get message stats method
+func (s *Store) GetMessageStats(ctx context.Context, windowMinutes int) (*sender.MessageStats, error) {
+	args := s.Called(ctx, windowMinutes)
+	return args.Get(0).(*sender.MessageStats), args.Error(1)
+}
+
+// CacheMessageStats mocks cache message stats method
+func (s *Store) CacheMessageStats(ctx context.Context, stats sender.MessageStats, ttl time.Duration) error {
+	args := s.Called(ctx, stats, ttl)
+	return args.Error(0)
+}
+
+// PublishMessageEvent mocks publish message event method
+func (s *Store) PublishMessageEvent(ctx context.Context, e sender.MessageEvent) error {
+	args := s.Called(ctx, e)
+	return args.Error(0)
+}
+
+// SubscribeMessageEvents mocks subscribe message events method
+func (s *Store) SubscribeMessageEvents(ctx context.Context) (<-chan sender.MessageEvent, error) {
+	args := s.Called(ctx)
+	events, _ := args.Get(0).(chan sender.MessageEvent)
+	return events, args.Error(1)
+}
+
+// ClaimNonce mocks claim nonce method
+func (s *Store) ClaimNonce(ctx context.Context, keyID, nonce string, ttl time.Duration) (bool, error) {
+	args := s.Called(ctx, keyID, nonce, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+// CountRequest mocks count request
+func (s *Store) CountRequest(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	args := s.Called(ctx, key, window)
+	return args.Get(0).(int64), args.Get(1).(time.Time), args.Error(2)
+}
+
+// Ping mocks to ping method
+func (s *Store) Ping(ctx context.Context) error {
+	args := s.Called(ctx)
+
+	return args.Error(0)
+}
+
 // Close mocks to close method
 func (s *Store) Close() error {
 	args := s.Called()
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/auth"
+	"github.com/mkaykisiz/sender/internal/clientip"
+)
+
+// ErrRateLimited is returned when a client exceeds its request limit
+var ErrRateLimited = errors.New("request rate limit exceeded")
+
+// CounterStore defines behaviors of store counting requests of clients across replicas
+type CounterStore interface {
+	CountRequest(ctx context.Context, key string, window time.Duration) (count int64, resetAt time.Time, err error)
+}
+
+// NewMiddleware returns endpoint middleware allowing each client limit requests per window. Clients are
+// identified by the hash of the api key the request presents, or by their ip address when it presents
+// none. It runs before authentication middleware, so requests with missing or invalid keys are limited
+// too. Requests are allowed when counting fails, an unavailable store does not take the api down.
+func NewMiddleware(cs CounterStore, limit int64, window time.Duration, l log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			key := clientKey(ctx)
+			if key == "" {
+				return next(ctx, request)
+			}
+
+			count, resetAt, err := cs.CountRequest(ctx, key, window)
+			if err != nil {
+				_ = l.Log("method", "rateLimit", "error", err.Error())
+				return next(ctx, request)
+			}
+
+			if count > limit {
+				retryAfter := time.Until(resetAt)
+				return nil, apierror.NewTooManyRequestsError(fmt.Errorf("%w, client: %s, limit: %d per %s", ErrRateLimited, key, limit, window), retryAfter)
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// NopMiddleware returns next as it is, it is used when rate limiting is disabled
+func NopMiddleware(next endpoint.Endpoint) endpoint.Endpoint {
+	return next
+}
+
+// clientKey returns rate limit key of the client of request, it is empty when the client is unknown
+func clientKey(ctx context.Context) string {
+	if key := auth.KeyFromContext(ctx); key != "" {
+		return "key:" + auth.HashKey(key)
+	}
+
+	if ip := clientip.FromContext(ctx); ip != "" {
+		return "ip:" + ip
+	}
+
+	return ""
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/auth"
+	"github.com/mkaykisiz/sender/internal/clientip"
+	mockredisstore "github.com/mkaykisiz/sender/internal/mock/store/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewMiddleware(t *testing.T) {
+	next := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return request, nil
+	}
+
+	newKeyContext := func(key string) context.Context {
+		r := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		r.Header.Set(auth.APIKeyHeader, key)
+		return auth.HTTPToContext(clientip.NewContext(context.Background(), "203.0.113.7"), r)
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		rs := mockredisstore.NewStore()
+		ctx := newKeyContext("snd_key")
+
+		rs.On("CountRequest", ctx, "key:"+auth.HashKey("snd_key"), time.Minute).Return(int64(10), time.Now().Add(time.Minute), nil).Once()
+
+		res, err := NewMiddleware(rs, 10, time.Minute, log.NewNopLogger())(next)(ctx, "request")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "request", res)
+		rs.AssertExpectations(t)
+	})
+
+	t.Run("limited by presented key before authentication", func(t *testing.T) {
+		rs := mockredisstore.NewStore()
+		ctx := newKeyContext("snd_invalid")
+
+		rs.On("CountRequest", ctx, "key:"+auth.HashKey("snd_invalid"), time.Minute).Return(int64(11), time.Now().Add(30*time.Second), nil).Once()
+
+		res, err := NewMiddleware(rs, 10, time.Minute, log.NewNopLogger())(next)(ctx, "request")
+
+		assert.Nil(t, res)
+		var apiErr *apierror.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apierror.CodeTooManyRequestsError, apiErr.Code)
+		assert.NotContains(t, err.Error(), "snd_invalid")
+		rs.AssertExpectations(t)
+	})
+
+	t.Run("limited by ip address", func(t *testing.T) {
+		rs := mockredisstore.NewStore()
+		ctx := clientip.NewContext(context.Background(), "203.0.113.7")
+
+		rs.On("CountRequest", ctx, "ip:203.0.113.7", time.Minute).Return(int64(11), time.Now().Add(30*time.Second), nil).Once()
+
+		res, err := NewMiddleware(rs, 10, time.Minute, log.NewNopLogger())(next)(ctx, "request")
+
+		assert.Nil(t, res)
+		var apiErr *apierror.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apierror.CodeTooManyRequestsError, apiErr.Code)
+		assert.ErrorIs(t, apiErr.BaseError, ErrRateLimited)
+		assert.InDelta(t, 30*time.Second, apiErr.RetryAfter, float64(time.Second))
+		rs.AssertExpectations(t)
+	})
+
+	t.Run("store error fails open", func(t *testing.T) {
+		rs := mockredisstore.NewStore()
+		ctx := clientip.NewContext(context.Background(), "203.0.113.7")
+
+		rs.On("CountRequest", ctx, "ip:203.0.113.7", time.Minute).Return(int64(0), time.Time{}, errors.New("redis is down")).Once()
+
+		res, err := NewMiddleware(rs, 10, time.Minute, log.NewNopLogger())(next)(ctx, "request")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "request", res)
+		rs.AssertExpectations(t)
+	})
+
+	t.Run("unknown client", func(t *testing.T) {
+		rs := mockredisstore.NewStore()
+
+		res, err := NewMiddleware(rs, 10, time.Minute, log.NewNopLogger())(next)(context.Background(), "request")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "request", res)
+		rs.AssertNotCalled(t, "CountRequest", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
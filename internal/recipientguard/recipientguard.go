@@ -0,0 +1,77 @@
+package recipientguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Guard allows sends only to recipients in an allow-list of numbers and prefixes, it keeps non-prod
+// environments from reaching real customers. A nil guard allows every recipient.
+type Guard struct {
+	environment string
+	numbers     map[string]struct{}
+	prefixes    []string
+}
+
+// NewGuard creates and returns guard of environment allowing comma separated numbers and recipients
+// starting with any of comma separated prefixes
+func NewGuard(environment, numbers, prefixes string) *Guard {
+	g := &Guard{environment: environment, numbers: make(map[string]struct{})}
+	for _, n := range split(numbers) {
+		g.numbers[n] = struct{}{}
+	}
+	g.prefixes = split(prefixes)
+
+	return g
+}
+
+// Allows reports whether a message can be sent to recipient
+func (g *Guard) Allows(recipient string) bool {
+	if g == nil {
+		return true
+	}
+
+	recipient = strings.TrimSpace(recipient)
+	if _, ok := g.numbers[recipient]; ok {
+		return true
+	}
+	for _, p := range g.prefixes {
+		if strings.HasPrefix(recipient, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reason returns why a recipient is not allowed
+func (g *Guard) Reason() string {
+	return fmt.Sprintf("recipient is not in the allow-list of %s environment", g.environment)
+}
+
+// Details returns environment and allow-list of guard, numbers are counted rather than listed since
+// they are personal data
+func (g *Guard) Details() map[string]interface{} {
+	return map[string]interface{}{
+		"environment":      g.environment,
+		"allowed_numbers":  len(g.numbers),
+		"allowed_prefixes": g.prefixes,
+	}
+}
+
+// IsEmpty reports whether guard allows no recipient at all
+func (g *Guard) IsEmpty() bool {
+	return len(g.numbers) == 0 && len(g.prefixes) == 0
+}
+
+// split returns trimmed non-empty values of comma separated s
+func split(s string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
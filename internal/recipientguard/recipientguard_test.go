@@ -0,0 +1,32 @@
+package recipientguard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard_Allows(t *testing.T) {
+	g := NewGuard("stg", " +905551234567 ,", "+90555000, +4470")
+
+	assert.True(t, g.Allows("+905551234567"))
+	assert.True(t, g.Allows("+905550001111"))
+	assert.True(t, g.Allows("+447000000000"))
+	assert.False(t, g.Allows("+905559876543"))
+	assert.False(t, g.Allows(""))
+	assert.False(t, g.IsEmpty())
+	assert.Equal(t, map[string]interface{}{
+		"environment":      "stg",
+		"allowed_numbers":  1,
+		"allowed_prefixes": []string{"+90555000", "+4470"},
+	}, g.Details())
+}
+
+func TestGuard_Empty(t *testing.T) {
+	var nilGuard *Guard
+	assert.True(t, nilGuard.Allows("+905551234567"))
+
+	g := NewGuard("dev", "", "")
+	assert.True(t, g.IsEmpty())
+	assert.False(t, g.Allows("+905551234567"))
+}
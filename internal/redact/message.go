@@ -0,0 +1,41 @@
+package redact
+
+import "github.com/mkaykisiz/sender"
+
+// Message returns mt with recipient masked and content hidden, values kept in history included
+func Message(mt sender.MessageTransaction) sender.MessageTransaction {
+	mt.Recipient = Recipient(mt.Recipient)
+	mt.Content = Redacted
+
+	if len(mt.History) > 0 {
+		history := make([]sender.MessageRevision, 0, len(mt.History))
+		for _, r := range mt.History {
+			r.Recipient = Recipient(r.Recipient)
+			r.Content = Redacted
+			history = append(history, r)
+		}
+		mt.History = history
+	}
+
+	return mt
+}
+
+// ResponseMessage returns m with recipient masked and content hidden
+func ResponseMessage(m sender.ResponseMessage) sender.ResponseMessage {
+	m.Recipient = Recipient(m.Recipient)
+	m.Content = Redacted
+	return m
+}
+
+// MessageEvent returns e with recipient masked
+func MessageEvent(e sender.MessageEvent) sender.MessageEvent {
+	e.Recipient = Recipient(e.Recipient)
+	return e
+}
+
+// SandboxMessage returns m with recipient masked and content hidden
+func SandboxMessage(m sender.SandboxMessage) sender.SandboxMessage {
+	m.Recipient = Recipient(m.Recipient)
+	m.Content = Redacted
+	return m
+}
@@ -0,0 +1,111 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-kit/log"
+)
+
+// masking settings
+const (
+	maskChar               = '*'
+	recipientVisibleSuffix = 4
+	contentVisiblePrefix   = 16
+)
+
+// Redacted replaces values which are hidden as a whole
+const Redacted = "[redacted]"
+
+// phoneNumberPattern matches international phone numbers written into free text such as error messages
+var phoneNumberPattern = regexp.MustCompile(`\+\d{7,15}`)
+
+// log keys whose values are masked
+var (
+	recipientKeys = map[string]bool{"recipient": true, "recipients": true, "phone": true}
+	contentKeys   = map[string]bool{"content": true}
+)
+
+// Recipient masks recipient keeping its leading plus sign and last digits, e.g. +********4567
+func Recipient(recipient string) string {
+	runes := []rune(recipient)
+
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case i == 0 && r == '+':
+			b.WriteRune(r)
+		case i >= len(runes)-recipientVisibleSuffix && len(runes) > 2*recipientVisibleSuffix:
+			b.WriteRune(r)
+		default:
+			b.WriteRune(maskChar)
+		}
+	}
+
+	return b.String()
+}
+
+// Content truncates content to its first characters and appends its length
+func Content(content string) string {
+	runes := []rune(content)
+	if len(runes) <= contentVisiblePrefix {
+		return content
+	}
+
+	return fmt.Sprintf("%s... (%d chars)", string(runes[:contentVisiblePrefix]), len(runes))
+}
+
+// Text masks phone numbers within free text
+func Text(text string) string {
+	return phoneNumberPattern.ReplaceAllStringFunc(text, Recipient)
+}
+
+// NewLogger returns logger masking recipient and truncating content values before they are passed to
+// next, phone numbers within other string values are masked as well
+func NewLogger(next log.Logger) log.Logger {
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		redacted := make([]interface{}, len(keyvals))
+		copy(redacted, keyvals)
+
+		for i := 1; i < len(redacted); i += 2 {
+			key, _ := redacted[i-1].(string)
+			redacted[i] = value(strings.ToLower(key), redacted[i])
+		}
+
+		return next.Log(redacted...)
+	})
+}
+
+// value returns redacted log value of key
+func value(key string, v interface{}) interface{} {
+	switch {
+	case recipientKeys[key]:
+		switch rv := v.(type) {
+		case string:
+			return Recipient(rv)
+		case []string:
+			masked := make([]string, 0, len(rv))
+			for _, r := range rv {
+				masked = append(masked, Recipient(r))
+			}
+			return masked
+		default:
+			return Redacted
+		}
+	case contentKeys[key]:
+		if s, ok := v.(string); ok {
+			return Content(s)
+		}
+		return Redacted
+	}
+
+	switch sv := v.(type) {
+	case string:
+		return Text(sv)
+	case error:
+		return Text(sv.Error())
+	default:
+		return v
+	}
+}
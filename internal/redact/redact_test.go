@@ -0,0 +1,43 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipient(t *testing.T) {
+	assert.Equal(t, "+********4567", Recipient("+905551234567"))
+	assert.Equal(t, "*******4567", Recipient("05551234567"))
+	assert.Equal(t, "****", Recipient("4567"))
+	assert.Equal(t, "", Recipient(""))
+}
+
+func TestContent(t *testing.T) {
+	assert.Equal(t, "short", Content("short"))
+	assert.Equal(t, "Your verificatio... (27 chars)", Content("Your verification code 1234"))
+}
+
+func TestNewLogger(t *testing.T) {
+	var got []interface{}
+	l := NewLogger(log.LoggerFunc(func(keyvals ...interface{}) error {
+		got = keyvals
+		return nil
+	}))
+
+	_ = l.Log(
+		"recipient", "+905551234567",
+		"content", "Your verification code 1234",
+		"error", errors.New("sending to +905551234567 failed"),
+		"id", 42,
+	)
+
+	assert.Equal(t, []interface{}{
+		"recipient", "+********4567",
+		"content", "Your verificatio... (27 chars)",
+		"error", "sending to +********4567 failed",
+		"id", 42,
+	}, got)
+}
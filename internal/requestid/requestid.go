@@ -0,0 +1,91 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Header is the header request ids are accepted and echoed in, MetadataKey is its grpc metadata key
+const (
+	Header      = "X-Request-ID"
+	MetadataKey = "x-request-id"
+)
+
+// maxLength is the maximum length of request ids given by clients, longer ids are replaced
+const maxLength = 128
+
+var requestIDKey = struct{ Key string }{"requestID"}
+
+// New returns a random request id
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Handler returns handler accepting request id of requests or generating one, the id is added to context
+// of requests passed to next and echoed in responses
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !isValid(id) {
+			id = New()
+		}
+
+		rw.Header().Set(Header, id)
+		next.ServeHTTP(rw, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// GRPCToContext adds request id in grpc metadata to context, one is generated when metadata has none.
+// The id is echoed in response header metadata.
+func GRPCToContext(ctx context.Context, md metadata.MD) context.Context {
+	id := ""
+	if values := md.Get(MetadataKey); len(values) > 0 {
+		id = values[0]
+	}
+	if !isValid(id) {
+		id = New()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+
+	return NewContext(ctx, id)
+}
+
+// NewContext returns context carrying request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// FromContext returns request id of context, it is empty when context has none
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// isValid reports whether request id given by a client can be used, ids are written into logs and
+// headers so only printable ascii characters are accepted
+func isValid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
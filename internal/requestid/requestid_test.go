@@ -0,0 +1,61 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHandler(t *testing.T) {
+	var got string
+	h := Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		if id != "" {
+			r.Header.Set(Header, id)
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		return rw
+	}
+
+	t.Run("given id", func(t *testing.T) {
+		rw := serve("req-123")
+
+		assert.Equal(t, "req-123", got)
+		assert.Equal(t, "req-123", rw.Header().Get(Header))
+	})
+
+	t.Run("generated id", func(t *testing.T) {
+		rw := serve("")
+
+		assert.Len(t, got, 32)
+		assert.Equal(t, got, rw.Header().Get(Header))
+	})
+
+	t.Run("invalid ids are replaced", func(t *testing.T) {
+		for _, id := range []string{"req 123", "req\n123", strings.Repeat("a", maxLength+1)} {
+			rw := serve(id)
+
+			assert.NotEqual(t, id, got)
+			assert.Len(t, got, 32)
+			assert.Equal(t, got, rw.Header().Get(Header))
+		}
+	})
+}
+
+func TestGRPCToContext(t *testing.T) {
+	ctx := GRPCToContext(context.Background(), metadata.Pairs(MetadataKey, "req-123"))
+	assert.Equal(t, "req-123", FromContext(ctx))
+
+	ctx = GRPCToContext(context.Background(), metadata.MD{})
+	assert.Len(t, FromContext(ctx), 32)
+}
@@ -2,6 +2,19 @@ package service
 
 const defaultLanguageCode = "tr"
 
+// defaults of retrieve sent messages
+const (
+	defaultRetrieveLimit     = 20
+	defaultRetrieveSortBy    = "created_at"
+	defaultRetrieveSortOrder = "desc"
+)
+
+// defaultSandboxLimit is the number of captured sandbox messages returned unless a limit is given
+const defaultSandboxLimit = 20
+
+// export content type chosen when neither format nor Accept header asks for one
+const csvContentType = "text/csv"
+
 // constants for service environments
 const (
 	local = "local"
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	mongostore "github.com/mkaykisiz/sender/internal/store/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// claimReleaseTimeout bounds releasing claims on drain, it is applied apart from the drain deadline
+// since claims are released after the deadline when the batch is cancelled
+const claimReleaseTimeout = 5 * time.Second
+
+// Drain stops worker and waits for the running batch to finish its sends and status writes. The batch
+// is cancelled when ctx is done first. Claimed messages whose send was not attempted or whose failure
+// could not be written are released back to pending afterwards so that they are sent by another worker,
+// SENT status of sent messages which could not be written is written again. The worker runs no batch once
+// it is drained, an error is returned when the batch is cancelled, claims cannot be released or sends
+// cannot be recorded.
+func (w *Worker) Drain(ctx context.Context) error {
+	w.Stop()
+
+	finished := make(chan struct{})
+	go func() {
+		// batch lock is kept so that no batch starts while stores are closed
+		w.batchMu.Lock()
+		close(finished)
+	}()
+
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		err = fmt.Errorf("batch is cancelled since it did not finish in time, %s", ctx.Err().Error())
+		w.mu.Lock()
+		if w.cancelBatch != nil {
+			w.cancelBatch()
+		}
+		w.mu.Unlock()
+		<-finished
+	}
+
+	rctx, cf := context.WithTimeout(context.Background(), claimReleaseTimeout)
+	defer cf()
+
+	released, failed := w.releaseClaims(rctx)
+	unrecorded := w.recordUnrecordedSends(rctx)
+	w.logWithLogger(ctx, nil, map[string]interface{}{
+		"method":     "Drain",
+		"msg":        "drained",
+		"released":   released,
+		"failed":     failed,
+		"unrecorded": unrecorded,
+	})
+	if failed > 0 && err == nil {
+		err = fmt.Errorf("releasing %d claimed messages failed", failed)
+	}
+	if unrecorded > 0 && err == nil {
+		err = fmt.Errorf("writing status of %d sent messages failed", unrecorded)
+	}
+
+	return err
+}
+
+// holdClaim keeps claimed message until its send is attempted
+func (w *Worker) holdClaim(mt sender.MessageTransaction) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.claims == nil {
+		w.claims = make(map[primitive.ObjectID]sender.MessageTransaction)
+	}
+	w.claims[mt.ID] = mt
+}
+
+// dropClaim forgets claim of message id
+func (w *Worker) dropClaim(id primitive.ObjectID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.claims, id)
+}
+
+// releaseClaims moves held claims back to pending and returns released and failed counts. A claim whose
+// message changed meanwhile is not released.
+func (w *Worker) releaseClaims(ctx context.Context) (released int, failed int) {
+	w.mu.Lock()
+	claims := w.claims
+	w.claims = nil
+	w.mu.Unlock()
+
+	for _, mt := range claims {
+		pending, err := w.ms.UpdateMessageStatus(ctx, mt, mongostore.STATUS_PENDING, mongostore.StatusDetails{})
+		var conflictErr *mongostore.StatusConflictError
+		if errors.As(err, &conflictErr) {
+			continue
+		}
+		if err != nil {
+			failed++
+			w.logWithLogger(ctx, err, map[string]interface{}{
+				"method": "releaseClaims",
+				"msg":    "error releasing claimed message",
+				"id":     mt.ID,
+			})
+			continue
+		}
+		released++
+		w.publishMessageEvent(ctx, pending)
+	}
+
+	return released, failed
+}
+
+// sentRecord is a send whose SENT status is written with details
+type sentRecord struct {
+	claimed sender.MessageTransaction
+	details mongostore.StatusDetails
+}
+
+// holdSent keeps send rec until its SENT status is written
+func (w *Worker) holdSent(rec sentRecord) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.unrecorded == nil {
+		w.unrecorded = make(map[primitive.ObjectID]sentRecord)
+	}
+	w.unrecorded[rec.claimed.ID] = rec
+}
+
+// isUnrecorded reports whether message id is sent and its SENT status is not written yet
+func (w *Worker) isUnrecorded(id primitive.ObjectID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.unrecorded[id]
+	return ok
+}
+
+// recordSent writes SENT status of send rec. The send is forgotten once status is written or message
+// changed meanwhile, it is kept when the write fails so that the write is retried.
+func (w *Worker) recordSent(ctx context.Context, rec sentRecord) (sender.MessageTransaction, error) {
+	sent, err := w.updateMessageStatus(ctx, rec.claimed, mongostore.STATUS_SENT, rec.details)
+	var conflictErr *mongostore.StatusConflictError
+	if err == nil || errors.As(err, &conflictErr) {
+		w.mu.Lock()
+		delete(w.unrecorded, rec.claimed.ID)
+		w.mu.Unlock()
+	}
+
+	return sent, err
+}
+
+// recordUnrecordedSends writes SENT status of sends whose status could not be written and returns count of
+// sends whose status is still not written
+func (w *Worker) recordUnrecordedSends(ctx context.Context) (failed int) {
+	w.mu.Lock()
+	recs := make([]sentRecord, 0, len(w.unrecorded))
+	for _, rec := range w.unrecorded {
+		recs = append(recs, rec)
+	}
+	w.mu.Unlock()
+
+	for _, rec := range recs {
+		sent, err := w.recordSent(ctx, rec)
+		var conflictErr *mongostore.StatusConflictError
+		if errors.As(err, &conflictErr) {
+			continue
+		}
+		if err != nil {
+			failed++
+			w.logWithLogger(ctx, err, map[string]interface{}{
+				"method": "recordUnrecordedSends",
+				"msg":    "error updating status of sent message",
+				"id":     rec.claimed.ID,
+			})
+			continue
+		}
+		w.publishMessageEvent(ctx, sent)
+	}
+
+	return failed
+}
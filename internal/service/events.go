@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/redact"
+	redisstore "github.com/mkaykisiz/sender/internal/store/redis"
+)
+
+// eventBufferSize is the number of events buffered per subscriber, events are dropped for subscribers falling behind
+const eventBufferSize = 64
+
+// newMessageEvent returns status change event of mt with its recipient masked, events pass through redis
+// and reach every subscriber so that they carry no personal data
+func newMessageEvent(mt sender.MessageTransaction) sender.MessageEvent {
+	return redact.MessageEvent(sender.NewMessageEvent(mt))
+}
+
+// messageEventFilter narrows message events of a subscriber, empty fields match every event. Recipient is
+// masked as recipients of events are, recipients sharing length and last digits match the same events.
+type messageEventFilter struct {
+	recipient string
+	campaign  string
+	statuses  []string
+}
+
+func (f messageEventFilter) matches(e sender.MessageEvent) bool {
+	if f.recipient != "" && f.recipient != e.Recipient {
+		return false
+	}
+
+	if f.campaign != "" && !contains(e.Tags, f.campaign) {
+		return false
+	}
+
+	if len(f.statuses) > 0 && !contains(f.statuses, e.Status) {
+		return false
+	}
+
+	return true
+}
+
+type eventSubscriber struct {
+	f      messageEventFilter
+	events chan sender.MessageEvent
+}
+
+// eventHub fans out message events of a single redis subscription to subscribers of this replica.
+// Redis subscription is opened with the first subscriber and closed with the last one.
+type eventHub struct {
+	rs          redisstore.Store
+	l           log.Logger
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	cancel      context.CancelFunc
+}
+
+func newEventHub(rs redisstore.Store, l log.Logger) *eventHub {
+	return &eventHub{
+		rs:          rs,
+		l:           l,
+		subscribers: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+// subscribe returns message events matching filter, returned channel is closed when ctx is done
+func (h *eventHub) subscribe(ctx context.Context, f messageEventFilter) (<-chan sender.MessageEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cancel == nil {
+		sctx, cancel := context.WithCancel(context.Background())
+		events, err := h.rs.SubscribeMessageEvents(sctx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		h.cancel = cancel
+		go h.dispatch(sctx, events)
+	}
+
+	sub := &eventSubscriber{f: f, events: make(chan sender.MessageEvent, eventBufferSize)}
+	h.subscribers[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		h.unsubscribe(sub)
+	}()
+
+	return sub.events, nil
+}
+
+func (h *eventHub) unsubscribe(sub *eventSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers, sub)
+	close(sub.events)
+
+	if len(h.subscribers) == 0 && h.cancel != nil {
+		h.cancel()
+		h.cancel = nil
+	}
+}
+
+func (h *eventHub) dispatch(ctx context.Context, events <-chan sender.MessageEvent) {
+	for e := range events {
+		h.mu.Lock()
+		// events still buffered in a closed subscription belong to no one
+		if ctx.Err() == nil {
+			for sub := range h.subscribers {
+				if !sub.f.matches(e) {
+					continue
+				}
+
+				select {
+				case sub.events <- e:
+				default:
+				}
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"github.com/mkaykisiz/sender"
+)
+
+// batchHistorySize is the number of recent batch summaries kept by worker
+const batchHistorySize = 20
+
+// batchHistory is a ring buffer of recent batch summaries, the oldest summary is overwritten once it
+// is full. It is not safe for concurrent use.
+type batchHistory struct {
+	summaries []sender.BatchSummary
+	next      int
+	full      bool
+}
+
+// newBatchHistory creates and returns history keeping size summaries
+func newBatchHistory(size int) *batchHistory {
+	return &batchHistory{summaries: make([]sender.BatchSummary, size)}
+}
+
+// add adds summary overwriting the oldest one when history is full
+func (h *batchHistory) add(s sender.BatchSummary) {
+	h.summaries[h.next] = s
+	h.next = (h.next + 1) % len(h.summaries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// recent returns up to n summaries, most recent first
+func (h *batchHistory) recent(n int) []sender.BatchSummary {
+	size := h.next
+	if h.full {
+		size = len(h.summaries)
+	}
+	if n <= 0 || n > size {
+		n = size
+	}
+
+	recent := make([]sender.BatchSummary, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, h.summaries[(h.next-i+len(h.summaries))%len(h.summaries)])
+	}
+
+	return recent
+}
@@ -2,6 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -9,8 +14,15 @@ import (
 
 	"github.com/mkaykisiz/sender"
 	envvars "github.com/mkaykisiz/sender/configs/env-vars"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/auth"
+	"github.com/mkaykisiz/sender/internal/client/sandboxclient"
+	"github.com/mkaykisiz/sender/internal/redact"
+	"github.com/mkaykisiz/sender/internal/requestid"
 	mongostore "github.com/mkaykisiz/sender/internal/store/mongo"
 	redisstore "github.com/mkaykisiz/sender/internal/store/redis"
+	"github.com/mkaykisiz/sender/internal/tracing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // compile-time proofs of service interface implementation
@@ -24,6 +36,7 @@ type Service struct {
 	envConfigs envvars.Configs
 	env        string
 	worker     *Worker
+	events     *eventHub
 }
 
 // NewService creates and returns service
@@ -35,6 +48,7 @@ func NewService(l log.Logger, ms mongostore.Store, rs redisstore.Store, envc env
 		envConfigs: envc,
 		env:        env,
 		worker:     worker,
+		events:     newEventHub(rs, l),
 	}
 }
 
@@ -51,6 +65,105 @@ func (s *Service) Health(_ context.Context, _ sender.HealthRequest) sender.Healt
 	return sender.HealthResponse{}
 }
 
+// Liveness checks process is alive
+// swagger:operation GET /health/live Sender livenessRequest
+// ---
+// summary: Liveness
+// description: checks process is alive, it fails when the worker is running but stalled so that the process is restarted
+// responses:
+//
+//	200:
+//	  $ref: "#/responses/livenessResponse"
+//	503:
+//	  $ref: "#/responses/livenessResponse"
+func (s *Service) Liveness(_ context.Context, _ sender.LivenessRequest) sender.LivenessResponse {
+	res := sender.LivenessResponse{
+		Components: map[string]sender.ComponentHealth{sender.ComponentWorker: s.worker.Health()},
+	}
+
+	if w := res.Components[sender.ComponentWorker]; w.Status == sender.ComponentStatusStalled {
+		res.Result = apierror.NewServiceUnavailableError(fmt.Errorf("worker is stalled, %s", w.Error))
+	}
+
+	return res
+}
+
+// Readiness checks service is ready to serve requests
+// swagger:operation GET /health/ready Sender readinessRequest
+// ---
+// summary: Readiness
+// description: checks mongo and redis are reachable and reports worker health, it fails while the service is shutting down or mongo or redis is unreachable
+// responses:
+//
+//	200:
+//	  $ref: "#/responses/readinessResponse"
+//	503:
+//	  $ref: "#/responses/readinessResponse"
+func (s *Service) Readiness(ctx context.Context, _ sender.ReadinessRequest) sender.ReadinessResponse {
+	checks := map[string]func(context.Context) error{
+		sender.ComponentMongo: s.ms.Ping,
+		sender.ComponentRedis: s.rs.Ping,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	components := make(map[string]sender.ComponentHealth, len(checks)+1)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+
+			h := checkComponent(ctx, check, s.envConfigs.HealthCheckTimeout)
+
+			mu.Lock()
+			components[name] = h
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	// worker is reported but does not fail readiness, a stalled worker is restarted by liveness probe
+	components[sender.ComponentWorker] = s.worker.Health()
+
+	res := sender.ReadinessResponse{Components: components}
+
+	var down []string
+	for name := range checks {
+		if components[name].Status != sender.ComponentStatusUp {
+			down = append(down, name)
+		}
+	}
+	sort.Strings(down)
+
+	switch {
+	case !sender.HEALTH_STATUS.GetStatus():
+		res.Result = apierror.NewServiceUnavailableError(sender.ErrServiceUnavailable)
+	case len(down) > 0:
+		res.Result = apierror.NewServiceUnavailableError(fmt.Errorf("unreachable components: %s", strings.Join(down, ", ")))
+	}
+
+	return res
+}
+
+// checkComponent runs check bounded by timeout and returns health of the component
+func checkComponent(ctx context.Context, check func(context.Context) error, timeout time.Duration) sender.ComponentHealth {
+	ctx, cf := context.WithTimeout(ctx, timeout)
+	defer cf()
+
+	begin := time.Now()
+	err := check(ctx)
+	h := sender.ComponentHealth{
+		Status:    sender.ComponentStatusUp,
+		LatencyMS: float64(time.Since(begin).Microseconds()) / 1000,
+	}
+	if err != nil {
+		h.Status = sender.ComponentStatusDown
+		h.Error = err.Error()
+	}
+
+	return h
+}
+
 // StartStopMessageSending starts or stops message sending based on action
 // swagger:operation POST /start-stop-sending Sender startStopMessageSendingRequest
 // ---
@@ -71,28 +184,792 @@ func (s *Service) StartStopMessageSending(_ context.Context, req sender.StartSto
 	return sender.StartStopMessageSendingResponse{}
 }
 
+// GetWorkerStatus returns state of the worker and its recent batches
+// swagger:operation GET /worker/status Sender getWorkerStatusRequest
+// ---
+// summary: GetWorkerStatus
+// description: returns whether the worker is running, its interval and batch size, last and next run times and outcome counts of recent batches, most recent first
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/getWorkerStatusResponse"
+func (s *Service) GetWorkerStatus(_ context.Context, req sender.GetWorkerStatusRequest) sender.GetWorkerStatusResponse {
+	status := s.worker.Status(req.Batches)
+	return sender.GetWorkerStatusResponse{Status: &status}
+}
+
+// RunWorkerBatch runs a worker batch right away or previews it
+// swagger:operation POST /worker/run Sender runWorkerBatchRequest
+// ---
+// summary: RunWorkerBatch
+// description: starts a worker batch right away without waiting for the ticker, its summary is added to worker status once it finishes. On dry run, messages the next batch would select are returned with the action it would take and the provider it would send with, nothing is sent and no status is changed.
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/runWorkerBatchResponse"
+func (s *Service) RunWorkerBatch(ctx context.Context, req sender.RunWorkerBatchRequest) sender.RunWorkerBatchResponse {
+	if !req.DryRun {
+		if !s.worker.RunNow() {
+			return sender.RunWorkerBatchResponse{Result: apierror.NewConflictError("a batch is already running", "worker-batch-running-conflict-error-message")}
+		}
+		return sender.RunWorkerBatchResponse{Status: "started"}
+	}
+
+	planned, err := s.worker.DryRun(ctx)
+	if err != nil {
+		return sender.RunWorkerBatchResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	if !canReadPII(ctx) {
+		for i := range planned {
+			planned[i].Message = redact.ResponseMessage(planned[i].Message)
+		}
+	}
+
+	return sender.RunWorkerBatchResponse{Status: "dry_run", Messages: planned}
+}
+
+// CreateMessage queues a new message
+// swagger:operation POST /create-message Sender createMessageRequest
+// ---
+// summary: CreateMessage
+// description: queues a new pending message, it is sent by the worker once send_at has passed
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/createMessageResponse"
+func (s *Service) CreateMessage(ctx context.Context, req sender.CreateMessageRequest) sender.CreateMessageResponse {
+	mt := sender.MessageTransaction{
+		ID:        primitive.NewObjectID(),
+		Content:   req.Content,
+		Recipient: req.Recipient,
+		Status:    mongostore.STATUS_PENDING,
+		Priority:  req.Priority,
+		Tags:      req.Tags,
+		SendAt:    req.SendAt,
+		CreatedAt: time.Now(),
+	}
+	mt.TraceID, mt.SpanID = tracing.IDs(ctx)
+	mt.RequestID = requestid.FromContext(ctx)
+
+	if !mt.IsValid() {
+		err := errors.New("message is invalid")
+		apiErr := apierror.NewValidationError(err.Error(), "")
+		apiErr.BaseError = err
+		return sender.CreateMessageResponse{Result: apiErr}
+	}
+
+	if err := s.ms.InsertMany(ctx, []sender.MessageTransaction{mt}); err != nil {
+		return sender.CreateMessageResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	s.publishMessageEvent(ctx, mt)
+
+	mt = maskMessage(ctx, mt)
+	return sender.CreateMessageResponse{Message: &mt}
+}
+
 // RetrieveSentMessages retrieves sent messages
 // swagger:operation GET /retrieve-sent-messages Sender retrieveSentMessagesRequest
 // ---
 // summary: RetrieveSentMessages
-// description: retrieves sent messages
+// description: retrieves sent messages page by page, next_cursor of the response is passed as cursor to get the next page
 // responses:
 //
 //	  200:
 //		  $ref: "#/responses/retrieveSentMessagesResponse"
-func (s *Service) RetrieveSentMessages(ctx context.Context, _ sender.RetrieveSentMessagesRequest) sender.RetrieveSentMessagesResponse {
-	messages, err := s.ms.GetMessages(ctx, mongostore.MessageFilter{Status: []string{mongostore.STATUS_SENT}}, mongostore.MessageOptions{})
+func (s *Service) RetrieveSentMessages(ctx context.Context, req sender.RetrieveSentMessagesRequest) sender.RetrieveSentMessagesResponse {
+	f := mongostore.MessageFilter{
+		Status:      req.Status,
+		Recipient:   req.Recipient,
+		Tags:        req.Tags,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		SentFrom:    req.SentFrom,
+		SentTo:      req.SentTo,
+	}
+	if len(f.Status) == 0 {
+		f.Status = []string{mongostore.STATUS_SENT}
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultRetrieveLimit
+	}
+
+	o := mongostore.MessageOptions{
+		Limit:    limit + 1, // one more to find out whether there is a next page
+		SortBy:   req.SortBy,
+		SortDesc: req.SortOrder == "desc",
+	}
+	if o.SortBy == "" {
+		o.SortBy = defaultRetrieveSortBy
+	}
+	if req.SortOrder == "" {
+		o.SortDesc = defaultRetrieveSortOrder == "desc"
+	}
+
+	if req.Cursor != "" {
+		c, err := mongostore.DecodeMessageCursor(req.Cursor)
+		if err != nil {
+			apiErr := apierror.NewValidationError(err.Error(), "")
+			apiErr.BaseError = err
+			return sender.RetrieveSentMessagesResponse{Result: apiErr}
+		}
+		o.After = c
+	}
+
+	messages, err := s.ms.GetMessages(ctx, f, o)
+	if err != nil {
+		return sender.RetrieveSentMessagesResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	res := sender.RetrieveSentMessagesResponse{Messages: make([]sender.ResponseMessage, 0, len(messages))}
+	if int64(len(messages)) > limit {
+		messages = messages[:limit]
+		res.NextCursor = mongostore.NewMessageCursor(messages[len(messages)-1], o.SortBy).Encode()
+	}
+
+	mask := !canReadPII(ctx)
+	for _, mt := range messages {
+		m := sender.NewResponseMessage(mt)
+		if mask {
+			m = redact.ResponseMessage(m)
+		}
+		res.Messages = append(res.Messages, m)
+	}
+
+	return res
+}
+
+// CancelMessage cancels a message which is not sent yet
+// swagger:operation POST /cancel-message Sender cancelMessageRequest
+// ---
+// summary: CancelMessage
+// description: cancels a pending or failed message by id, a processing message is cancelled once its claim expired
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/cancelMessageResponse"
+func (s *Service) CancelMessage(ctx context.Context, req sender.CancelMessageRequest) sender.CancelMessageResponse {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		apiErr := apierror.NewValidationError(err.Error(), "")
+		apiErr.BaseError = err
+		return sender.CancelMessageResponse{Result: apiErr}
+	}
+
+	mt, err := s.ms.GetMessage(ctx, id)
+	if errors.Is(err, mongostore.ErrMessageNotFound) {
+		apiErr := apierror.NewNotFoundError(err.Error(), "message-not-found-error-message")
+		apiErr.BaseError = err
+		return sender.CancelMessageResponse{Result: apiErr}
+	}
+	if err != nil {
+		return sender.CancelMessageResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	cancelled, err := s.ms.UpdateMessageStatus(ctx, mt, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{})
+	if err != nil {
+		return sender.CancelMessageResponse{Result: newConflictError(err)}
+	}
+
+	s.publishMessageEvent(ctx, cancelled)
+
+	cancelled = maskMessage(ctx, cancelled)
+	return sender.CancelMessageResponse{Message: &cancelled}
+}
+
+// CancelMessages cancels messages matching filter which are not sent yet
+// swagger:operation POST /cancel-messages Sender cancelMessagesRequest
+// ---
+// summary: CancelMessages
+// description: cancels pending and failed messages matching filter, messages claimed by a running worker are skipped and reported while messages whose claim expired are cancelled. Conflict is returned when every matching message is claimed.
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/cancelMessagesResponse"
+func (s *Service) CancelMessages(ctx context.Context, req sender.CancelMessagesRequest) sender.CancelMessagesResponse {
+	f := mongostore.MessageFilter{
+		Recipient:   req.Recipient,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+	}
+	for _, hex := range req.IDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			apiErr := apierror.NewValidationError(err.Error(), "")
+			apiErr.BaseError = err
+			return sender.CancelMessagesResponse{Result: apiErr}
+		}
+		f.IDs = append(f.IDs, id)
+	}
+
+	if f.IsEmpty() {
+		apiErr := apierror.NewBadRequestError("cancel messages filter is empty", "cancel-messages-empty-filter-error-message")
+		apiErr.BaseError = errors.New(apiErr.Message)
+		return sender.CancelMessagesResponse{Result: apiErr}
+	}
+
+	// claimed messages are selected since expired claims are cancelled, running claims are reported as skipped
+	f.Status = mongostore.StatusesTransitionableTo(mongostore.STATUS_CANCELLED)
+
+	res := sender.CancelMessagesResponse{SkippedIDs: []string{}}
+	err := s.ms.StreamMessages(ctx, f, mongostore.MessageOptions{}, func(mt sender.MessageTransaction) error {
+		if mongostore.RequiresExpiredClaim(mt.Status, mongostore.STATUS_CANCELLED) && !mongostore.IsClaimExpired(mt, time.Now()) {
+			res.SkippedIDs = append(res.SkippedIDs, mt.ID.Hex())
+			return nil
+		}
+
+		cancelled, err := s.ms.UpdateMessageStatus(ctx, mt, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{})
+		var conflictErr *mongostore.StatusConflictError
+		if errors.As(err, &conflictErr) {
+			// message was claimed or changed after it was read
+			res.SkippedIDs = append(res.SkippedIDs, mt.ID.Hex())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		res.CancelledCount++
+		s.publishMessageEvent(ctx, cancelled)
+		return nil
+	})
+	res.SkippedCount = int64(len(res.SkippedIDs))
+	if err != nil {
+		res.Result = apierror.NewInternalServerError(err)
+		return res
+	}
+
+	if res.CancelledCount == 0 && res.SkippedCount > 0 {
+		err := fmt.Errorf("%d matching messages are claimed by a worker", res.SkippedCount)
+		apiErr := apierror.NewConflictError(err.Error(), "cancel-messages-claimed-conflict-error-message")
+		apiErr.BaseError = err
+		res.Result = apiErr
+	}
+
+	return res
+}
+
+// RequeueMessages moves failed and invalid messages matching filter back to pending
+// swagger:operation POST /requeue-messages Sender requeueMessagesRequest
+// ---
+// summary: RequeueMessages
+// description: moves dead letters, failed and invalid messages matching filter, back to pending so that the worker sends them again
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/requeueMessagesResponse"
+func (s *Service) RequeueMessages(ctx context.Context, req sender.RequeueMessagesRequest) sender.RequeueMessagesResponse {
+	f := mongostore.MessageFilter{
+		Status:      req.Status,
+		Recipient:   req.Recipient,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+	}
+	for _, hex := range req.IDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			apiErr := apierror.NewValidationError(err.Error(), "")
+			apiErr.BaseError = err
+			return sender.RequeueMessagesResponse{Result: apiErr}
+		}
+		f.IDs = append(f.IDs, id)
+	}
+	// only dead letters are requeued, other statuses would release claims of running workers
+	for _, status := range req.Status {
+		if status != mongostore.STATUS_FAILED && status != mongostore.STATUS_INVALID {
+			err := fmt.Errorf("status %q can not be requeued", status)
+			apiErr := apierror.NewValidationError(err.Error(), "")
+			apiErr.BaseError = err
+			return sender.RequeueMessagesResponse{Result: apiErr}
+		}
+	}
+
+	if f.IsEmpty() {
+		apiErr := apierror.NewBadRequestError("requeue messages filter is empty", "requeue-messages-empty-filter-error-message")
+		apiErr.BaseError = errors.New(apiErr.Message)
+		return sender.RequeueMessagesResponse{Result: apiErr}
+	}
+	if len(f.Status) == 0 {
+		f.Status = []string{mongostore.STATUS_FAILED, mongostore.STATUS_INVALID}
+	}
+
+	var count int64
+	err := s.ms.StreamMessages(ctx, f, mongostore.MessageOptions{}, func(mt sender.MessageTransaction) error {
+		requeued, err := s.ms.UpdateMessageStatus(ctx, mt, mongostore.STATUS_PENDING, mongostore.StatusDetails{Requeue: true})
+		var conflictErr *mongostore.StatusConflictError
+		if errors.As(err, &conflictErr) {
+			// message changed after it was read, it is no longer a dead letter
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		count++
+		s.publishMessageEvent(ctx, requeued)
+		return nil
+	})
+	if err != nil {
+		return sender.RequeueMessagesResponse{Result: apierror.NewInternalServerError(err), RequeuedCount: count}
+	}
+
+	return sender.RequeueMessagesResponse{RequeuedCount: count}
+}
+
+// UpdateMessage edits or reschedules a message which is not sent yet
+// swagger:operation POST /update-message Sender updateMessageRequest
+// ---
+// summary: UpdateMessage
+// description: changes content, recipient, send time or priority of a pending or failed message, previous values are kept in message history
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/updateMessageResponse"
+func (s *Service) UpdateMessage(ctx context.Context, req sender.UpdateMessageRequest) sender.UpdateMessageResponse {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		apiErr := apierror.NewValidationError(err.Error(), "")
+		apiErr.BaseError = err
+		return sender.UpdateMessageResponse{Result: apiErr}
+	}
+
+	mt, err := s.ms.GetMessage(ctx, id)
+	if errors.Is(err, mongostore.ErrMessageNotFound) {
+		apiErr := apierror.NewNotFoundError(err.Error(), "message-not-found-error-message")
+		apiErr.BaseError = err
+		return sender.UpdateMessageResponse{Result: apiErr}
+	}
+	if err != nil {
+		return sender.UpdateMessageResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	if req.Version != nil && *req.Version != mt.Version {
+		return sender.UpdateMessageResponse{Result: newConflictError(&mongostore.EditConflictError{ID: mt.ID, Status: mt.Status, Version: *req.Version, LeaseUntil: mt.LeaseUntil})}
+	}
+
+	updated := mt
+	if req.Content != nil {
+		updated.Content = *req.Content
+	}
+	if req.Recipient != nil {
+		updated.Recipient = *req.Recipient
+	}
+	if req.SendAt != nil {
+		updated.SendAt = req.SendAt
+	}
+	if req.SendNow {
+		updated.SendAt = nil
+	}
+	if req.Priority != nil {
+		updated.Priority = *req.Priority
+	}
+
+	if !updated.IsValid() {
+		err := errors.New("updated message is invalid")
+		apiErr := apierror.NewValidationError(err.Error(), "")
+		apiErr.BaseError = err
+		return sender.UpdateMessageResponse{Result: apiErr}
+	}
+
+	res, err := s.ms.UpdateMessage(ctx, mt, updated)
+	if err != nil {
+		return sender.UpdateMessageResponse{Result: newConflictError(err)}
+	}
+
+	res = maskMessage(ctx, res)
+	return sender.UpdateMessageResponse{Message: &res}
+}
+
+// GetMessage returns message details
+// swagger:operation GET /messages/{id} Sender getMessageRequest
+// ---
+// summary: GetMessage
+// description: returns message with its status, timestamps, attempts and provider info
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/getMessageResponse"
+func (s *Service) GetMessage(ctx context.Context, req sender.GetMessageRequest) sender.GetMessageResponse {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		apiErr := apierror.NewValidationError(err.Error(), "")
+		apiErr.BaseError = err
+		return sender.GetMessageResponse{Result: apiErr}
+	}
+
+	mt, err := s.ms.GetMessage(ctx, id)
+	if errors.Is(err, mongostore.ErrMessageNotFound) {
+		apiErr := apierror.NewNotFoundError(err.Error(), "message-not-found-error-message")
+		apiErr.BaseError = err
+		return sender.GetMessageResponse{Result: apiErr}
+	}
+	if err != nil {
+		return sender.GetMessageResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	mt = maskMessage(ctx, mt)
+	return sender.GetMessageResponse{Message: &mt}
+}
+
+// GetStats returns queue and delivery statistics
+// swagger:operation GET /stats Sender getStatsRequest
+// ---
+// summary: GetStats
+// description: returns counts per status, oldest pending age, and throughput, failure rate per provider and send latency percentiles of the last window_minutes, results are cached briefly
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/getStatsResponse"
+func (s *Service) GetStats(ctx context.Context, req sender.GetStatsRequest) sender.GetStatsResponse {
+	window := req.WindowMinutes
+	if window == 0 {
+		window = s.envConfigs.StatsWindowMinutes
+	}
+
+	cached, err := s.rs.GetMessageStats(ctx, window)
+	if err != nil {
+		s.log(ctx, err, map[string]interface{}{"method": "GetStats"})
+	}
+	if cached != nil {
+		return sender.GetStatsResponse{Stats: cached}
+	}
+
+	now := time.Now()
+	stats, err := s.ms.GetMessageStats(ctx, now.Add(-time.Duration(window)*time.Minute))
+	if err != nil {
+		return sender.GetStatsResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	stats.WindowMinutes = window
+	stats.GeneratedAt = now
+	stats.ThroughputPerMinute = float64(stats.SentInWindow) / float64(window)
+	if stats.OldestPendingCreatedAt != nil {
+		stats.OldestPendingAgeSeconds = now.Sub(*stats.OldestPendingCreatedAt).Seconds()
+	}
+
+	if err := s.rs.CacheMessageStats(ctx, stats, s.envConfigs.StatsCacheTTL); err != nil {
+		s.log(ctx, err, map[string]interface{}{"method": "GetStats"})
+	}
+
+	return sender.GetStatsResponse{Stats: &stats}
+}
+
+// ExportMessages exports messages matching filters as CSV or NDJSON
+// swagger:operation GET /export-messages Sender exportMessagesRequest
+// ---
+// summary: ExportMessages
+// description: streams every message matching filters ordered by created_at, format query parameter takes precedence over Accept header, NDJSON is the default
+// produces:
+// - text/csv
+// - application/x-ndjson
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/exportMessagesResponse"
+func (s *Service) ExportMessages(ctx context.Context, req sender.ExportMessagesRequest) sender.ExportMessagesResponse {
+	f := mongostore.MessageFilter{
+		Status:      req.Status,
+		Recipient:   req.Recipient,
+		Tags:        req.Tags,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		SentFrom:    req.SentFrom,
+		SentTo:      req.SentTo,
+	}
+	if len(f.Status) == 0 {
+		f.Status = []string{mongostore.STATUS_SENT}
+	}
+
+	o := mongostore.MessageOptions{SortBy: mongostore.SortByCreatedAt}
+	mask := !canReadPII(ctx)
+
+	return sender.ExportMessagesResponse{
+		Format: exportFormat(req),
+		Messages: func(fn func(sender.ResponseMessage) error) error {
+			err := s.ms.StreamMessages(ctx, f, o, func(mt sender.MessageTransaction) error {
+				m := sender.NewResponseMessage(mt)
+				if mask {
+					m = redact.ResponseMessage(m)
+				}
+				return fn(m)
+			})
+			if err != nil {
+				s.log(ctx, err, map[string]interface{}{"method": "ExportMessages"})
+			}
+			return err
+		},
+	}
+}
+
+// StreamMessageEvents streams message status changes as server-sent events
+// swagger:operation GET /message-events Sender streamMessageEventsRequest
+// ---
+// summary: StreamMessageEvents
+// description: pushes status changes of messages as server-sent events until the client disconnects, events of every replica are received
+// produces:
+// - text/event-stream
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/streamMessageEventsResponse"
+func (s *Service) StreamMessageEvents(ctx context.Context, req sender.StreamMessageEventsRequest) sender.StreamMessageEventsResponse {
+	f := messageEventFilter{
+		campaign: req.Campaign,
+		statuses: req.Status,
+	}
+	if req.Recipient != "" {
+		f.recipient = redact.Recipient(req.Recipient)
+	}
+
+	events, err := s.events.subscribe(ctx, f)
 	if err != nil {
-		s.log(ctx, err, map[string]interface{}{"method": "RetrieveSentMessages"})
-		return sender.RetrieveSentMessagesResponse{}
+		return sender.StreamMessageEventsResponse{Result: apierror.NewInternalServerError(err)}
 	}
-	return sender.RetrieveSentMessagesResponse{Messages: messages}
+
+	return sender.StreamMessageEventsResponse{Events: events, MaskPII: !canReadPII(ctx)}
+}
+
+// CreateAPIKey creates a new api key
+// swagger:operation POST /create-api-key Sender createAPIKeyRequest
+// ---
+// summary: CreateAPIKey
+// description: creates a new api key granted scopes of its role and given scopes, the key is returned only in this response and stored hashed. A key can not grant scopes it is not granted.
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/createAPIKeyResponse"
+func (s *Service) CreateAPIKey(ctx context.Context, req sender.CreateAPIKeyRequest) sender.CreateAPIKeyResponse {
+	key, err := auth.GenerateKey()
+	if err != nil {
+		return sender.CreateAPIKeyResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	scopes := apiKeyScopes(req)
+
+	var createdBy string
+	if id, ok := auth.FromContext(ctx); ok {
+		if !id.HasScopes(scopes) {
+			apiErr := apierror.NewForbiddenError("api key can not grant scopes it is not granted", "scope-escalation-forbidden-error-message")
+			apiErr.BaseError = errors.New(apiErr.Message)
+			return sender.CreateAPIKeyResponse{Result: apiErr}
+		}
+		createdBy = id.Name
+	}
+
+	k, err := s.ms.InsertAPIKey(ctx, auth.NewAPIKey(key, req.Name, scopes, createdBy))
+	if err != nil {
+		return sender.CreateAPIKeyResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	return sender.CreateAPIKeyResponse{APIKey: &k, Key: key}
+}
+
+// ListAPIKeys lists api keys
+// swagger:operation GET /api-keys Sender listAPIKeysRequest
+// ---
+// summary: ListAPIKeys
+// description: lists active and revoked api keys without the keys themselves
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/listAPIKeysResponse"
+func (s *Service) ListAPIKeys(ctx context.Context, _ sender.ListAPIKeysRequest) sender.ListAPIKeysResponse {
+	ks, err := s.ms.GetAPIKeys(ctx)
+	if err != nil {
+		return sender.ListAPIKeysResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	return sender.ListAPIKeysResponse{APIKeys: ks}
+}
+
+// RevokeAPIKey revokes api key by id
+// swagger:operation POST /revoke-api-key Sender revokeAPIKeyRequest
+// ---
+// summary: RevokeAPIKey
+// description: revokes api key by id, requests with a revoked key are rejected
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/revokeAPIKeyResponse"
+func (s *Service) RevokeAPIKey(ctx context.Context, req sender.RevokeAPIKeyRequest) sender.RevokeAPIKeyResponse {
+	id, err := primitive.ObjectIDFromHex(req.ID)
+	if err != nil {
+		apiErr := apierror.NewValidationError(err.Error(), "")
+		apiErr.BaseError = err
+		return sender.RevokeAPIKeyResponse{Result: apiErr}
+	}
+
+	k, err := s.ms.RevokeAPIKey(ctx, id)
+	if errors.Is(err, mongostore.ErrAPIKeyNotFound) {
+		apiErr := apierror.NewNotFoundError(err.Error(), "api-key-not-found-error-message")
+		apiErr.BaseError = err
+		return sender.RevokeAPIKeyResponse{Result: apiErr}
+	}
+	if err != nil {
+		return sender.RevokeAPIKeyResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	return sender.RevokeAPIKeyResponse{APIKey: &k}
+}
+
+// ReceiveDeliveryReceipt marks sent message as delivered by provider's delivery receipt
+// swagger:operation POST /delivery-receipts Sender deliveryReceiptRequest
+// ---
+// summary: ReceiveDeliveryReceipt
+// description: provider callback marking a sent message as delivered. Requests are authenticated by hmac-sha256 signature headers instead of an api key, a signed request is accepted only once. Receipts of already delivered messages are accepted again.
+// security: []
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/deliveryReceiptResponse"
+func (s *Service) ReceiveDeliveryReceipt(ctx context.Context, req sender.DeliveryReceiptRequest) sender.DeliveryReceiptResponse {
+	mt, err := s.ms.GetMessageByProviderMessageID(ctx, req.ProviderMessageID)
+	if errors.Is(err, mongostore.ErrMessageNotFound) {
+		apiErr := apierror.NewNotFoundError(err.Error(), "message-not-found-error-message")
+		apiErr.BaseError = err
+		return sender.DeliveryReceiptResponse{Result: apiErr}
+	}
+	if err != nil {
+		return sender.DeliveryReceiptResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	// providers retry receipts until they are acknowledged
+	if mt.Status == mongostore.STATUS_DELIVERED {
+		return sender.DeliveryReceiptResponse{}
+	}
+
+	deliveredAt := req.DeliveredAt
+	if deliveredAt == nil {
+		now := time.Now()
+		deliveredAt = &now
+	}
+
+	delivered, err := s.ms.UpdateMessageStatus(ctx, mt, mongostore.STATUS_DELIVERED, mongostore.StatusDetails{DeliveredAt: deliveredAt})
+	if err != nil {
+		return sender.DeliveryReceiptResponse{Result: newConflictError(err)}
+	}
+
+	s.publishMessageEvent(ctx, delivered)
+
+	return sender.DeliveryReceiptResponse{}
+}
+
+// GetSandboxMessages returns messages captured by sandbox provider
+// swagger:operation GET /sandbox/messages Sender getSandboxMessagesRequest
+// ---
+// summary: GetSandboxMessages
+// description: returns messages the sandbox provider captured instead of delivering, most recent first. It is only available when the service sends with the sandbox provider, which is never the case in prod.
+// responses:
+//
+//	  200:
+//		  $ref: "#/responses/getSandboxMessagesResponse"
+func (s *Service) GetSandboxMessages(ctx context.Context, req sender.GetSandboxMessagesRequest) sender.GetSandboxMessagesResponse {
+	if s.worker.sender.Name() != sandboxclient.Provider {
+		return sender.GetSandboxMessagesResponse{Result: apierror.NewNotFoundError("sandbox provider is not enabled", "sandbox-disabled-not-found-error-message")}
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultSandboxLimit
+	}
+
+	ms, err := s.ms.GetSandboxMessages(ctx, req.Recipient, limit)
+	if err != nil {
+		return sender.GetSandboxMessagesResponse{Result: apierror.NewInternalServerError(err)}
+	}
+
+	if !canReadPII(ctx) {
+		for i, m := range ms {
+			ms[i] = redact.SandboxMessage(m)
+		}
+	}
+
+	return sender.GetSandboxMessagesResponse{Messages: ms}
 }
 
 func (s *Service) StartSendMessage(count int, delay time.Duration) {
 	s.worker.Start()
 }
 
+// newConflictError maps message update error to api error, claims whose lease expired are told apart
+// from claims of running workers
+func newConflictError(err error) *apierror.APIError {
+	var mt sender.MessageTransaction
+	var statusConflictErr *mongostore.StatusConflictError
+	var editConflictErr *mongostore.EditConflictError
+	switch {
+	case errors.As(err, &statusConflictErr):
+		mt = sender.MessageTransaction{Status: statusConflictErr.From, LeaseUntil: statusConflictErr.LeaseUntil}
+	case errors.As(err, &editConflictErr):
+		mt = sender.MessageTransaction{Status: editConflictErr.Status, LeaseUntil: editConflictErr.LeaseUntil}
+	default:
+		return apierror.NewInternalServerError(err)
+	}
+
+	messageLocalizerKey := "message-status-conflict-error-message"
+	switch {
+	case mongostore.IsClaimExpired(mt, time.Now()):
+		messageLocalizerKey = "message-claim-expired-conflict-error-message"
+	case mt.Status == mongostore.STATUS_PROCESSING:
+		messageLocalizerKey = "message-claimed-conflict-error-message"
+	}
+
+	apiErr := apierror.NewConflictError(err.Error(), messageLocalizerKey)
+	apiErr.BaseError = err
+	return apiErr
+}
+
+// apiKeyScopes returns scopes of requested role and scopes without duplicates
+func apiKeyScopes(req sender.CreateAPIKeyRequest) []string {
+	scopes := make([]string, 0, len(sender.Scopes))
+	for _, requested := range [][]string{sender.RoleScopes[req.Role], req.Scopes} {
+		for _, scope := range requested {
+			if !contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// exportFormat returns requested export format, format parameter takes precedence over Accept header
+func exportFormat(req sender.ExportMessagesRequest) string {
+	if req.Format != "" {
+		return req.Format
+	}
+
+	if strings.Contains(req.Accept, csvContentType) {
+		return sender.ExportFormatCSV
+	}
+
+	return sender.ExportFormatNDJSON
+}
+
+// canReadPII reports whether caller is granted to see recipients and contents, every caller is granted
+// when authentication is disabled
+func canReadPII(ctx context.Context) bool {
+	id, ok := auth.FromContext(ctx)
+	return !ok || id.HasScope(sender.ScopePIIRead)
+}
+
+// maskMessage returns mt masked unless caller can read personal data
+func maskMessage(ctx context.Context, mt sender.MessageTransaction) sender.MessageTransaction {
+	if canReadPII(ctx) {
+		return mt
+	}
+	return redact.Message(mt)
+}
+
+// publishMessageEvent publishes status change of message, events are best effort so failures are only logged
+func (s *Service) publishMessageEvent(ctx context.Context, mt sender.MessageTransaction) {
+	if err := s.rs.PublishMessageEvent(ctx, newMessageEvent(mt)); err != nil {
+		s.log(ctx, err, map[string]interface{}{"method": "publishMessageEvent", "id": mt.ID})
+	}
+}
+
 func (s *Service) log(ctx context.Context, err error, additionalParams map[string]interface{}) {
 	logParams := make([]interface{}, 0, 2+len(additionalParams)*2)
 
@@ -3,12 +3,17 @@ package service
 import (
 	"context"
 	"errors"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/mkaykisiz/sender"
 	envvars "github.com/mkaykisiz/sender/configs/env-vars"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/auth"
+	"github.com/mkaykisiz/sender/internal/client/sandboxclient"
 	mockmessagehook "github.com/mkaykisiz/sender/internal/mock/client/messagehook"
 	mockmongostore "github.com/mkaykisiz/sender/internal/mock/store/mongo"
 	mockredisstore "github.com/mkaykisiz/sender/internal/mock/store/redis"
@@ -16,6 +21,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestService_RetrieveSentMessages(t *testing.T) {
@@ -28,27 +34,84 @@ func TestService_RetrieveSentMessages(t *testing.T) {
 	svc := NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
 
 	ctx := context.Background()
+	defaultFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_SENT}}
+	defaultOptions := mongostore.MessageOptions{Limit: defaultRetrieveLimit + 1, SortBy: mongostore.SortByCreatedAt, SortDesc: true}
 
 	t.Run("success", func(t *testing.T) {
 		expectedMessages := []sender.MessageTransaction{
 			{ID: primitive.NewObjectID(), Content: "test", Status: "sent"},
 		}
 
-		mockMongoStore.On("GetMessages", ctx, mongostore.MessageFilter{Status: []string{mongostore.STATUS_SENT}}, mongostore.MessageOptions{}).Return(expectedMessages, nil).Once()
+		mockMongoStore.On("GetMessages", ctx, defaultFilter, defaultOptions).Return(expectedMessages, nil).Once()
 
 		resp := svc.RetrieveSentMessages(ctx, sender.RetrieveSentMessagesRequest{})
 
 		assert.Nil(t, resp.Result)
-		assert.Equal(t, expectedMessages, resp.Messages)
+		assert.Equal(t, []sender.ResponseMessage{sender.NewResponseMessage(expectedMessages[0])}, resp.Messages)
+		assert.Empty(t, resp.NextCursor)
 		mockMongoStore.AssertExpectations(t)
 	})
 
+	t.Run("next page", func(t *testing.T) {
+		createdAt := time.Now()
+		expectedMessages := []sender.MessageTransaction{
+			{ID: primitive.NewObjectID(), Content: "test 1", Status: "delivered", CreatedAt: createdAt},
+			{ID: primitive.NewObjectID(), Content: "test 2", Status: "delivered", CreatedAt: createdAt.Add(time.Second)},
+		}
+		f := mongostore.MessageFilter{Status: []string{mongostore.STATUS_DELIVERED}, Recipient: "+905551234567", Tags: []string{"campaign"}}
+		o := mongostore.MessageOptions{Limit: 2, SortBy: mongostore.SortByCreatedAt}
+
+		mockMongoStore.On("GetMessages", ctx, f, o).Return(expectedMessages, nil).Once()
+
+		resp := svc.RetrieveSentMessages(ctx, sender.RetrieveSentMessagesRequest{
+			Limit:     1,
+			Status:    []string{mongostore.STATUS_DELIVERED},
+			Recipient: "+905551234567",
+			Tags:      []string{"campaign"},
+			SortOrder: "asc",
+		})
+
+		assert.Nil(t, resp.Result)
+		assert.Len(t, resp.Messages, 1)
+		assert.Equal(t, expectedMessages[0].ID.Hex(), resp.Messages[0].ID)
+
+		c, err := mongostore.DecodeMessageCursor(resp.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMessages[0].ID, c.ID)
+		assert.True(t, createdAt.Equal(*c.Value))
+
+		o.After = c
+		mockMongoStore.On("GetMessages", ctx, f, o).Return(expectedMessages[1:], nil).Once()
+
+		resp = svc.RetrieveSentMessages(ctx, sender.RetrieveSentMessagesRequest{
+			Cursor:    resp.NextCursor,
+			Limit:     1,
+			Status:    []string{mongostore.STATUS_DELIVERED},
+			Recipient: "+905551234567",
+			Tags:      []string{"campaign"},
+			SortOrder: "asc",
+		})
+
+		assert.Nil(t, resp.Result)
+		assert.Len(t, resp.Messages, 1)
+		assert.Empty(t, resp.NextCursor)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		resp := svc.RetrieveSentMessages(ctx, sender.RetrieveSentMessagesRequest{Cursor: "not a cursor"})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeValidationError, resp.Result.Code)
+	})
+
 	t.Run("error", func(t *testing.T) {
-		mockMongoStore.On("GetMessages", ctx, mongostore.MessageFilter{Status: []string{mongostore.STATUS_SENT}}, mongostore.MessageOptions{}).Return([]sender.MessageTransaction(nil), errors.New("db error")).Once()
+		mockMongoStore.On("GetMessages", ctx, defaultFilter, defaultOptions).Return([]sender.MessageTransaction(nil), errors.New("db error")).Once()
 
 		resp := svc.RetrieveSentMessages(ctx, sender.RetrieveSentMessagesRequest{})
 
-		assert.Nil(t, resp.Result)
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeInternalServerError, resp.Result.Code)
 		assert.Empty(t, resp.Messages)
 		mockMongoStore.AssertExpectations(t)
 	})
@@ -122,3 +185,1073 @@ func TestService_StartSendMessage(t *testing.T) {
 
 	worker.Stop()
 }
+
+func TestService_GetWorkerStatus(t *testing.T) {
+	logger := log.NewNopLogger()
+	worker := NewWorker(mockmessagehook.NewClient(), mockmongostore.NewStore(), mockredisstore.NewStore(), logger, 5)
+	svc := NewService(logger, mockmongostore.NewStore(), mockredisstore.NewStore(), envvars.Configs{}, "test", worker)
+
+	worker.history.add(sender.BatchSummary{Selected: 1})
+	worker.history.add(sender.BatchSummary{Selected: 2})
+
+	resp := svc.GetWorkerStatus(context.Background(), sender.GetWorkerStatusRequest{Batches: 1})
+
+	assert.Nil(t, resp.Result)
+	if assert.NotNil(t, resp.Status) {
+		assert.False(t, resp.Status.Running)
+		assert.Equal(t, int64(5), resp.Status.BatchSize)
+		assert.Equal(t, []sender.BatchSummary{{Selected: 2}}, resp.Status.Batches)
+	}
+}
+
+func TestService_RunWorkerBatch(t *testing.T) {
+	logger := log.NewNopLogger()
+
+	t.Run("batch already running", func(t *testing.T) {
+		worker := NewWorker(mockmessagehook.NewClient(), mockmongostore.NewStore(), mockredisstore.NewStore(), logger, 2)
+		svc := NewService(logger, mockmongostore.NewStore(), mockredisstore.NewStore(), envvars.Configs{}, "test", worker)
+
+		worker.batchMu.Lock()
+		defer worker.batchMu.Unlock()
+
+		resp := svc.RunWorkerBatch(context.Background(), sender.RunWorkerBatchRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeConflictError, resp.Result.Code)
+	})
+
+	t.Run("dry run masks recipients", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), logger, 2)
+		svc := NewService(logger, mockMongoStore, mockredisstore.NewStore(), envvars.Configs{}, "test", worker)
+		ctx := auth.NewContext(context.Background(), auth.Identity{Name: "sre", Scopes: []string{sender.ScopeWorkerControl}})
+
+		mockMongoStore.On("GetMessages", ctx, mock.Anything, mock.Anything).
+			Return([]sender.MessageTransaction{{ID: primitive.NewObjectID(), Content: "hello", Recipient: "+905551234567"}}, nil).Once()
+
+		resp := svc.RunWorkerBatch(ctx, sender.RunWorkerBatchRequest{DryRun: true})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, "dry_run", resp.Status)
+		if assert.Len(t, resp.Messages, 1) {
+			assert.Equal(t, "+********4567", resp.Messages[0].Message.Recipient)
+			assert.Equal(t, sender.BatchActionSend, resp.Messages[0].Action)
+		}
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestService_GetSandboxMessages(t *testing.T) {
+	logger := log.NewNopLogger()
+
+	t.Run("sandbox disabled", func(t *testing.T) {
+		worker := NewWorker(mockmessagehook.NewClient(), mockmongostore.NewStore(), mockredisstore.NewStore(), logger, 2)
+		svc := NewService(logger, mockmongostore.NewStore(), mockredisstore.NewStore(), envvars.Configs{}, "test", worker)
+
+		resp := svc.GetSandboxMessages(context.Background(), sender.GetSandboxMessagesRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeNotFoundError, resp.Result.Code)
+	})
+
+	t.Run("masks recipients", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		sandbox, _ := sandboxclient.NewClient(mockMongoStore, 0, "")
+		worker := NewWorker(sandbox, mockMongoStore, mockredisstore.NewStore(), logger, 2)
+		svc := NewService(logger, mockMongoStore, mockredisstore.NewStore(), envvars.Configs{}, "test", worker)
+		ctx := auth.NewContext(context.Background(), auth.Identity{Name: "qa", Scopes: []string{sender.ScopeMessagesRead}})
+
+		mockMongoStore.On("GetSandboxMessages", ctx, "+905551234567", int64(defaultSandboxLimit)).
+			Return([]sender.SandboxMessage{{ID: primitive.NewObjectID(), Content: "hello", Recipient: "+905551234567", Status: sender.SandboxStatusAccepted}}, nil).Once()
+
+		resp := svc.GetSandboxMessages(ctx, sender.GetSandboxMessagesRequest{Recipient: "+905551234567"})
+
+		assert.Nil(t, resp.Result)
+		if assert.Len(t, resp.Messages, 1) {
+			assert.Equal(t, "+********4567", resp.Messages[0].Recipient)
+			assert.NotEqual(t, "hello", resp.Messages[0].Content)
+		}
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestService_Liveness(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	t.Run("stopped worker", func(t *testing.T) {
+		worker := NewWorker(mockmessagehook.NewClient(), mockmongostore.NewStore(), mockredisstore.NewStore(), logger, 2)
+		svc := NewService(logger, mockmongostore.NewStore(), mockredisstore.NewStore(), envvars.Configs{}, "test", worker)
+
+		resp := svc.Liveness(ctx, sender.LivenessRequest{})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, sender.ComponentStatusStopped, resp.Components[sender.ComponentWorker].Status)
+	})
+
+	t.Run("stalled worker", func(t *testing.T) {
+		worker := NewWorker(mockmessagehook.NewClient(), mockmongostore.NewStore(), mockredisstore.NewStore(), logger, 2)
+		worker.running = true
+		worker.inFlight = true
+		worker.lastRunAt = time.Now().Add(-stallTimeout - time.Second)
+		worker.startedAt = worker.lastRunAt
+		svc := NewService(logger, mockmongostore.NewStore(), mockredisstore.NewStore(), envvars.Configs{}, "test", worker)
+
+		resp := svc.Liveness(ctx, sender.LivenessRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Result.StatusCode)
+		assert.Equal(t, sender.ComponentStatusStalled, resp.Components[sender.ComponentWorker].Status)
+	})
+}
+
+func TestService_Readiness(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	sender.HEALTH_STATUS.SetStatus(true)
+	defer sender.HEALTH_STATUS.SetStatus(false)
+
+	newService := func() (*mockmongostore.Store, *mockredisstore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, mockRedisStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{HealthCheckTimeout: time.Second}, "test", worker)
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+
+		mockMongoStore.On("Ping", mock.Anything).Return(nil).Once()
+		mockRedisStore.On("Ping", mock.Anything).Return(nil).Once()
+
+		resp := svc.Readiness(ctx, sender.ReadinessRequest{})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, sender.ComponentStatusUp, resp.Components[sender.ComponentMongo].Status)
+		assert.Equal(t, sender.ComponentStatusUp, resp.Components[sender.ComponentRedis].Status)
+		assert.Equal(t, sender.ComponentStatusStopped, resp.Components[sender.ComponentWorker].Status)
+		mockMongoStore.AssertExpectations(t)
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("mongo unreachable", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+
+		mockMongoStore.On("Ping", mock.MatchedBy(func(ctx context.Context) bool {
+			_, ok := ctx.Deadline()
+			return ok
+		})).Return(errors.New("connection refused")).Once()
+		mockRedisStore.On("Ping", mock.Anything).Return(nil).Once()
+
+		resp := svc.Readiness(ctx, sender.ReadinessRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Result.StatusCode)
+		assert.Equal(t, sender.ComponentStatusDown, resp.Components[sender.ComponentMongo].Status)
+		assert.Equal(t, "connection refused", resp.Components[sender.ComponentMongo].Error)
+		assert.Equal(t, sender.ComponentStatusUp, resp.Components[sender.ComponentRedis].Status)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("shutting down", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+
+		mockMongoStore.On("Ping", mock.Anything).Return(nil).Once()
+		mockRedisStore.On("Ping", mock.Anything).Return(nil).Once()
+
+		sender.HEALTH_STATUS.SetStatus(false)
+		defer sender.HEALTH_STATUS.SetStatus(true)
+
+		resp := svc.Readiness(ctx, sender.ReadinessRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Result.StatusCode)
+	})
+}
+
+func TestService_CancelMessage(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*mockmongostore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PENDING}
+		cancelled := sender.MessageTransaction{ID: msg.ID, Status: mongostore.STATUS_CANCELLED, Version: 1}
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", ctx, msg, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{}).Return(cancelled, nil).Once()
+
+		resp := svc.CancelMessage(ctx, sender.CancelMessageRequest{ID: msg.ID.Hex()})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, &cancelled, resp.Message)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		id := primitive.NewObjectID()
+
+		mockMongoStore.On("GetMessage", ctx, id).Return(sender.MessageTransaction{}, mongostore.ErrMessageNotFound).Once()
+
+		resp := svc.CancelMessage(ctx, sender.CancelMessageRequest{ID: id.Hex()})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeNotFoundError, resp.Result.Code)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("claimed by worker", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PROCESSING, Version: 1}
+		conflictErr := &mongostore.StatusConflictError{ID: msg.ID, From: msg.Status, To: mongostore.STATUS_CANCELLED, Version: msg.Version}
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", ctx, msg, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{}).Return(sender.MessageTransaction{}, conflictErr).Once()
+
+		resp := svc.CancelMessage(ctx, sender.CancelMessageRequest{ID: msg.ID.Hex()})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeConflictError, resp.Result.Code)
+		assert.Equal(t, http.StatusConflict, resp.Result.StatusCode)
+		assert.Equal(t, "message-claimed-conflict-error-message", resp.Result.MessageLocalizerKey)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("claim expired", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		expired := time.Now().Add(-time.Minute)
+		msg := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PROCESSING, Version: 1, LeaseUntil: &expired}
+		cancelled := sender.MessageTransaction{ID: msg.ID, Status: mongostore.STATUS_CANCELLED, Version: 2}
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", ctx, msg, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{}).Return(cancelled, nil).Once()
+
+		resp := svc.CancelMessage(ctx, sender.CancelMessageRequest{ID: msg.ID.Hex()})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, &cancelled, resp.Message)
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestService_CancelMessages(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*mockmongostore.Store, *mockredisstore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, mockRedisStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	filter := func(ids ...primitive.ObjectID) mongostore.MessageFilter {
+		return mongostore.MessageFilter{
+			IDs:       ids,
+			Status:    []string{mongostore.STATUS_FAILED, mongostore.STATUS_PENDING, mongostore.STATUS_PROCESSING},
+			Recipient: "+905551234567",
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+		pending := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PENDING, Version: 1}
+		claimed := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PROCESSING, Version: 2}
+		raced := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_FAILED, Version: 3}
+		cancelled := sender.MessageTransaction{ID: pending.ID, Status: mongostore.STATUS_CANCELLED, Version: 2}
+		conflictErr := &mongostore.StatusConflictError{ID: raced.ID, From: raced.Status, To: mongostore.STATUS_CANCELLED, Version: raced.Version}
+
+		mockMongoStore.On("StreamMessages", ctx, filter(pending.ID, claimed.ID, raced.ID), mongostore.MessageOptions{}, mock.Anything).
+			Return([]sender.MessageTransaction{pending, claimed, raced}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", ctx, pending, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{}).Return(cancelled, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", ctx, raced, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{}).Return(sender.MessageTransaction{}, conflictErr).Once()
+		mockRedisStore.On("PublishMessageEvent", ctx, mock.MatchedBy(func(e sender.MessageEvent) bool {
+			return e.ID == pending.ID.Hex() && e.Status == mongostore.STATUS_CANCELLED
+		})).Return(nil).Once()
+
+		resp := svc.CancelMessages(ctx, sender.CancelMessagesRequest{IDs: []string{pending.ID.Hex(), claimed.ID.Hex(), raced.ID.Hex()}, Recipient: "+905551234567"})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, int64(1), resp.CancelledCount)
+		assert.Equal(t, int64(2), resp.SkippedCount)
+		assert.Equal(t, []string{claimed.ID.Hex(), raced.ID.Hex()}, resp.SkippedIDs)
+		mockMongoStore.AssertExpectations(t)
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("every message claimed", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+		claimed := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PROCESSING, Version: 2}
+
+		mockMongoStore.On("StreamMessages", ctx, filter(claimed.ID), mongostore.MessageOptions{}, mock.Anything).
+			Return([]sender.MessageTransaction{claimed}, nil).Once()
+
+		resp := svc.CancelMessages(ctx, sender.CancelMessagesRequest{IDs: []string{claimed.ID.Hex()}, Recipient: "+905551234567"})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeConflictError, resp.Result.Code)
+		assert.Equal(t, "cancel-messages-claimed-conflict-error-message", resp.Result.MessageLocalizerKey)
+		assert.Equal(t, []string{claimed.ID.Hex()}, resp.SkippedIDs)
+		mockMongoStore.AssertNotCalled(t, "UpdateMessageStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("claim expired", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+		expired := time.Now().Add(-time.Minute)
+		abandoned := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PROCESSING, Version: 2, LeaseUntil: &expired}
+		cancelled := sender.MessageTransaction{ID: abandoned.ID, Status: mongostore.STATUS_CANCELLED, Version: 3}
+
+		mockMongoStore.On("StreamMessages", ctx, filter(abandoned.ID), mongostore.MessageOptions{}, mock.Anything).
+			Return([]sender.MessageTransaction{abandoned}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", ctx, abandoned, mongostore.STATUS_CANCELLED, mongostore.StatusDetails{}).Return(cancelled, nil).Once()
+		mockRedisStore.On("PublishMessageEvent", ctx, mock.Anything).Return(nil).Once()
+
+		resp := svc.CancelMessages(ctx, sender.CancelMessagesRequest{IDs: []string{abandoned.ID.Hex()}, Recipient: "+905551234567"})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, int64(1), resp.CancelledCount)
+		assert.Equal(t, int64(0), resp.SkippedCount)
+		mockMongoStore.AssertExpectations(t)
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("nothing matched", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+
+		mockMongoStore.On("StreamMessages", ctx, filter(), mongostore.MessageOptions{}, mock.Anything).
+			Return([]sender.MessageTransaction{}, nil).Once()
+
+		resp := svc.CancelMessages(ctx, sender.CancelMessagesRequest{Recipient: "+905551234567"})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, int64(0), resp.CancelledCount)
+		assert.Equal(t, int64(0), resp.SkippedCount)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("empty filter", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+
+		resp := svc.CancelMessages(ctx, sender.CancelMessagesRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeBadRequestError, resp.Result.Code)
+		mockMongoStore.AssertNotCalled(t, "StreamMessages")
+	})
+}
+
+func TestService_RequeueMessages(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*mockmongostore.Store, *mockredisstore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, mockRedisStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+		failed := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_FAILED, Version: 3}
+		invalid := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_INVALID, Version: 1}
+		changed := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_FAILED, Version: 2}
+		f := mongostore.MessageFilter{Status: []string{mongostore.STATUS_FAILED, mongostore.STATUS_INVALID}, Recipient: "+905551234567"}
+
+		mockMongoStore.On("StreamMessages", ctx, f, mongostore.MessageOptions{}, mock.Anything).
+			Return([]sender.MessageTransaction{failed, invalid, changed}, nil).Once()
+		for _, mt := range []sender.MessageTransaction{failed, invalid} {
+			requeued := sender.MessageTransaction{ID: mt.ID, Status: mongostore.STATUS_PENDING, Version: mt.Version + 1}
+			mockMongoStore.On("UpdateMessageStatus", ctx, mt, mongostore.STATUS_PENDING, mongostore.StatusDetails{Requeue: true}).Return(requeued, nil).Once()
+			mockRedisStore.On("PublishMessageEvent", ctx, mock.MatchedBy(func(e sender.MessageEvent) bool {
+				return e.ID == mt.ID.Hex() && e.Status == mongostore.STATUS_PENDING
+			})).Return(nil).Once()
+		}
+		conflictErr := &mongostore.StatusConflictError{ID: changed.ID, From: changed.Status, To: mongostore.STATUS_PENDING, Version: changed.Version}
+		mockMongoStore.On("UpdateMessageStatus", ctx, changed, mongostore.STATUS_PENDING, mongostore.StatusDetails{Requeue: true}).Return(sender.MessageTransaction{}, conflictErr).Once()
+
+		resp := svc.RequeueMessages(ctx, sender.RequeueMessagesRequest{Recipient: "+905551234567"})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, int64(2), resp.RequeuedCount)
+		mockMongoStore.AssertExpectations(t)
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+		f := mongostore.MessageFilter{Status: []string{mongostore.STATUS_INVALID}}
+
+		mockMongoStore.On("StreamMessages", ctx, f, mongostore.MessageOptions{}, mock.Anything).
+			Return([]sender.MessageTransaction{}, errors.New("cursor error")).Once()
+
+		resp := svc.RequeueMessages(ctx, sender.RequeueMessagesRequest{Status: []string{mongostore.STATUS_INVALID}})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeInternalServerError, resp.Result.Code)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("status other than dead letters", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+
+		resp := svc.RequeueMessages(ctx, sender.RequeueMessagesRequest{Status: []string{mongostore.STATUS_FAILED, mongostore.STATUS_PROCESSING}})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeValidationError, resp.Result.Code)
+		mockMongoStore.AssertNotCalled(t, "StreamMessages")
+	})
+
+	t.Run("empty filter", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+
+		resp := svc.RequeueMessages(ctx, sender.RequeueMessagesRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeBadRequestError, resp.Result.Code)
+		mockMongoStore.AssertNotCalled(t, "StreamMessages")
+	})
+}
+
+func TestService_UpdateMessage(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*mockmongostore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	newMessage := func() sender.MessageTransaction {
+		return sender.MessageTransaction{
+			ID:        primitive.NewObjectID(),
+			Content:   "test",
+			Recipient: "+905551234567",
+			Status:    mongostore.STATUS_PENDING,
+			Version:   2,
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := newMessage()
+		content := "edited"
+		priority := 5
+		sendAt := time.Now().Add(time.Hour)
+
+		updated := msg
+		updated.Content = content
+		updated.Priority = priority
+		updated.SendAt = &sendAt
+		stored := updated
+		stored.Version = 3
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+		mockMongoStore.On("UpdateMessage", ctx, msg, updated).Return(stored, nil).Once()
+
+		resp := svc.UpdateMessage(ctx, sender.UpdateMessageRequest{ID: msg.ID.Hex(), Content: &content, Priority: &priority, SendAt: &sendAt})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, &stored, resp.Message)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("send now clears send time", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := newMessage()
+		sendAt := time.Now().Add(time.Hour)
+		msg.SendAt = &sendAt
+
+		updated := msg
+		updated.SendAt = nil
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+		mockMongoStore.On("UpdateMessage", ctx, msg, updated).Return(updated, nil).Once()
+
+		resp := svc.UpdateMessage(ctx, sender.UpdateMessageRequest{ID: msg.ID.Hex(), SendNow: true})
+
+		assert.Nil(t, resp.Result)
+		assert.Nil(t, resp.Message.SendAt)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("content exceeding character limit", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := newMessage()
+		content := strings.Repeat("a", sender.MaxMessageLength+1)
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+
+		resp := svc.UpdateMessage(ctx, sender.UpdateMessageRequest{ID: msg.ID.Hex(), Content: &content})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeValidationError, resp.Result.Code)
+		mockMongoStore.AssertNotCalled(t, "UpdateMessage")
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := newMessage()
+		version := int64(1)
+		content := "edited"
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+
+		resp := svc.UpdateMessage(ctx, sender.UpdateMessageRequest{ID: msg.ID.Hex(), Version: &version, Content: &content})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeConflictError, resp.Result.Code)
+		mockMongoStore.AssertNotCalled(t, "UpdateMessage")
+	})
+
+	t.Run("claimed by worker", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := newMessage()
+		content := "edited"
+
+		updated := msg
+		updated.Content = content
+		conflictErr := &mongostore.EditConflictError{ID: msg.ID, Status: msg.Status, Version: msg.Version}
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+		mockMongoStore.On("UpdateMessage", ctx, msg, updated).Return(sender.MessageTransaction{}, conflictErr).Once()
+
+		resp := svc.UpdateMessage(ctx, sender.UpdateMessageRequest{ID: msg.ID.Hex(), Content: &content})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeConflictError, resp.Result.Code)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("claim expired", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		msg := newMessage()
+		expired := time.Now().Add(-time.Minute)
+		content := "edited"
+
+		updated := msg
+		updated.Content = content
+		conflictErr := &mongostore.EditConflictError{ID: msg.ID, Status: mongostore.STATUS_PROCESSING, Version: msg.Version + 1, LeaseUntil: &expired}
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+		mockMongoStore.On("UpdateMessage", ctx, msg, updated).Return(sender.MessageTransaction{}, conflictErr).Once()
+
+		resp := svc.UpdateMessage(ctx, sender.UpdateMessageRequest{ID: msg.ID.Hex(), Content: &content})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeConflictError, resp.Result.Code)
+		assert.Equal(t, "message-claim-expired-conflict-error-message", resp.Result.MessageLocalizerKey)
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestService_GetMessage(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockRedisStore := mockredisstore.NewStore()
+	logger := log.NewNopLogger()
+	worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+	svc := NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		sentAt := time.Now()
+		msg := sender.MessageTransaction{
+			ID:                primitive.NewObjectID(),
+			Status:            mongostore.STATUS_SENT,
+			Attempts:          2,
+			SentAt:            &sentAt,
+			Provider:          "webhook",
+			ProviderMessageID: "provider-id",
+		}
+
+		mockMongoStore.On("GetMessage", ctx, msg.ID).Return(msg, nil).Once()
+
+		resp := svc.GetMessage(ctx, sender.GetMessageRequest{ID: msg.ID.Hex()})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, &msg, resp.Message)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("masked without pii scope", func(t *testing.T) {
+		msg := sender.MessageTransaction{
+			ID:        primitive.NewObjectID(),
+			Content:   "Your code is 1234",
+			Recipient: "+905551234567",
+			Status:    mongostore.STATUS_SENT,
+			History:   []sender.MessageRevision{{Content: "Your code is 0000", Recipient: "+905550000000"}},
+		}
+
+		supportCtx := auth.NewContext(ctx, auth.Identity{Name: "support", Scopes: []string{sender.ScopeMessagesRead}})
+		mockMongoStore.On("GetMessage", supportCtx, msg.ID).Return(msg, nil).Once()
+
+		resp := svc.GetMessage(supportCtx, sender.GetMessageRequest{ID: msg.ID.Hex()})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, "+********4567", resp.Message.Recipient)
+		assert.NotContains(t, resp.Message.Content, "1234")
+		assert.Equal(t, "+********0000", resp.Message.History[0].Recipient)
+		assert.NotContains(t, resp.Message.History[0].Content, "0000")
+
+		adminCtx := auth.NewContext(ctx, auth.Identity{Name: "admin", Scopes: sender.Scopes})
+		mockMongoStore.On("GetMessage", adminCtx, msg.ID).Return(msg, nil).Once()
+
+		resp = svc.GetMessage(adminCtx, sender.GetMessageRequest{ID: msg.ID.Hex()})
+
+		assert.Equal(t, &msg, resp.Message)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		id := primitive.NewObjectID()
+
+		mockMongoStore.On("GetMessage", ctx, id).Return(sender.MessageTransaction{}, mongostore.ErrMessageNotFound).Once()
+
+		resp := svc.GetMessage(ctx, sender.GetMessageRequest{ID: id.Hex()})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusNotFound, resp.Result.StatusCode)
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestService_GetStats(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockRedisStore := mockredisstore.NewStore()
+	logger := log.NewNopLogger()
+	worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+	configs := envvars.Configs{StatsWindowMinutes: 15, StatsCacheTTL: 10 * time.Second}
+	svc := NewService(logger, mockMongoStore, mockRedisStore, configs, "test", worker)
+
+	ctx := context.Background()
+
+	t.Run("cache hit", func(t *testing.T) {
+		cached := &sender.MessageStats{WindowMinutes: 15, SentInWindow: 30}
+
+		mockRedisStore.On("GetMessageStats", ctx, 15).Return(cached, nil).Once()
+
+		resp := svc.GetStats(ctx, sender.GetStatsRequest{})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, cached, resp.Stats)
+		mockRedisStore.AssertExpectations(t)
+		mockMongoStore.AssertNotCalled(t, "GetMessageStats", mock.Anything, mock.Anything)
+	})
+
+	t.Run("cache miss", func(t *testing.T) {
+		oldest := time.Now().Add(-time.Minute)
+		stats := sender.MessageStats{
+			StatusCounts:           map[string]int64{mongostore.STATUS_PENDING: 3},
+			OldestPendingCreatedAt: &oldest,
+			SentInWindow:           10,
+		}
+
+		mockRedisStore.On("GetMessageStats", ctx, 5).Return((*sender.MessageStats)(nil), nil).Once()
+		mockMongoStore.On("GetMessageStats", ctx, mock.AnythingOfType("time.Time")).Return(stats, nil).Once()
+		mockRedisStore.On("CacheMessageStats", ctx, mock.AnythingOfType("sender.MessageStats"), configs.StatsCacheTTL).Return(nil).Once()
+
+		resp := svc.GetStats(ctx, sender.GetStatsRequest{WindowMinutes: 5})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, 5, resp.Stats.WindowMinutes)
+		assert.Equal(t, float64(2), resp.Stats.ThroughputPerMinute)
+		assert.GreaterOrEqual(t, resp.Stats.OldestPendingAgeSeconds, float64(60))
+		assert.False(t, resp.Stats.GeneratedAt.IsZero())
+		mockMongoStore.AssertExpectations(t)
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		mockRedisStore.On("GetMessageStats", ctx, 15).Return((*sender.MessageStats)(nil), nil).Once()
+		mockMongoStore.On("GetMessageStats", ctx, mock.AnythingOfType("time.Time")).Return(sender.MessageStats{}, errors.New("db error")).Once()
+
+		resp := svc.GetStats(ctx, sender.GetStatsRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusInternalServerError, resp.Result.StatusCode)
+		assert.Nil(t, resp.Stats)
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestService_ExportMessages(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockRedisStore := mockredisstore.NewStore()
+	logger := log.NewNopLogger()
+	worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+	svc := NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+
+	ctx := context.Background()
+	defaultFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_SENT}}
+	defaultOptions := mongostore.MessageOptions{SortBy: mongostore.SortByCreatedAt}
+
+	t.Run("streams messages", func(t *testing.T) {
+		messages := []sender.MessageTransaction{
+			{ID: primitive.NewObjectID(), Content: "first", Status: mongostore.STATUS_SENT},
+			{ID: primitive.NewObjectID(), Content: "second", Status: mongostore.STATUS_SENT},
+		}
+
+		mockMongoStore.On("StreamMessages", ctx, defaultFilter, defaultOptions, mock.Anything).Return(messages, nil).Once()
+
+		resp := svc.ExportMessages(ctx, sender.ExportMessagesRequest{})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, sender.ExportFormatNDJSON, resp.Format)
+
+		var exported []sender.ResponseMessage
+		err := resp.Messages(func(m sender.ResponseMessage) error {
+			exported = append(exported, m)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []sender.ResponseMessage{sender.NewResponseMessage(messages[0]), sender.NewResponseMessage(messages[1])}, exported)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		mockMongoStore.On("StreamMessages", ctx, defaultFilter, defaultOptions, mock.Anything).Return([]sender.MessageTransaction{}, errors.New("cursor error")).Once()
+
+		resp := svc.ExportMessages(ctx, sender.ExportMessagesRequest{})
+
+		err := resp.Messages(func(m sender.ResponseMessage) error { return nil })
+
+		assert.Error(t, err)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("format", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			req      sender.ExportMessagesRequest
+			expected string
+		}{
+			{name: "default", req: sender.ExportMessagesRequest{}, expected: sender.ExportFormatNDJSON},
+			{name: "accept csv", req: sender.ExportMessagesRequest{Accept: "text/csv"}, expected: sender.ExportFormatCSV},
+			{name: "query over accept", req: sender.ExportMessagesRequest{Accept: "text/csv", Format: sender.ExportFormatNDJSON}, expected: sender.ExportFormatNDJSON},
+			{name: "query csv", req: sender.ExportMessagesRequest{Format: sender.ExportFormatCSV}, expected: sender.ExportFormatCSV},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, svc.ExportMessages(ctx, tt.req).Format)
+			})
+		}
+	})
+}
+
+func TestService_StreamMessageEvents(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockRedisStore := mockredisstore.NewStore()
+	logger := log.NewNopLogger()
+	worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+	svc := NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+
+	t.Run("filters events and closes stream", func(t *testing.T) {
+		published := make(chan sender.MessageEvent)
+		mockRedisStore.On("SubscribeMessageEvents", mock.Anything).Return(published, nil).Once()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		resp := svc.StreamMessageEvents(ctx, sender.StreamMessageEventsRequest{Campaign: "promo", Status: []string{mongostore.STATUS_SENT}})
+		assert.Nil(t, resp.Result)
+
+		published <- sender.MessageEvent{ID: "1", Status: mongostore.STATUS_SENT, Tags: []string{"other"}}
+		published <- sender.MessageEvent{ID: "2", Status: mongostore.STATUS_FAILED, Tags: []string{"promo"}}
+		published <- sender.MessageEvent{ID: "3", Status: mongostore.STATUS_SENT, Tags: []string{"promo"}}
+
+		select {
+		case e := <-resp.Events:
+			assert.Equal(t, "3", e.ID)
+		case <-time.After(time.Second):
+			t.Fatal("event is not received")
+		}
+
+		cancel()
+
+		select {
+		case _, ok := <-resp.Events:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("stream is not closed")
+		}
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("filters by masked recipient", func(t *testing.T) {
+		published := make(chan sender.MessageEvent)
+		mockRedisStore.On("SubscribeMessageEvents", mock.Anything).Return(published, nil).Once()
+
+		ctx, cancel := context.WithCancel(auth.NewContext(context.Background(), auth.Identity{Name: "qa", Scopes: []string{sender.ScopeMessagesRead}}))
+		resp := svc.StreamMessageEvents(ctx, sender.StreamMessageEventsRequest{Recipient: "+905551234567"})
+		assert.Nil(t, resp.Result)
+		assert.True(t, resp.MaskPII)
+
+		published <- sender.MessageEvent{ID: "1", Status: mongostore.STATUS_SENT, Recipient: "+********0000"}
+		published <- sender.MessageEvent{ID: "2", Status: mongostore.STATUS_SENT, Recipient: "+********4567"}
+
+		select {
+		case e := <-resp.Events:
+			assert.Equal(t, "2", e.ID)
+		case <-time.After(time.Second):
+			t.Fatal("event is not received")
+		}
+
+		cancel()
+
+		select {
+		case _, ok := <-resp.Events:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("stream is not closed")
+		}
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("subscribe error", func(t *testing.T) {
+		mockRedisStore.On("SubscribeMessageEvents", mock.Anything).Return(nil, errors.New("redis error")).Once()
+
+		resp := svc.StreamMessageEvents(context.Background(), sender.StreamMessageEventsRequest{})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusInternalServerError, resp.Result.StatusCode)
+		mockRedisStore.AssertExpectations(t)
+	})
+}
+
+func TestService_CreateMessage(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*mockmongostore.Store, *mockredisstore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, mockRedisStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+		sendAt := time.Now().Add(time.Hour)
+		req := sender.CreateMessageRequest{Content: "hello", Recipient: "+905551234567", SendAt: &sendAt, Priority: 3, Tags: []string{"promo"}}
+
+		mockMongoStore.On("InsertMany", ctx, mock.MatchedBy(func(mts []sender.MessageTransaction) bool {
+			return len(mts) == 1 && mts[0].Status == mongostore.STATUS_PENDING && mts[0].Content == req.Content && mts[0].Priority == req.Priority
+		})).Return(nil).Once()
+		mockRedisStore.On("PublishMessageEvent", ctx, mock.MatchedBy(func(e sender.MessageEvent) bool {
+			return e.Status == mongostore.STATUS_PENDING && e.Recipient == "+********4567"
+		})).Return(nil).Once()
+
+		resp := svc.CreateMessage(ctx, req)
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, mongostore.STATUS_PENDING, resp.Message.Status)
+		assert.Equal(t, req.Recipient, resp.Message.Recipient)
+		assert.Equal(t, &sendAt, resp.Message.SendAt)
+		assert.Equal(t, req.Tags, resp.Message.Tags)
+		assert.False(t, resp.Message.ID.IsZero())
+		mockMongoStore.AssertExpectations(t)
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("stores trace of request", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+		tctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "POST /create-message")
+		defer span.End()
+
+		mockMongoStore.On("InsertMany", tctx, mock.MatchedBy(func(mts []sender.MessageTransaction) bool {
+			return len(mts) == 1 && mts[0].TraceID == span.SpanContext().TraceID().String() && mts[0].SpanID == span.SpanContext().SpanID().String()
+		})).Return(nil).Once()
+		mockRedisStore.On("PublishMessageEvent", tctx, mock.Anything).Return(nil).Once()
+
+		resp := svc.CreateMessage(tctx, sender.CreateMessageRequest{Content: "hello", Recipient: "+905551234567"})
+
+		assert.Nil(t, resp.Result)
+		assert.Equal(t, span.SpanContext().TraceID().String(), resp.Message.TraceID)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("content too long", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+
+		resp := svc.CreateMessage(ctx, sender.CreateMessageRequest{Content: strings.Repeat("a", sender.MaxMessageLength+1), Recipient: "+905551234567"})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeValidationError, resp.Result.Code)
+		mockMongoStore.AssertNotCalled(t, "InsertMany", mock.Anything, mock.Anything)
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+
+		mockMongoStore.On("InsertMany", ctx, mock.Anything).Return(errors.New("db error")).Once()
+
+		resp := svc.CreateMessage(ctx, sender.CreateMessageRequest{Content: "hello", Recipient: "+905551234567"})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusInternalServerError, resp.Result.StatusCode)
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestService_CreateAPIKey(t *testing.T) {
+	newService := func() (*mockmongostore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		ctx := auth.NewContext(context.Background(), auth.Identity{KeyID: primitive.NewObjectID().Hex(), Name: "admin", Scopes: sender.Scopes})
+
+		var inserted sender.APIKey
+		mockMongoStore.On("InsertAPIKey", ctx, mock.MatchedBy(func(k sender.APIKey) bool {
+			inserted = k
+			return k.Name == "reporting" && k.CreatedBy == "admin"
+		})).Return(sender.APIKey{ID: primitive.NewObjectID(), Name: "reporting"}, nil).Once()
+
+		resp := svc.CreateAPIKey(ctx, sender.CreateAPIKeyRequest{Name: "reporting", Role: sender.RoleSupport, Scopes: []string{sender.ScopeMessagesRead, sender.ScopeMessagesWrite}})
+
+		assert.Nil(t, resp.Result)
+		assert.NotEmpty(t, resp.Key)
+		assert.NotNil(t, resp.APIKey)
+		assert.Equal(t, auth.HashKey(resp.Key), inserted.Hash)
+		assert.True(t, strings.HasPrefix(resp.Key, inserted.Prefix))
+		assert.NotEqual(t, resp.Key, inserted.Prefix)
+		assert.Equal(t, []string{sender.ScopeMessagesRead, sender.ScopeMessagesWrite}, inserted.Scopes)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("scope escalation", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		ctx := auth.NewContext(context.Background(), auth.Identity{Name: "keys", Scopes: []string{sender.ScopeKeysAdmin}})
+
+		resp := svc.CreateAPIKey(ctx, sender.CreateAPIKeyRequest{Name: "sre", Role: sender.RoleSRE})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeForbiddenError, resp.Result.Code)
+		assert.Empty(t, resp.Key)
+		mockMongoStore.AssertNotCalled(t, "InsertAPIKey", mock.Anything, mock.Anything)
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		ctx := context.Background()
+
+		mockMongoStore.On("InsertAPIKey", ctx, mock.Anything).Return(sender.APIKey{}, errors.New("db error")).Once()
+
+		resp := svc.CreateAPIKey(ctx, sender.CreateAPIKeyRequest{Name: "reporting"})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, http.StatusInternalServerError, resp.Result.StatusCode)
+		assert.Empty(t, resp.Key)
+	})
+}
+
+func TestService_RevokeAPIKey(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*mockmongostore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		id := primitive.NewObjectID()
+		revokedAt := time.Now()
+
+		mockMongoStore.On("RevokeAPIKey", ctx, id).Return(sender.APIKey{ID: id, Name: "reporting", RevokedAt: &revokedAt}, nil).Once()
+
+		resp := svc.RevokeAPIKey(ctx, sender.RevokeAPIKeyRequest{ID: id.Hex()})
+
+		assert.Nil(t, resp.Result)
+		assert.True(t, resp.APIKey.IsRevoked())
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockMongoStore, svc := newService()
+		id := primitive.NewObjectID()
+
+		mockMongoStore.On("RevokeAPIKey", ctx, id).Return(sender.APIKey{}, mongostore.ErrAPIKeyNotFound).Once()
+
+		resp := svc.RevokeAPIKey(ctx, sender.RevokeAPIKeyRequest{ID: id.Hex()})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeNotFoundError, resp.Result.Code)
+	})
+}
+
+func TestService_ReceiveDeliveryReceipt(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*mockmongostore.Store, *mockredisstore.Store, sender.Service) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		logger := log.NewNopLogger()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockRedisStore, logger, 2)
+		return mockMongoStore, mockRedisStore, NewService(logger, mockMongoStore, mockRedisStore, envvars.Configs{}, "test", worker)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockMongoStore, mockRedisStore, svc := newService()
+		mt := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_SENT, ProviderMessageID: "provider-1"}
+		deliveredAt := time.Now().Add(-time.Minute)
+		delivered := mt
+		delivered.Status = mongostore.STATUS_DELIVERED
+		delivered.DeliveredAt = &deliveredAt
+
+		mockMongoStore.On("GetMessageByProviderMessageID", ctx, "provider-1").Return(mt, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", ctx, mt, mongostore.STATUS_DELIVERED, mongostore.StatusDetails{DeliveredAt: &deliveredAt}).Return(delivered, nil).Once()
+		mockRedisStore.On("PublishMessageEvent", ctx, mock.MatchedBy(func(e sender.MessageEvent) bool {
+			return e.Status == mongostore.STATUS_DELIVERED
+		})).Return(nil).Once()
+
+		resp := svc.ReceiveDeliveryReceipt(ctx, sender.DeliveryReceiptRequest{ProviderMessageID: "provider-1", Status: "delivered", DeliveredAt: &deliveredAt})
+
+		assert.Nil(t, resp.Result)
+		mockMongoStore.AssertExpectations(t)
+		mockRedisStore.AssertExpectations(t)
+	})
+
+	t.Run("already delivered", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+		mt := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_DELIVERED, ProviderMessageID: "provider-1"}
+
+		mockMongoStore.On("GetMessageByProviderMessageID", ctx, "provider-1").Return(mt, nil).Once()
+
+		resp := svc.ReceiveDeliveryReceipt(ctx, sender.DeliveryReceiptRequest{ProviderMessageID: "provider-1", Status: "delivered"})
+
+		assert.Nil(t, resp.Result)
+		mockMongoStore.AssertNotCalled(t, "UpdateMessageStatus")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockMongoStore, _, svc := newService()
+
+		mockMongoStore.On("GetMessageByProviderMessageID", ctx, "provider-1").Return(sender.MessageTransaction{}, mongostore.ErrMessageNotFound).Once()
+
+		resp := svc.ReceiveDeliveryReceipt(ctx, sender.DeliveryReceiptRequest{ProviderMessageID: "provider-1", Status: "delivered"})
+
+		assert.NotNil(t, resp.Result)
+		assert.Equal(t, apierror.CodeNotFoundError, resp.Result.Code)
+	})
+}
@@ -2,19 +2,57 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
 	"github.com/mkaykisiz/sender"
 	"github.com/mkaykisiz/sender/internal/client/messageclient"
+	"github.com/mkaykisiz/sender/internal/recipientguard"
+	"github.com/mkaykisiz/sender/internal/requestid"
 	mongostore "github.com/mkaykisiz/sender/internal/store/mongo"
 	redisstore "github.com/mkaykisiz/sender/internal/store/redis"
+	"github.com/mkaykisiz/sender/internal/tracing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const MaxMessageLength = 1000
 
+// worker schedule, a running worker is stalled when a batch outlives stallTimeout or no batch starts
+// for two intervals
+const (
+	workerInterval = 2 * time.Minute
+	batchTimeout   = 30 * time.Second
+	stallTimeout   = 2 * batchTimeout
+)
+
+// defaultClaimLease is how long a claimed message is held by a worker, it is claimed again by the next
+// batch once the lease expires since its worker is considered stopped
+const defaultClaimLease = 5 * time.Minute
+
+// send outcomes counted by worker metrics
+const (
+	sendOutcomeSent    = "sent"
+	sendOutcomeFailed  = "failed"
+	sendOutcomeInvalid = "invalid"
+	sendOutcomeBlocked = "blocked"
+)
+
+// WorkerMetrics holds instruments of worker, Sends is labeled by outcome
+type WorkerMetrics struct {
+	BatchSize   metrics.Histogram
+	Sends       metrics.Counter
+	SendLatency metrics.Histogram
+	QueueDepth  metrics.Gauge
+}
+
 type Worker struct {
 	sender  messageclient.MessageClient
 	ms      mongostore.Store
@@ -25,6 +63,29 @@ type Worker struct {
 	running bool
 	limit   int64
 	mu      sync.Mutex
+	// batchMu serializes batches of the ticker and manual runs
+	batchMu sync.Mutex
+
+	startedAt time.Time
+	lastRunAt time.Time
+	nextRunAt time.Time
+	inFlight  bool
+	history   *batchHistory
+	// cancelBatch cancels the running batch, it is nil while no batch is running
+	cancelBatch context.CancelFunc
+	// claims holds messages claimed for sending whose send has not been attempted or whose failure could
+	// not be written, they are released on drain
+	claims map[primitive.ObjectID]sender.MessageTransaction
+	// unrecorded holds sends whose SENT status could not be written, their messages are not sent again by
+	// worker and their status is written again by the next batch and on drain
+	unrecorded map[primitive.ObjectID]sentRecord
+	// claimLease is how long claims of worker are held
+	claimLease time.Duration
+
+	// guard blocks recipients outside the allow-list of non-prod environments, every recipient is allowed when it is nil
+	guard *recipientguard.Guard
+
+	metrics *WorkerMetrics
 }
 
 // NewWorker creates and returns worker
@@ -37,9 +98,33 @@ func NewWorker(sender messageclient.MessageClient, ms mongostore.Store, rs redis
 		done:    make(chan bool),
 		running: false,
 		limit:   limit,
+		history: newBatchHistory(batchHistorySize),
+
+		claimLease: defaultClaimLease,
 	}
 }
 
+// SetClaimLease makes worker hold its claims for d, d must outlive a batch so that a message is not
+// claimed again while it is being sent
+func (w *Worker) SetClaimLease(d time.Duration) error {
+	if d <= batchTimeout {
+		return fmt.Errorf("claim lease %s must be longer than batch timeout %s", d, batchTimeout)
+	}
+
+	w.claimLease = d
+	return nil
+}
+
+// SetRecipientGuard makes worker block messages whose recipients are not allowed by g
+func (w *Worker) SetRecipientGuard(g *recipientguard.Guard) {
+	w.guard = g
+}
+
+// SetMetrics makes worker report batches, sends and queue depth to m
+func (w *Worker) SetMetrics(m *WorkerMetrics) {
+	w.metrics = m
+}
+
 func (w *Worker) Start() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -48,7 +133,9 @@ func (w *Worker) Start() {
 		return
 	}
 	w.running = true
-	w.ticker = time.NewTicker(2 * time.Minute)
+	w.startedAt = time.Now()
+	w.nextRunAt = w.startedAt.Add(workerInterval)
+	w.ticker = time.NewTicker(workerInterval)
 	w.done = make(chan bool)
 
 	go func() {
@@ -57,12 +144,13 @@ func (w *Worker) Start() {
 			select {
 			case <-w.done:
 				return
-			case <-w.ticker.C:
+			case t := <-w.ticker.C:
+				w.setNextRunAt(t.Add(workerInterval))
 				w.process() // Run every x minutes
 			}
 		}
 	}()
-	w.logWithLogger(nil, map[string]interface{}{
+	w.logWithLogger(context.Background(), nil, map[string]interface{}{
 		"method": "Start",
 		"msg":    "started",
 	})
@@ -78,38 +166,161 @@ func (w *Worker) Stop() {
 	w.running = false
 	w.ticker.Stop()
 	close(w.done)
-	w.logWithLogger(nil, map[string]interface{}{
+	w.logWithLogger(context.Background(), nil, map[string]interface{}{
 		"method": "Stop",
 		"msg":    "stopped",
 	})
 }
 
+// Health returns health of worker, stopped worker is not unhealthy since it is stopped on purpose. Recipient
+// guard is reported in details when it is set.
+func (w *Worker) Health() sender.ComponentHealth {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.running {
+		h := sender.ComponentHealth{Status: sender.ComponentStatusStopped}
+		if w.guard != nil {
+			h.Details = map[string]interface{}{"recipient_guard": w.guard.Details()}
+		}
+		return h
+	}
+
+	h := sender.ComponentHealth{
+		Status:  sender.ComponentStatusUp,
+		Details: map[string]interface{}{"in_flight": w.inFlight},
+	}
+	if w.guard != nil {
+		h.Details["recipient_guard"] = w.guard.Details()
+	}
+	if !w.lastRunAt.IsZero() {
+		h.Details["last_run_at"] = w.lastRunAt
+	}
+
+	// worker restarted after its last batch is measured from its start
+	since := w.lastRunAt
+	if since.Before(w.startedAt) {
+		since = w.startedAt
+	}
+
+	switch {
+	case w.inFlight && time.Since(since) > stallTimeout:
+		h.Status = sender.ComponentStatusStalled
+		h.Error = fmt.Sprintf("batch has been running for more than %s", stallTimeout)
+	case !w.inFlight && time.Since(since) > 2*workerInterval:
+		h.Status = sender.ComponentStatusStalled
+		h.Error = fmt.Sprintf("no batch has started for more than %s", 2*workerInterval)
+	}
+
+	return h
+}
+
+// RunNow starts a batch right away without waiting for the ticker, ok is false when a batch is already
+// running. The batch runs in background, its summary is added to history once it finishes.
+func (w *Worker) RunNow() (ok bool) {
+	if !w.batchMu.TryLock() {
+		return false
+	}
+
+	go func() {
+		defer w.batchMu.Unlock()
+		w.runBatch()
+	}()
+
+	return true
+}
+
+// DryRun returns messages the next batch would select and what it would do with each of them without
+// sending them or changing their status
+func (w *Worker) DryRun(ctx context.Context) ([]sender.PlannedSend, error) {
+	f, o := w.batchQuery()
+	messages, err := w.ms.GetMessages(ctx, f, o)
+	if err != nil {
+		return nil, err
+	}
+
+	planned := make([]sender.PlannedSend, 0, len(messages))
+	for _, msg := range messages {
+		p := sender.PlannedSend{Message: sender.NewResponseMessage(msg), Action: w.planAction(msg)}
+		if p.Action == sender.BatchActionSend {
+			p.Provider = w.sender.Name()
+		}
+		planned = append(planned, p)
+	}
+
+	return planned, nil
+}
+
+// batchQuery returns filter and options of messages a batch selects
+func (w *Worker) batchQuery() (mongostore.MessageFilter, mongostore.MessageOptions) {
+	f := mongostore.MessageFilter{
+		Status:        []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED},
+		Due:           true,
+		ExpiredClaims: true,
+	}
+
+	return f, mongostore.MessageOptions{Limit: w.limit}
+}
+
+// planAction returns what a batch does with msg
+func (w *Worker) planAction(msg sender.MessageTransaction) string {
+	if !msg.IsValid() {
+		return sender.BatchActionInvalidate
+	}
+	if !w.guard.Allows(msg.Recipient) {
+		return sender.BatchActionBlock
+	}
+
+	return sender.BatchActionSend
+}
+
+// process runs a batch once no other batch is running
 func (w *Worker) process() {
-	w.logWithLogger(nil, map[string]interface{}{
+	w.batchMu.Lock()
+	defer w.batchMu.Unlock()
+
+	w.runBatch()
+}
+
+func (w *Worker) runBatch() {
+	ctx, cancel := context.WithTimeout(context.Background(), batchTimeout)
+	defer cancel()
+
+	b := w.startBatch(cancel)
+	defer w.finishBatch(b)
+
+	// lines of a batch share an id, lines of a message carry the id of the request which created it
+	ctx = requestid.NewContext(ctx, requestid.New())
+
+	w.logWithLogger(ctx, nil, map[string]interface{}{
 		"method": "process",
 		"msg":    "processing",
 	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// every batch is a trace of its own, sends are linked to traces of requests which created messages
+	ctx, span := tracing.Start(ctx, "Worker.process", trace.WithNewRoot())
+	defer span.End()
 
-	messageFilter := mongostore.MessageFilter{
-		Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED},
-	}
-	messageOptions := mongostore.MessageOptions{
-		Limit: w.limit,
-	}
+	// sends of earlier batches are recorded first so that their messages are not selected again
+	w.recordUnrecordedSends(ctx)
+
+	messageFilter, messageOptions := w.batchQuery()
 	messages, err := w.ms.GetMessages(ctx, messageFilter, messageOptions)
 	if err != nil {
-		w.logWithLogger(err, map[string]interface{}{
+		b.err = err
+		tracing.RecordError(span, err)
+		w.logWithLogger(ctx, err, map[string]interface{}{
 			"method": "process",
 			"msg":    "error getting messages",
 		})
 		return
 	}
+	b.selected = int64(len(messages))
+	w.observeBatch(ctx, messageFilter, len(messages))
+	span.SetAttributes(attribute.Int("batch.size", len(messages)))
 
 	if len(messages) == 0 {
-		w.logWithLogger(nil, map[string]interface{}{
+		w.logWithLogger(ctx, nil, map[string]interface{}{
 			"method": "process",
 			"msg":    "no unsent messages found",
 		})
@@ -123,71 +334,125 @@ func (w *Worker) process() {
 		go func(msg sender.MessageTransaction) {
 			defer wg.Done()
 
-			if !msg.IsValid() {
+			ctx, span := startSendSpan(ctx, msg)
+			defer span.End()
+			if msg.RequestID != "" {
+				ctx = requestid.NewContext(ctx, msg.RequestID)
+			}
+
+			// message whose claim expired while its SENT status could not be written is already sent
+			if w.isUnrecorded(msg.ID) {
+				w.logWithLogger(ctx, nil, map[string]interface{}{
+					"method": "process",
+					"msg":    "message is sent, its status is not written yet",
+					"id":     msg.ID,
+				})
+				return
+			}
+
+			switch w.planAction(msg) {
+			case sender.BatchActionInvalidate:
 				// Update status to INVALID
-				w.logWithLogger(nil, map[string]interface{}{
+				w.logWithLogger(ctx, nil, map[string]interface{}{
 					"method": "process",
 					"msg":    "message is invalid",
 					"id":     msg.ID,
 				})
-				err = w.ms.UpdateMessageStatus(ctx, msg.ID, mongostore.STATUS_INVALID, nil)
+				invalid, err := w.ms.UpdateMessageStatus(ctx, msg, mongostore.STATUS_INVALID, mongostore.StatusDetails{})
 				if err != nil {
-					w.logWithLogger(err, map[string]interface{}{
+					w.logWithLogger(ctx, err, map[string]interface{}{
 						"method": "process",
 						"msg":    "error updating message status to INVALID",
 						"id":     msg.ID,
 					})
+					return
 				}
+				w.countSend(b, sendOutcomeInvalid)
+				w.publishMessageEvent(ctx, invalid)
+				return
+			case sender.BatchActionBlock:
+				w.logWithLogger(ctx, nil, map[string]interface{}{
+					"method":    "process",
+					"msg":       "recipient is not allowed in this environment",
+					"id":        msg.ID,
+					"recipient": msg.Recipient,
+				})
+				blocked, err := w.ms.UpdateMessageStatus(ctx, msg, mongostore.STATUS_BLOCKED, mongostore.StatusDetails{Error: w.guard.Reason()})
+				if err != nil {
+					w.logWithLogger(ctx, err, map[string]interface{}{
+						"method": "process",
+						"msg":    "error updating message status to BLOCKED",
+						"id":     msg.ID,
+					})
+					return
+				}
+				w.countSend(b, sendOutcomeBlocked)
+				w.publishMessageEvent(ctx, blocked)
 				return
 			}
 
-			res, err := w.sender.SendMessage(ctx, msg.Recipient, msg.Content)
+			// Claim message so that no other worker sends it until the lease expires
+			leaseUntil := time.Now().Add(w.claimLease)
+			claimed, err := w.ms.UpdateMessageStatus(ctx, msg, mongostore.STATUS_PROCESSING, mongostore.StatusDetails{Provider: w.sender.Name(), LeaseUntil: &leaseUntil})
 			if err != nil {
-				w.logWithLogger(err, map[string]interface{}{
+				w.logWithLogger(ctx, err, map[string]interface{}{
 					"method": "process",
-					"msg":    "error sending message, trying to update status to FAILED",
+					"msg":    "error claiming message",
 					"id":     msg.ID,
 				})
+				return
+			}
+			w.holdClaim(claimed)
+			w.publishMessageEvent(ctx, claimed)
 
-				// Retry 3 times to update status to FAILED
-				for i := 0; i < 3; i++ {
-					err = w.ms.UpdateMessageStatus(ctx, msg.ID, mongostore.STATUS_FAILED, nil)
-					if err == nil {
-						break
-					}
-					time.Sleep(100 * time.Millisecond)
-				}
+			begin := time.Now()
+			res, err := w.sender.SendMessage(ctx, msg.Recipient, msg.Content)
+			w.observeSendLatency(begin)
+			if err != nil {
+				tracing.RecordError(span, err)
+				w.countSend(b, sendOutcomeFailed)
+				w.logWithLogger(ctx, err, map[string]interface{}{
+					"method":    "process",
+					"msg":       "error sending message, trying to update status to FAILED",
+					"id":        msg.ID,
+					"recipient": msg.Recipient,
+				})
+
+				failed, err := w.updateMessageStatus(ctx, claimed, mongostore.STATUS_FAILED, mongostore.StatusDetails{Error: err.Error()})
 				if err != nil {
-					w.logWithLogger(err, map[string]interface{}{
+					w.logWithLogger(ctx, err, map[string]interface{}{
 						"method": "process",
 						"msg":    "error updating messages",
 						"id":     msg.ID,
 					})
+					return
 				}
+				w.dropClaim(claimed.ID)
+				w.publishMessageEvent(ctx, failed)
 				return
 			}
 
-			// Retry updating status to SENT
-			for i := 0; i < 3; i++ {
-				now := time.Now()
-				err = w.ms.UpdateMessageStatus(ctx, msg.ID, mongostore.STATUS_SENT, &now)
-				if err == nil {
-					break
-				}
-				time.Sleep(100 * time.Millisecond)
-			}
+			// send is kept before claim is dropped so that message is neither released on drain nor sent
+			// again when its status cannot be written, the status is written again by the next batch
+			now := time.Now()
+			rec := sentRecord{claimed: claimed, details: mongostore.StatusDetails{SentAt: &now, ProviderMessageID: res.MessageID}}
+			w.holdSent(rec)
+			w.dropClaim(claimed.ID)
+			w.countSend(b, sendOutcomeSent)
+			sent, err := w.recordSent(ctx, rec)
 			if err != nil {
-				w.logWithLogger(err, map[string]interface{}{
+				w.logWithLogger(ctx, err, map[string]interface{}{
 					"method": "process",
 					"msg":    "error updating messages",
 					"id":     msg.ID,
 				})
 				return
 			}
+			w.publishMessageEvent(ctx, sent)
 			// Cache message id
 			err = w.rs.CacheMessageID(ctx, res.MessageID)
 			if err != nil {
-				w.logWithLogger(err, map[string]interface{}{
+				w.logWithLogger(ctx, err, map[string]interface{}{
 					"method": "process",
 					"msg":    "error caching message id",
 					"id":     msg.ID,
@@ -195,7 +460,7 @@ func (w *Worker) process() {
 				return
 			}
 
-			w.logWithLogger(nil, map[string]interface{}{
+			w.logWithLogger(ctx, nil, map[string]interface{}{
 				"method": "process",
 				"msg":    "message sent successfully",
 				"id":     msg.ID,
@@ -205,13 +470,181 @@ func (w *Worker) process() {
 	wg.Wait()
 }
 
-func (w *Worker) logWithLogger(err error, additionalParams map[string]interface{}) {
-	logParams := make([]interface{}, 0, 2+len(additionalParams)*2)
+// batch accumulates outcomes of a batch, sends of a batch are counted concurrently
+type batch struct {
+	startedAt time.Time
+	selected  int64
+	err       error
+	sent      atomic.Int64
+	failed    atomic.Int64
+	invalid   atomic.Int64
+	blocked   atomic.Int64
+}
+
+// startBatch marks a batch started and returns it, cancel cancels the batch
+func (w *Worker) startBatch(cancel context.CancelFunc) *batch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &batch{startedAt: time.Now()}
+	w.inFlight = true
+	w.lastRunAt = b.startedAt
+	w.cancelBatch = cancel
+
+	return b
+}
+
+// finishBatch marks batch b finished and adds its summary to history
+func (w *Worker) finishBatch(b *batch) {
+	summary := sender.BatchSummary{
+		StartedAt:  b.startedAt,
+		DurationMS: float64(time.Since(b.startedAt).Microseconds()) / 1000,
+		Selected:   b.selected,
+		Sent:       b.sent.Load(),
+		Failed:     b.failed.Load(),
+		Invalid:    b.invalid.Load(),
+		Blocked:    b.blocked.Load(),
+	}
+	if b.err != nil {
+		summary.Error = b.err.Error()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.inFlight = false
+	w.cancelBatch = nil
+	w.history.add(summary)
+}
+
+// setNextRunAt sets time of the next scheduled batch
+func (w *Worker) setNextRunAt(t time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.nextRunAt = t
+}
+
+// Status returns state of worker and summaries of up to n recent batches, most recent first. Every
+// kept summary is returned when n is zero.
+func (w *Worker) Status(n int) sender.WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := sender.WorkerStatus{
+		Running:         w.running,
+		IntervalSeconds: workerInterval.Seconds(),
+		BatchSize:       w.limit,
+		Batches:         w.history.recent(n),
+	}
+	if !w.lastRunAt.IsZero() {
+		lastRunAt := w.lastRunAt
+		status.LastRunAt = &lastRunAt
+	}
+	if w.running {
+		nextRunAt := w.nextRunAt
+		status.NextRunAt = &nextRunAt
+	}
+
+	return status
+}
+
+// updateMessageStatus updates message status, retrying 3 times unless the update conflicts
+func (w *Worker) updateMessageStatus(ctx context.Context, mt sender.MessageTransaction, status string, d mongostore.StatusDetails) (sender.MessageTransaction, error) {
+	var updated sender.MessageTransaction
+	var err error
+	for i := 0; i < 3; i++ {
+		updated, err = w.ms.UpdateMessageStatus(ctx, mt, status, d)
+		var conflictErr *mongostore.StatusConflictError
+		if err == nil || errors.As(err, &conflictErr) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return updated, err
+}
+
+// startSendSpan starts span of sending msg linked to span of the request which created msg
+func startSendSpan(ctx context.Context, msg sender.MessageTransaction) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{trace.WithAttributes(attribute.String("message.id", msg.ID.Hex()))}
+	if link, ok := tracing.Link(msg.TraceID, msg.SpanID); ok {
+		opts = append(opts, trace.WithLinks(link))
+	}
+
+	return tracing.Start(ctx, "Worker.send", opts...)
+}
+
+// observeBatch observes size of batch and depth of the queue it is taken from
+func (w *Worker) observeBatch(ctx context.Context, f mongostore.MessageFilter, size int) {
+	if w.metrics == nil {
+		return
+	}
+
+	w.metrics.BatchSize.Observe(float64(size))
+
+	depth, err := w.ms.Count(ctx, f)
+	if err != nil {
+		w.logWithLogger(ctx, err, map[string]interface{}{
+			"method": "observeBatch",
+			"msg":    "error counting queued messages",
+		})
+		return
+	}
+	w.metrics.QueueDepth.Set(float64(depth))
+}
+
+// countSend counts a send of outcome in batch b
+func (w *Worker) countSend(b *batch, outcome string) {
+	switch outcome {
+	case sendOutcomeSent:
+		b.sent.Add(1)
+	case sendOutcomeFailed:
+		b.failed.Add(1)
+	case sendOutcomeInvalid:
+		b.invalid.Add(1)
+	case sendOutcomeBlocked:
+		b.blocked.Add(1)
+	}
+
+	if w.metrics == nil {
+		return
+	}
+
+	w.metrics.Sends.With("outcome", outcome).Add(1)
+}
+
+// observeSendLatency observes latency of a provider call started at begin
+func (w *Worker) observeSendLatency(begin time.Time) {
+	if w.metrics == nil {
+		return
+	}
+
+	w.metrics.SendLatency.Observe(time.Since(begin).Seconds())
+}
+
+// publishMessageEvent publishes status change of message, events are best effort so failures are only logged
+func (w *Worker) publishMessageEvent(ctx context.Context, mt sender.MessageTransaction) {
+	if err := w.rs.PublishMessageEvent(ctx, newMessageEvent(mt)); err != nil {
+		w.logWithLogger(ctx, err, map[string]interface{}{
+			"method": "publishMessageEvent",
+			"msg":    "error publishing message event",
+			"id":     mt.ID,
+		})
+	}
+}
+
+func (w *Worker) logWithLogger(ctx context.Context, err error, additionalParams map[string]interface{}) {
+	logParams := make([]interface{}, 0, 4+len(additionalParams)*2)
 
 	for k, v := range additionalParams {
 		logParams = append(logParams, k, v)
 	}
 
+	if id := requestid.FromContext(ctx); id != "" {
+		logParams = append(logParams, "requestID", id)
+	}
+
 	if err != nil {
 		logParams = append(logParams, "error", err.Error())
 		_ = level.Error(w.l).Log(logParams...)
@@ -1,18 +1,25 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
 	"fmt"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/mkaykisiz/sender"
 	"github.com/mkaykisiz/sender/internal/client/messageclient"
 	mockmessagehook "github.com/mkaykisiz/sender/internal/mock/client/messagehook"
 	mockmongostore "github.com/mkaykisiz/sender/internal/mock/store/mongo"
 	mockredisstore "github.com/mkaykisiz/sender/internal/mock/store/redis"
+	"github.com/mkaykisiz/sender/internal/recipientguard"
+	"github.com/mkaykisiz/sender/internal/requestid"
 	mongostore "github.com/mkaykisiz/sender/internal/store/mongo"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -99,13 +106,14 @@ func TestWorker_Process(t *testing.T) {
 	t.Run("process with no messages", func(t *testing.T) {
 		mockMongoStore := mockmongostore.NewStore()
 		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 		mockMessageClient := mockmessagehook.NewClient()
 		logger := log.NewNopLogger()
 
 		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, logger, 2)
 
 		mockMongoStore.On("GetMessages", mock.Anything, 
-			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 			mongostore.MessageOptions{Limit: int64(2)}).Return([]sender.MessageTransaction{}, nil).Once()
 
 		// Call process directly
@@ -117,6 +125,7 @@ func TestWorker_Process(t *testing.T) {
 	t.Run("process with successful message sending", func(t *testing.T) {
 		mockMongoStore := mockmongostore.NewStore()
 		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 		mockMessageClient := mockmessagehook.NewClient()
 		logger := log.NewNopLogger()
 
@@ -134,14 +143,20 @@ func TestWorker_Process(t *testing.T) {
 		}
 
 		mockMongoStore.On("GetMessages", mock.Anything,
-			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 			mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
 
 		mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message").
 			Return(&messageclient.MessageResponse{MessageID: msgID.Hex()}, nil).Once()
 
-		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msgID, mongostore.STATUS_SENT, mock.Anything).
-			Return(nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_PROCESSING, mock.MatchedBy(func(d mongostore.StatusDetails) bool {
+			return d.Provider == "mock" && d.LeaseUntil != nil && d.LeaseUntil.After(time.Now().Add(defaultClaimLease-time.Minute))
+		})).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_SENT, mock.MatchedBy(func(d mongostore.StatusDetails) bool {
+			return d.SentAt != nil && d.ProviderMessageID == msgID.Hex()
+		})).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_SENT}, nil).Once()
 
 		mockRedisStore.On("CacheMessageID", mock.Anything, msgID.Hex()).
 			Return(nil).Once()
@@ -156,6 +171,7 @@ func TestWorker_Process(t *testing.T) {
 	t.Run("process with failed message sending", func(t *testing.T) {
 		mockMongoStore := mockmongostore.NewStore()
 		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 		mockMessageClient := mockmessagehook.NewClient()
 		logger := log.NewNopLogger()
 
@@ -173,14 +189,16 @@ func TestWorker_Process(t *testing.T) {
 		}
 
 		mockMongoStore.On("GetMessages", mock.Anything,
-			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 			mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
 
 		mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message").
 			Return((*messageclient.MessageResponse)(nil), errors.New("send failed")).Once()
 
-		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msgID, mongostore.STATUS_FAILED, mock.Anything).
-			Return(nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_PROCESSING, mock.Anything).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_FAILED, mock.Anything).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_FAILED}, nil).Once()
 
 		worker.process()
 
@@ -191,13 +209,14 @@ func TestWorker_Process(t *testing.T) {
 	t.Run("process with database error on fetch", func(t *testing.T) {
 		mockMongoStore := mockmongostore.NewStore()
 		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 		mockMessageClient := mockmessagehook.NewClient()
 		logger := log.NewNopLogger()
 
 		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, logger, 2)
 
 		mockMongoStore.On("GetMessages", mock.Anything,
-			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 			mongostore.MessageOptions{Limit: int64(2)}).Return([]sender.MessageTransaction(nil), errors.New("db error")).Once()
 
 		worker.process()
@@ -208,6 +227,7 @@ func TestWorker_Process(t *testing.T) {
 	t.Run("process with update status error", func(t *testing.T) {
 		mockMongoStore := mockmongostore.NewStore()
 		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 		mockMessageClient := mockmessagehook.NewClient()
 		logger := log.NewNopLogger()
 
@@ -225,14 +245,16 @@ func TestWorker_Process(t *testing.T) {
 		}
 
 		mockMongoStore.On("GetMessages", mock.Anything,
-			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 			mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
 
 		mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message").
 			Return(&messageclient.MessageResponse{}, nil).Once()
 
-		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(errors.New("update error")).Maybe()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_PROCESSING, mock.Anything).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{}, errors.New("update error")).Times(3)
 
 		fmt.Printf("Expected calls: %+v\n", mockMongoStore.ExpectedCalls)
 
@@ -245,6 +267,7 @@ func TestWorker_Process(t *testing.T) {
 	t.Run("process with redis cache error", func(t *testing.T) {
 		mockMongoStore := mockmongostore.NewStore()
 		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 		mockMessageClient := mockmessagehook.NewClient()
 		logger := log.NewNopLogger()
 
@@ -262,14 +285,16 @@ func TestWorker_Process(t *testing.T) {
 		}
 
 		mockMongoStore.On("GetMessages", mock.Anything,
-			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 			mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
 
 		mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message").
 			Return(&messageclient.MessageResponse{MessageID: msgID.Hex()}, nil).Once()
 
-		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msgID, mongostore.STATUS_SENT, mock.Anything).
-			Return(nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_PROCESSING, mock.Anything).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_SENT}, nil).Once()
 
 		mockRedisStore.On("CacheMessageID", mock.Anything, msgID.Hex()).
 			Return(errors.New("redis error")).Once()
@@ -284,6 +309,7 @@ func TestWorker_Process(t *testing.T) {
 	t.Run("process with message exceeding character limit", func(t *testing.T) {
 		mockMongoStore := mockmongostore.NewStore()
 		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 		mockMessageClient := mockmessagehook.NewClient()
 		logger := log.NewNopLogger()
 
@@ -306,11 +332,11 @@ func TestWorker_Process(t *testing.T) {
 		}
 
 		mockMongoStore.On("GetMessages", mock.Anything,
-			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 			mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
 
-		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msgID, mongostore.STATUS_INVALID, mock.Anything).
-			Return(nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_INVALID, mock.Anything).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_INVALID}, nil).Once()
 
 		worker.process()
 
@@ -320,9 +346,175 @@ func TestWorker_Process(t *testing.T) {
 	})
 }
 
+func TestWorker_ProcessClaimConflict(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockRedisStore := mockredisstore.NewStore()
+	mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockMessageClient := mockmessagehook.NewClient()
+	logger := log.NewNopLogger()
+
+	worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, logger, 2)
+
+	msgID := primitive.NewObjectID()
+	messages := []sender.MessageTransaction{
+		{
+			ID:        msgID,
+			Content:   "Test message",
+			Recipient: "+905551234567",
+			Status:    mongostore.STATUS_PENDING,
+			CreatedAt: time.Now(),
+		},
+	}
+
+	mockMongoStore.On("GetMessages", mock.Anything,
+		mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
+		mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
+
+	conflictErr := &mongostore.StatusConflictError{ID: msgID, From: mongostore.STATUS_PENDING, To: mongostore.STATUS_PROCESSING}
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_PROCESSING, mock.Anything).
+		Return(sender.MessageTransaction{}, conflictErr).Once()
+
+	worker.process()
+
+	mockMongoStore.AssertExpectations(t)
+	// Message claimed by another worker should NOT be sent
+	mockMessageClient.AssertNotCalled(t, "SendMessage")
+}
+
+func TestWorker_ClaimLease(t *testing.T) {
+	t.Run("rejects lease shorter than batch", func(t *testing.T) {
+		worker := NewWorker(mockmessagehook.NewClient(), mockmongostore.NewStore(), mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		assert.Error(t, worker.SetClaimLease(batchTimeout))
+		assert.NoError(t, worker.SetClaimLease(time.Hour))
+		assert.Equal(t, time.Hour, worker.claimLease)
+	})
+
+	t.Run("claims message whose lease expired again", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+		mockMessageClient := mockmessagehook.NewClient()
+
+		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+		assert.NoError(t, worker.SetClaimLease(time.Hour))
+
+		msgID := primitive.NewObjectID()
+		expired := time.Now().Add(-time.Minute)
+		messages := []sender.MessageTransaction{
+			{
+				ID:         msgID,
+				Content:    "Test message",
+				Recipient:  "+905551234567",
+				Status:     mongostore.STATUS_PROCESSING,
+				LeaseUntil: &expired,
+				CreatedAt:  time.Now(),
+			},
+		}
+
+		mockMongoStore.On("GetMessages", mock.Anything,
+			mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
+			mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_PROCESSING, mock.MatchedBy(func(d mongostore.StatusDetails) bool {
+			return d.LeaseUntil != nil && d.LeaseUntil.After(time.Now().Add(time.Hour-time.Minute))
+		})).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_PROCESSING, Version: 2}, nil).Once()
+		mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message").
+			Return(&messageclient.MessageResponse{MessageID: msgID.Hex()}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_SENT}, nil).Once()
+		mockRedisStore.On("CacheMessageID", mock.Anything, msgID.Hex()).Return(nil).Once()
+
+		worker.process()
+
+		mockMongoStore.AssertExpectations(t)
+		mockMessageClient.AssertExpectations(t)
+	})
+}
+
+func TestWorker_UnrecordedSend(t *testing.T) {
+	messageFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true}
+	msg := sender.MessageTransaction{ID: primitive.NewObjectID(), Content: "Test message", Recipient: "+905551234567", Status: mongostore.STATUS_PENDING}
+	claimed := sender.MessageTransaction{ID: msg.ID, Content: msg.Content, Recipient: msg.Recipient, Status: mongostore.STATUS_PROCESSING, Version: 1}
+
+	t.Run("next batch writes status without sending again", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+		mockMessageClient := mockmessagehook.NewClient()
+		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mock.Anything).Return([]sender.MessageTransaction{msg}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msg, mongostore.STATUS_PROCESSING, mock.Anything).Return(claimed, nil).Once()
+		mockMessageClient.On("SendMessage", mock.Anything, msg.Recipient, msg.Content).
+			Return(&messageclient.MessageResponse{MessageID: "provider-1"}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, claimed, mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{}, errors.New("update error")).Times(3)
+
+		worker.process()
+
+		assert.True(t, worker.isUnrecorded(msg.ID))
+
+		// claim expired before the status is written, the message is selected again
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, claimed, mongostore.STATUS_SENT, mock.MatchedBy(func(d mongostore.StatusDetails) bool {
+			return d.ProviderMessageID == "provider-1"
+		})).Return(sender.MessageTransaction{}, errors.New("update error")).Times(3)
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mock.Anything).Return([]sender.MessageTransaction{claimed}, nil).Once()
+
+		worker.process()
+
+		mockMessageClient.AssertNumberOfCalls(t, "SendMessage", 1)
+		mockMongoStore.AssertNumberOfCalls(t, "UpdateMessageStatus", 7)
+
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, claimed, mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{ID: msg.ID, Status: mongostore.STATUS_SENT, Version: 2}, nil).Once()
+
+		err := worker.Drain(context.Background())
+
+		assert.NoError(t, err)
+		assert.False(t, worker.isUnrecorded(msg.ID))
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
+func TestWorker_UpdateMessageStatus(t *testing.T) {
+	t.Run("does not retry conflicts", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		msg := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_SENT, Version: 3}
+		conflictErr := &mongostore.StatusConflictError{ID: msg.ID, From: msg.Status, To: mongostore.STATUS_FAILED, Version: msg.Version}
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msg, mongostore.STATUS_FAILED, mock.Anything).
+			Return(sender.MessageTransaction{}, conflictErr).Once()
+
+		_, err := worker.updateMessageStatus(context.Background(), msg, mongostore.STATUS_FAILED, mongostore.StatusDetails{})
+
+		assert.ErrorIs(t, err, conflictErr)
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("retries other errors", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		msg := sender.MessageTransaction{ID: primitive.NewObjectID(), Status: mongostore.STATUS_PROCESSING, Version: 1}
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msg, mongostore.STATUS_FAILED, mock.Anything).
+			Return(sender.MessageTransaction{}, errors.New("db error")).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, msg, mongostore.STATUS_FAILED, mock.Anything).
+			Return(sender.MessageTransaction{ID: msg.ID, Status: mongostore.STATUS_FAILED, Version: 2}, nil).Once()
+
+		updated, err := worker.updateMessageStatus(context.Background(), msg, mongostore.STATUS_FAILED, mongostore.StatusDetails{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), updated.Version)
+		mockMongoStore.AssertExpectations(t)
+	})
+}
+
 func TestWorker_MultipleMessages(t *testing.T) {
 	mockMongoStore := mockmongostore.NewStore()
 	mockRedisStore := mockredisstore.NewStore()
+	mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
 	mockMessageClient := mockmessagehook.NewClient()
 	logger := log.NewNopLogger()
 
@@ -348,7 +540,7 @@ func TestWorker_MultipleMessages(t *testing.T) {
 	}
 
 	mockMongoStore.On("GetMessages", mock.Anything,
-		mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}},
+		mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true},
 		mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
 
 	mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message 1").
@@ -356,10 +548,14 @@ func TestWorker_MultipleMessages(t *testing.T) {
 	mockMessageClient.On("SendMessage", mock.Anything, "+905559876543", "Test message 2").
 		Return(&messageclient.MessageResponse{MessageID: msgID2.Hex()}, nil).Once()
 
-	mockMongoStore.On("UpdateMessageStatus", mock.Anything, msgID1, mongostore.STATUS_SENT, mock.Anything).
-		Return(nil).Once()
-	mockMongoStore.On("UpdateMessageStatus", mock.Anything, msgID2, mongostore.STATUS_SENT, mock.Anything).
-		Return(nil).Once()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID1), mongostore.STATUS_PROCESSING, mock.Anything).
+		Return(sender.MessageTransaction{ID: msgID1, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID1), mongostore.STATUS_SENT, mock.Anything).
+		Return(sender.MessageTransaction{ID: msgID1, Status: mongostore.STATUS_SENT}, nil).Once()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID2), mongostore.STATUS_PROCESSING, mock.Anything).
+		Return(sender.MessageTransaction{ID: msgID2, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID2), mongostore.STATUS_SENT, mock.Anything).
+		Return(sender.MessageTransaction{ID: msgID2, Status: mongostore.STATUS_SENT}, nil).Once()
 
 	mockRedisStore.On("CacheMessageID", mock.Anything, msgID1.Hex()).
 		Return(nil).Once()
@@ -372,3 +568,370 @@ func TestWorker_MultipleMessages(t *testing.T) {
 	mockMessageClient.AssertExpectations(t)
 	mockRedisStore.AssertExpectations(t)
 }
+
+func TestWorker_Metrics(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockRedisStore := mockredisstore.NewStore()
+	mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockMessageClient := mockmessagehook.NewClient()
+
+	sends := stdprometheus.NewCounterVec(stdprometheus.CounterOpts{Name: "sends_total"}, []string{"outcome"})
+	latency := stdprometheus.NewHistogramVec(stdprometheus.HistogramOpts{Name: "send_latency_seconds"}, []string{})
+	batchSize := generic.NewHistogram("batch_size", 10)
+	queueDepth := generic.NewGauge("queue_depth")
+
+	worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+	worker.SetMetrics(&WorkerMetrics{
+		BatchSize:   batchSize,
+		Sends:       kitprometheus.NewCounter(sends),
+		SendLatency: kitprometheus.NewHistogram(latency),
+		QueueDepth:  queueDepth,
+	})
+
+	msgID1 := primitive.NewObjectID()
+	msgID2 := primitive.NewObjectID()
+	messages := []sender.MessageTransaction{
+		{ID: msgID1, Content: "Test message 1", Recipient: "+905551234567", Status: mongostore.STATUS_PENDING},
+		{ID: msgID2, Content: "Test message 2", Recipient: "+905559876543", Status: mongostore.STATUS_PENDING},
+	}
+	messageFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true}
+
+	mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
+	mockMongoStore.On("Count", mock.Anything, messageFilter).Return(int64(7), nil).Once()
+
+	mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message 1").
+		Return(&messageclient.MessageResponse{MessageID: msgID1.Hex()}, nil).Once()
+	mockMessageClient.On("SendMessage", mock.Anything, "+905559876543", "Test message 2").
+		Return((*messageclient.MessageResponse)(nil), errors.New("send failed")).Once()
+
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_PROCESSING, mock.Anything).
+		Return(sender.MessageTransaction{Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Twice()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_SENT, mock.Anything).
+		Return(sender.MessageTransaction{Status: mongostore.STATUS_SENT}, nil).Once()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_FAILED, mock.Anything).
+		Return(sender.MessageTransaction{Status: mongostore.STATUS_FAILED}, nil).Once()
+	mockRedisStore.On("CacheMessageID", mock.Anything, msgID1.Hex()).Return(nil).Once()
+
+	worker.process()
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(sends.WithLabelValues("sent")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sends.WithLabelValues("failed")))
+	assert.Equal(t, 1, testutil.CollectAndCount(latency))
+	assert.Equal(t, float64(7), queueDepth.Value())
+	assert.Equal(t, float64(2), batchSize.Quantile(0.5))
+	mockMongoStore.AssertExpectations(t)
+	mockMessageClient.AssertExpectations(t)
+}
+
+func TestWorker_ProcessRequestID(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockRedisStore := mockredisstore.NewStore()
+	mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockMessageClient := mockmessagehook.NewClient()
+
+	worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+
+	msgID := primitive.NewObjectID()
+	messages := []sender.MessageTransaction{
+		{ID: msgID, Content: "Test message", Recipient: "+905551234567", Status: mongostore.STATUS_PENDING, RequestID: "req-123"},
+	}
+
+	mockMongoStore.On("GetMessages", mock.Anything, mock.Anything, mock.Anything).Return(messages, nil).Once()
+	mockMessageClient.On("SendMessage", mock.MatchedBy(func(ctx context.Context) bool {
+		return requestid.FromContext(ctx) == "req-123"
+	}), "+905551234567", "Test message").Return(&messageclient.MessageResponse{MessageID: msgID.Hex()}, nil).Once()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_PROCESSING, mock.Anything).
+		Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+	mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(msgID), mongostore.STATUS_SENT, mock.Anything).
+		Return(sender.MessageTransaction{ID: msgID, Status: mongostore.STATUS_SENT}, nil).Once()
+	mockRedisStore.On("CacheMessageID", mock.Anything, msgID.Hex()).Return(nil).Once()
+
+	worker.process()
+
+	mockMessageClient.AssertExpectations(t)
+}
+
+// matchMessage matches message transaction argument by id
+func matchMessage(id primitive.ObjectID) interface{} {
+	return mock.MatchedBy(func(mt sender.MessageTransaction) bool {
+		return mt.ID == id
+	})
+}
+
+func TestWorker_Status(t *testing.T) {
+	messageFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true}
+
+	t.Run("records batch outcomes", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+		mockMessageClient := mockmessagehook.NewClient()
+		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+
+		msgID := primitive.NewObjectID()
+		messages := []sender.MessageTransaction{
+			{ID: msgID, Content: "Test message", Recipient: "+905551234567", Status: mongostore.STATUS_PENDING},
+			{ID: primitive.NewObjectID(), Content: "", Recipient: "+905559876543", Status: mongostore.STATUS_PENDING},
+		}
+
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mongostore.MessageOptions{Limit: int64(2)}).Return(messages, nil).Once()
+		mockMessageClient.On("SendMessage", mock.Anything, "+905551234567", "Test message").
+			Return(&messageclient.MessageResponse{MessageID: msgID.Hex()}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_INVALID, mock.Anything).
+			Return(sender.MessageTransaction{Status: mongostore.STATUS_INVALID}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_PROCESSING, mock.Anything).
+			Return(sender.MessageTransaction{Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{Status: mongostore.STATUS_SENT}, nil).Once()
+		mockRedisStore.On("CacheMessageID", mock.Anything, msgID.Hex()).Return(nil).Once()
+
+		worker.process()
+
+		status := worker.Status(0)
+		assert.False(t, status.Running)
+		assert.Nil(t, status.NextRunAt)
+		assert.NotNil(t, status.LastRunAt)
+		assert.Equal(t, workerInterval.Seconds(), status.IntervalSeconds)
+		assert.Equal(t, int64(2), status.BatchSize)
+		if assert.Len(t, status.Batches, 1) {
+			assert.Equal(t, int64(2), status.Batches[0].Selected)
+			assert.Equal(t, int64(1), status.Batches[0].Sent)
+			assert.Equal(t, int64(0), status.Batches[0].Failed)
+			assert.Equal(t, int64(1), status.Batches[0].Invalid)
+			assert.Empty(t, status.Batches[0].Error)
+		}
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("records selection error", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mock.Anything).Return([]sender.MessageTransaction(nil), errors.New("db error")).Once()
+
+		worker.process()
+
+		batches := worker.Status(0).Batches
+		if assert.Len(t, batches, 1) {
+			assert.Equal(t, "db error", batches[0].Error)
+		}
+	})
+
+	t.Run("keeps recent batches", func(t *testing.T) {
+		worker := NewWorker(mockmessagehook.NewClient(), mockmongostore.NewStore(), mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		for i := 1; i <= batchHistorySize+5; i++ {
+			worker.history.add(sender.BatchSummary{Selected: int64(i)})
+		}
+
+		batches := worker.Status(0).Batches
+		assert.Len(t, batches, batchHistorySize)
+		assert.Equal(t, int64(batchHistorySize+5), batches[0].Selected)
+		assert.Equal(t, int64(6), batches[batchHistorySize-1].Selected)
+
+		batches = worker.Status(3).Batches
+		assert.Equal(t, []int64{25, 24, 23}, []int64{batches[0].Selected, batches[1].Selected, batches[2].Selected})
+	})
+
+	t.Run("running worker reports next run", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockMongoStore.On("GetMessages", mock.Anything, mock.Anything, mock.Anything).Return([]sender.MessageTransaction{}, nil).Maybe()
+		mockMongoStore.On("Count", mock.Anything, mock.Anything).Return(int64(0), nil).Maybe()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		worker.Start()
+		defer worker.Stop()
+
+		status := worker.Status(0)
+		assert.True(t, status.Running)
+		if assert.NotNil(t, status.NextRunAt) {
+			assert.WithinDuration(t, time.Now().Add(workerInterval), *status.NextRunAt, time.Second)
+		}
+	})
+}
+
+func TestWorker_RunNow(t *testing.T) {
+	t.Run("runs batch", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		done := make(chan struct{})
+		mockMongoStore.On("GetMessages", mock.Anything, mock.Anything, mock.Anything).
+			Return([]sender.MessageTransaction{}, nil).Once().
+			Run(func(mock.Arguments) { close(done) })
+
+		assert.True(t, worker.RunNow())
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("batch did not run")
+		}
+		mockMongoStore.AssertExpectations(t)
+	})
+
+	t.Run("batch already running", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+		worker.batchMu.Lock()
+		defer worker.batchMu.Unlock()
+
+		assert.False(t, worker.RunNow())
+		mockMongoStore.AssertNotCalled(t, "GetMessages", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWorker_DryRun(t *testing.T) {
+	mockMongoStore := mockmongostore.NewStore()
+	mockMessageClient := mockmessagehook.NewClient()
+	worker := NewWorker(mockMessageClient, mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+
+	valid := sender.MessageTransaction{ID: primitive.NewObjectID(), Content: "Test message", Recipient: "+905551234567", Status: mongostore.STATUS_PENDING}
+	invalid := sender.MessageTransaction{ID: primitive.NewObjectID(), Content: "", Recipient: "+905559876543", Status: mongostore.STATUS_FAILED}
+	messageFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true}
+
+	mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mongostore.MessageOptions{Limit: int64(2)}).
+		Return([]sender.MessageTransaction{valid, invalid}, nil).Once()
+
+	planned, err := worker.DryRun(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []sender.PlannedSend{
+		{Message: sender.NewResponseMessage(valid), Action: sender.BatchActionSend, Provider: "mock"},
+		{Message: sender.NewResponseMessage(invalid), Action: sender.BatchActionInvalidate},
+	}, planned)
+	mockMongoStore.AssertExpectations(t)
+	mockMongoStore.AssertNotCalled(t, "UpdateMessageStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockMessageClient.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything, mock.Anything)
+	assert.Empty(t, worker.Status(0).Batches)
+}
+
+func TestWorker_RecipientGuard(t *testing.T) {
+	messageFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true}
+	allowed := sender.MessageTransaction{ID: primitive.NewObjectID(), Content: "Test message", Recipient: "+905550001111", Status: mongostore.STATUS_PENDING}
+	blocked := sender.MessageTransaction{ID: primitive.NewObjectID(), Content: "Test message", Recipient: "+905559876543", Status: mongostore.STATUS_FAILED}
+
+	t.Run("blocks recipients outside allow-list", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+		mockMessageClient := mockmessagehook.NewClient()
+		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+		worker.SetRecipientGuard(recipientguard.NewGuard("stg", "", "+90555000"))
+
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mongostore.MessageOptions{Limit: int64(2)}).
+			Return([]sender.MessageTransaction{allowed, blocked}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(blocked.ID), mongostore.STATUS_BLOCKED,
+			mongostore.StatusDetails{Error: "recipient is not in the allow-list of stg environment"}).
+			Return(sender.MessageTransaction{ID: blocked.ID, Status: mongostore.STATUS_BLOCKED}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(allowed.ID), mongostore.STATUS_PROCESSING, mock.Anything).
+			Return(sender.MessageTransaction{ID: allowed.ID, Status: mongostore.STATUS_PROCESSING, Version: 1}, nil).Once()
+		mockMessageClient.On("SendMessage", mock.Anything, allowed.Recipient, allowed.Content).
+			Return(&messageclient.MessageResponse{MessageID: "provider-1"}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, matchMessage(allowed.ID), mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{ID: allowed.ID, Status: mongostore.STATUS_SENT}, nil).Once()
+		mockRedisStore.On("CacheMessageID", mock.Anything, "provider-1").Return(nil).Once()
+
+		worker.process()
+
+		mockMongoStore.AssertExpectations(t)
+		mockMessageClient.AssertNotCalled(t, "SendMessage", mock.Anything, blocked.Recipient, mock.Anything)
+		if batches := worker.Status(0).Batches; assert.Len(t, batches, 1) {
+			assert.Equal(t, int64(1), batches[0].Sent)
+			assert.Equal(t, int64(1), batches[0].Blocked)
+		}
+	})
+
+	t.Run("dry run and health show guard", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		worker := NewWorker(mockmessagehook.NewClient(), mockMongoStore, mockredisstore.NewStore(), log.NewNopLogger(), 2)
+		worker.SetRecipientGuard(recipientguard.NewGuard("stg", "", "+90555000"))
+
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mongostore.MessageOptions{Limit: int64(2)}).
+			Return([]sender.MessageTransaction{allowed, blocked}, nil).Once()
+
+		planned, err := worker.DryRun(context.Background())
+
+		assert.NoError(t, err)
+		if assert.Len(t, planned, 2) {
+			assert.Equal(t, sender.BatchActionSend, planned[0].Action)
+			assert.Equal(t, sender.BatchActionBlock, planned[1].Action)
+			assert.Empty(t, planned[1].Provider)
+		}
+
+		h := worker.Health()
+		assert.Equal(t, sender.ComponentStatusStopped, h.Status)
+		assert.Equal(t, map[string]interface{}{
+			"environment":      "stg",
+			"allowed_numbers":  0,
+			"allowed_prefixes": []string{"+90555000"},
+		}, h.Details["recipient_guard"])
+	})
+}
+
+func TestWorker_Drain(t *testing.T) {
+	messageFilter := mongostore.MessageFilter{Status: []string{mongostore.STATUS_PENDING, mongostore.STATUS_FAILED}, Due: true, ExpiredClaims: true}
+	msg := sender.MessageTransaction{ID: primitive.NewObjectID(), Content: "Test message", Recipient: "+905551234567", Status: mongostore.STATUS_PENDING}
+	claimed := sender.MessageTransaction{ID: msg.ID, Content: msg.Content, Recipient: msg.Recipient, Status: mongostore.STATUS_PROCESSING, Version: 1}
+
+	t.Run("waits for running batch", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+		mockMessageClient := mockmessagehook.NewClient()
+		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+
+		unblock := make(chan struct{})
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mock.Anything).Return([]sender.MessageTransaction{msg}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_PROCESSING, mock.Anything).Return(claimed, nil).Once()
+		mockMessageClient.On("SendMessage", mock.Anything, msg.Recipient, msg.Content).
+			Run(func(mock.Arguments) { <-unblock }).
+			Return(&messageclient.MessageResponse{MessageID: "provider-1"}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_SENT, mock.Anything).
+			Return(sender.MessageTransaction{ID: msg.ID, Status: mongostore.STATUS_SENT}, nil).Once()
+		mockRedisStore.On("CacheMessageID", mock.Anything, "provider-1").Return(nil).Once()
+
+		assert.True(t, worker.RunNow())
+		time.AfterFunc(50*time.Millisecond, func() { close(unblock) })
+
+		ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cf()
+		err := worker.Drain(ctx)
+
+		assert.NoError(t, err)
+		mockMongoStore.AssertExpectations(t)
+		mockMongoStore.AssertNotCalled(t, "UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_PENDING, mock.Anything)
+		assert.False(t, worker.RunNow())
+	})
+
+	t.Run("cancels batch and releases claims after deadline", func(t *testing.T) {
+		mockMongoStore := mockmongostore.NewStore()
+		mockRedisStore := mockredisstore.NewStore()
+		mockRedisStore.On("PublishMessageEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+		mockMessageClient := mockmessagehook.NewClient()
+		worker := NewWorker(mockMessageClient, mockMongoStore, mockRedisStore, log.NewNopLogger(), 2)
+
+		mockMongoStore.On("GetMessages", mock.Anything, messageFilter, mock.Anything).Return([]sender.MessageTransaction{msg}, nil).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_PROCESSING, mock.Anything).Return(claimed, nil).Once()
+		mockMessageClient.On("SendMessage", mock.Anything, msg.Recipient, msg.Content).
+			Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+			Return((*messageclient.MessageResponse)(nil), context.Canceled).Once()
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, mock.Anything, mongostore.STATUS_FAILED, mock.Anything).
+			Return(sender.MessageTransaction{}, context.Canceled)
+		mockMongoStore.On("UpdateMessageStatus", mock.Anything, claimed, mongostore.STATUS_PENDING, mongostore.StatusDetails{}).
+			Return(sender.MessageTransaction{ID: msg.ID, Status: mongostore.STATUS_PENDING, Version: 2}, nil).Once()
+
+		assert.True(t, worker.RunNow())
+
+		ctx, cf := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cf()
+		err := worker.Drain(ctx)
+
+		assert.Error(t, err)
+		mockMongoStore.AssertExpectations(t)
+		if batches := worker.Status(0).Batches; assert.Len(t, batches, 1) {
+			assert.Equal(t, int64(1), batches[0].Failed)
+		}
+	})
+}
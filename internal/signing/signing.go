@@ -0,0 +1,171 @@
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signature headers
+const (
+	HeaderKeyID     = "X-Signature-Key-Id"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderNonce     = "X-Signature-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// signatureVersion prefixes signatures so that the signed content can change without ambiguity
+const signatureVersion = "v1"
+
+const nonceBytes = 16
+
+// verification errors
+var (
+	ErrMissingSignature   = errors.New("signature headers are missing")
+	ErrMalformedSignature = errors.New("signature headers are malformed")
+	ErrUnknownKey         = errors.New("signing key is unknown")
+	ErrExpiredSignature   = errors.New("signature timestamp is outside of tolerance")
+	ErrInvalidSignature   = errors.New("signature does not match")
+	ErrReplayedRequest    = errors.New("signature nonce is already used")
+)
+
+// Keys holds signing secrets by key id. Keys are rotated by adding the new key, moving signers to it
+// and removing the old key once nothing signs with it anymore.
+type Keys map[string]string
+
+// ParseKeys parses comma separated id:secret pairs
+func ParseKeys(s string) (Keys, error) {
+	keys := Keys{}
+	for i, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("parsing signing keys failed, entry %d is not an id:secret pair", i+1)
+		}
+		keys[id] = secret
+	}
+
+	return keys, nil
+}
+
+// Sign returns hex encoded hmac-sha256 signature of timestamp, nonce, method, request uri and body. Request
+// uri is the path together with the query, so that neither can be changed without breaking the signature.
+func Sign(secret, timestamp, nonce, method, uri string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n", timestamp, nonce, method, uri)
+	_, _ = mac.Write(body)
+
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signer signs outbound requests with a single key
+type Signer struct {
+	keyID  string
+	secret string
+	now    func() time.Time
+}
+
+// NewSigner creates and returns signer
+func NewSigner(keyID, secret string) *Signer {
+	return &Signer{
+		keyID:  keyID,
+		secret: secret,
+		now:    time.Now,
+	}
+}
+
+// SignRequest sets signature headers of request with body
+func (s *Signer) SignRequest(r *http.Request, body []byte) error {
+	b := make([]byte, nonceBytes)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("generating signature nonce failed, %s", err.Error())
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	nonce := hex.EncodeToString(b)
+
+	r.Header.Set(HeaderKeyID, s.keyID)
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, Sign(s.secret, timestamp, nonce, r.Method, r.URL.RequestURI(), body))
+
+	return nil
+}
+
+// NonceStore defines behaviors of store remembering used nonces
+type NonceStore interface {
+	// ClaimNonce stores nonce of key for ttl, it returns false if the nonce is stored already
+	ClaimNonce(ctx context.Context, keyID, nonce string, ttl time.Duration) (bool, error)
+}
+
+// Verifier verifies signed inbound requests
+type Verifier struct {
+	keys      Keys
+	tolerance time.Duration
+	ns        NonceStore
+	now       func() time.Time
+}
+
+// NewVerifier creates and returns verifier accepting requests signed with any of keys whose timestamp
+// is within tolerance of now
+func NewVerifier(keys Keys, tolerance time.Duration, ns NonceStore) *Verifier {
+	return &Verifier{
+		keys:      keys,
+		tolerance: tolerance,
+		ns:        ns,
+		now:       time.Now,
+	}
+}
+
+// Verify verifies signature headers of request with body and claims its nonce, so that the same
+// request is not accepted twice
+func (v *Verifier) Verify(ctx context.Context, r *http.Request, body []byte) error {
+	keyID := r.Header.Get(HeaderKeyID)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	signature := r.Header.Get(HeaderSignature)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	secret, ok := v.keys[keyID]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMalformedSignature
+	}
+	if skew := v.now().Sub(time.Unix(unix, 0)); skew > v.tolerance || skew < -v.tolerance {
+		return ErrExpiredSignature
+	}
+
+	expected := Sign(secret, timestamp, nonce, r.Method, r.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	// a nonce has to be remembered as long as its timestamp is accepted, in both directions
+	claimed, err := v.ns.ClaimNonce(ctx, keyID, nonce, 2*v.tolerance)
+	if err != nil {
+		return fmt.Errorf("claiming signature nonce failed, %s", err.Error())
+	}
+	if !claimed {
+		return ErrReplayedRequest
+	}
+
+	return nil
+}
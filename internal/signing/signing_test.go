@@ -0,0 +1,111 @@
+package signing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nonceStore map[string]bool
+
+func (ns nonceStore) ClaimNonce(_ context.Context, keyID, nonce string, _ time.Duration) (bool, error) {
+	if ns[keyID+":"+nonce] {
+		return false, nil
+	}
+	ns[keyID+":"+nonce] = true
+	return true, nil
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	body := []byte(`{"messageId":"provider-1","status":"delivered"}`)
+	keys, err := ParseKeys("old:old-secret, new:new-secret")
+	assert.NoError(t, err)
+
+	newSignedRequest := func(s *Signer) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/delivery-receipts", nil)
+		assert.NoError(t, s.SignRequest(r, body))
+		return r
+	}
+
+	t.Run("rotated keys", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+
+		assert.NoError(t, v.Verify(context.Background(), newSignedRequest(NewSigner("old", "old-secret")), body))
+		assert.NoError(t, v.Verify(context.Background(), newSignedRequest(NewSigner("new", "new-secret")), body))
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+
+		err := v.Verify(context.Background(), newSignedRequest(NewSigner("removed", "old-secret")), body)
+
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+
+		err := v.Verify(context.Background(), newSignedRequest(NewSigner("new", "new-secret")), []byte(`{}`))
+
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("expired timestamp", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+		s := NewSigner("new", "new-secret")
+		s.now = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+
+		err := v.Verify(context.Background(), newSignedRequest(s), body)
+
+		assert.ErrorIs(t, err, ErrExpiredSignature)
+	})
+
+	t.Run("tampered query", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+		r := httptest.NewRequest(http.MethodPost, "/delivery-receipts?attempt=1", nil)
+		assert.NoError(t, NewSigner("new", "new-secret").SignRequest(r, body))
+		r.URL.RawQuery = "attempt=2"
+
+		err := v.Verify(context.Background(), r, body)
+
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("malformed timestamp", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+		r := newSignedRequest(NewSigner("new", "new-secret"))
+		r.Header.Set(HeaderTimestamp, "yesterday")
+
+		err := v.Verify(context.Background(), r, body)
+
+		assert.ErrorIs(t, err, ErrMalformedSignature)
+	})
+
+	t.Run("replayed request", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+		r := newSignedRequest(NewSigner("new", "new-secret"))
+
+		assert.NoError(t, v.Verify(context.Background(), r, body))
+		assert.ErrorIs(t, v.Verify(context.Background(), r, body), ErrReplayedRequest)
+	})
+
+	t.Run("missing headers", func(t *testing.T) {
+		v := NewVerifier(keys, time.Minute, nonceStore{})
+
+		err := v.Verify(context.Background(), httptest.NewRequest(http.MethodPost, "/delivery-receipts", nil), body)
+
+		assert.ErrorIs(t, err, ErrMissingSignature)
+	})
+}
+
+func TestParseKeys(t *testing.T) {
+	_, err := ParseKeys("old:s3cr3t-value,invalid")
+
+	assert.Error(t, err)
+	assert.False(t, strings.Contains(err.Error(), "s3cr3t-value"))
+}
@@ -0,0 +1,357 @@
+package mongostore
+
+import (
+	"fmt"
+
+	"github.com/mkaykisiz/sender"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/context"
+)
+
+// encrypted fields, field names are authenticated with their values
+const (
+	fieldContent   = "content"
+	fieldRecipient = "recipient"
+)
+
+// encryptMessage returns mt with content and recipient encrypted into fields of their own, so that
+// plaintext can not pass for an encrypted value. Content is emptied while recipient field holds the
+// blind index of recipient so that messages can still be filtered by recipient. mt is returned as it is
+// when encryption is not configured.
+func (s *store) encryptMessage(mt sender.MessageTransaction) (sender.MessageTransaction, error) {
+	if s.cipher == nil {
+		return mt, nil
+	}
+
+	content, err := s.cipher.Encrypt(fieldContent, mt.Content)
+	if err != nil {
+		return mt, fmt.Errorf("encrypting content failed, %s", err.Error())
+	}
+	recipient, err := s.cipher.Encrypt(fieldRecipient, mt.Recipient)
+	if err != nil {
+		return mt, fmt.Errorf("encrypting recipient failed, %s", err.Error())
+	}
+
+	history := make([]sender.MessageRevision, 0, len(mt.History))
+	for _, r := range mt.History {
+		r, err := s.encryptRevision(r)
+		if err != nil {
+			return mt, err
+		}
+		history = append(history, r)
+	}
+	if len(mt.History) == 0 {
+		history = nil
+	}
+
+	mt.ContentEncrypted = content
+	mt.Content = ""
+	mt.RecipientEncrypted = recipient
+	mt.Recipient = s.cipher.BlindIndex(mt.Recipient)
+	mt.History = history
+
+	return mt, nil
+}
+
+// encryptRevision returns r with content and recipient encrypted into fields of their own
+func (s *store) encryptRevision(r sender.MessageRevision) (sender.MessageRevision, error) {
+	if s.cipher == nil {
+		return r, nil
+	}
+
+	var err error
+	if r.ContentEncrypted, err = s.cipher.Encrypt(fieldContent, r.Content); err != nil {
+		return r, fmt.Errorf("encrypting revision content failed, %s", err.Error())
+	}
+	if r.RecipientEncrypted, err = s.cipher.Encrypt(fieldRecipient, r.Recipient); err != nil {
+		return r, fmt.Errorf("encrypting revision recipient failed, %s", err.Error())
+	}
+	r.Content = ""
+	r.Recipient = ""
+
+	return r, nil
+}
+
+// decryptMessage returns mt with content and recipient decrypted. Only values of encrypted fields are
+// decrypted, plaintext values written before encryption are returned as they are.
+func (s *store) decryptMessage(mt sender.MessageTransaction) (sender.MessageTransaction, error) {
+	var err error
+	if mt.ContentEncrypted != "" {
+		if mt.Content, err = s.cipher.Decrypt(fieldContent, mt.ContentEncrypted); err != nil {
+			return mt, fmt.Errorf("decrypting content of message %s failed, %w", mt.ID.Hex(), err)
+		}
+		mt.ContentEncrypted = ""
+	}
+
+	if mt.RecipientEncrypted != "" {
+		if mt.Recipient, err = s.cipher.Decrypt(fieldRecipient, mt.RecipientEncrypted); err != nil {
+			return mt, fmt.Errorf("decrypting recipient of message %s failed, %w", mt.ID.Hex(), err)
+		}
+		mt.RecipientEncrypted = ""
+	}
+
+	for i, r := range mt.History {
+		if r.ContentEncrypted != "" {
+			if r.Content, err = s.cipher.Decrypt(fieldContent, r.ContentEncrypted); err != nil {
+				return mt, fmt.Errorf("decrypting revision content of message %s failed, %w", mt.ID.Hex(), err)
+			}
+			r.ContentEncrypted = ""
+		}
+		if r.RecipientEncrypted != "" {
+			if r.Recipient, err = s.cipher.Decrypt(fieldRecipient, r.RecipientEncrypted); err != nil {
+				return mt, fmt.Errorf("decrypting revision recipient of message %s failed, %w", mt.ID.Hex(), err)
+			}
+			r.RecipientEncrypted = ""
+		}
+		mt.History[i] = r
+	}
+
+	return mt, nil
+}
+
+// messageFilter returns mongo filter of f, recipient is matched by its blind index when encryption is
+// configured. Plaintext recipient is matched as well until messages written before encryption are
+// re-encrypted.
+func (s *store) messageFilter(f MessageFilter) bson.M {
+	filter := f.ToFilter(bson.M{})
+	if s.cipher != nil && f.Recipient != "" {
+		filter["recipient"] = bson.M{"$in": bson.A{s.cipher.BlindIndex(f.Recipient), f.Recipient}}
+	}
+
+	return filter
+}
+
+// recipientFilter returns mongo filter matching recipient the way messageFilter does, it matches every
+// document when recipient is empty
+func (s *store) recipientFilter(recipient string) bson.M {
+	if recipient == "" {
+		return bson.M{}
+	}
+	if s.cipher != nil {
+		return bson.M{"recipient": bson.M{"$in": bson.A{s.cipher.BlindIndex(recipient), recipient}}}
+	}
+
+	return bson.M{"recipient": recipient}
+}
+
+// encryptSandboxMessage returns m with content and recipient encrypted the way encryptMessage does
+func (s *store) encryptSandboxMessage(m sender.SandboxMessage) (sender.SandboxMessage, error) {
+	if s.cipher == nil {
+		return m, nil
+	}
+
+	content, err := s.cipher.Encrypt(fieldContent, m.Content)
+	if err != nil {
+		return m, fmt.Errorf("encrypting sandbox content failed, %s", err.Error())
+	}
+	recipient, err := s.cipher.Encrypt(fieldRecipient, m.Recipient)
+	if err != nil {
+		return m, fmt.Errorf("encrypting sandbox recipient failed, %s", err.Error())
+	}
+
+	m.ContentEncrypted = content
+	m.Content = ""
+	m.RecipientEncrypted = recipient
+	m.Recipient = s.cipher.BlindIndex(m.Recipient)
+
+	return m, nil
+}
+
+// decryptSandboxMessage returns m with content and recipient decrypted
+func (s *store) decryptSandboxMessage(m sender.SandboxMessage) (sender.SandboxMessage, error) {
+	var err error
+	if m.ContentEncrypted != "" {
+		if m.Content, err = s.cipher.Decrypt(fieldContent, m.ContentEncrypted); err != nil {
+			return m, fmt.Errorf("decrypting content of sandbox message %s failed, %w", m.ID.Hex(), err)
+		}
+		m.ContentEncrypted = ""
+	}
+
+	if m.RecipientEncrypted != "" {
+		if m.Recipient, err = s.cipher.Decrypt(fieldRecipient, m.RecipientEncrypted); err != nil {
+			return m, fmt.Errorf("decrypting recipient of sandbox message %s failed, %w", m.ID.Hex(), err)
+		}
+		m.RecipientEncrypted = ""
+	}
+
+	return m, nil
+}
+
+// isCurrent reports whether content and recipient of stored message are encrypted with the active key,
+// encrypted content leaves content field empty while recipient field holds the blind index
+func (s *store) isCurrent(mt sender.MessageTransaction) bool {
+	if mt.Content != "" || !s.cipher.IsCurrent(mt.ContentEncrypted) ||
+		mt.RecipientEncrypted == "" && mt.Recipient != "" || !s.cipher.IsCurrent(mt.RecipientEncrypted) {
+		return false
+	}
+
+	for _, r := range mt.History {
+		if r.Content != "" || r.Recipient != "" ||
+			!s.cipher.IsCurrent(r.ContentEncrypted) || !s.cipher.IsCurrent(r.RecipientEncrypted) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSandboxCurrent reports whether content and recipient of stored sandbox message are encrypted with
+// the active key
+func (s *store) isSandboxCurrent(m sender.SandboxMessage) bool {
+	return m.Content == "" && s.cipher.IsCurrent(m.ContentEncrypted) &&
+		(m.RecipientEncrypted != "" || m.Recipient == "") && s.cipher.IsCurrent(m.RecipientEncrypted)
+}
+
+// ReencryptMessages encrypts content and recipient of messages and sandbox messages which are plaintext
+// or encrypted with a key other than the active key. A message changed while it is re-encrypted is
+// skipped, it is re-encrypted by the next run. Re-encrypted and skipped counts of both collections are
+// returned together.
+func (s *store) ReencryptMessages(ctx context.Context) (reencrypted int64, skipped int64, err error) {
+	if s.cipher == nil {
+		return 0, 0, ErrEncryptionNotConfigured
+	}
+
+	reencrypted, skipped, err = s.reencryptMessages(ctx)
+	if err != nil {
+		return reencrypted, skipped, err
+	}
+
+	sandboxReencrypted, sandboxSkipped, err := s.reencryptSandboxMessages(ctx)
+	return reencrypted + sandboxReencrypted, skipped + sandboxSkipped, err
+}
+
+// reencryptMessages re-encrypts messages of message collection the way ReencryptMessages does
+func (s *store) reencryptMessages(ctx context.Context) (reencrypted int64, skipped int64, err error) {
+	c := s.db.Collection(MessageCollectionName)
+
+	cursor, err := c.Find(ctx, bson.M{}, options.Find().SetBatchSize(streamBatchSize))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var stored sender.MessageTransaction
+		if err := cursor.Decode(&stored); err != nil {
+			return reencrypted, skipped, err
+		}
+		if s.isCurrent(stored) {
+			continue
+		}
+
+		mt, err := s.decryptMessage(stored)
+		if err != nil {
+			return reencrypted, skipped, err
+		}
+		mt, err = s.encryptMessage(mt)
+		if err != nil {
+			return reencrypted, skipped, err
+		}
+
+		// version is not incremented since message values are unchanged
+		filter := bson.M{"_id": stored.ID, "version": stored.Version}
+		if stored.Version == 0 {
+			filter["version"] = bson.M{"$in": bson.A{0, nil}}
+		}
+		set := bson.M{
+			"content":             mt.Content,
+			"content_encrypted":   mt.ContentEncrypted,
+			"recipient":           mt.Recipient,
+			"recipient_encrypted": mt.RecipientEncrypted,
+		}
+		if len(mt.History) > 0 {
+			set["history"] = mt.History
+		}
+		update := bson.M{"$set": set}
+
+		wctx, cf := context.WithTimeout(ctx, s.writeTimeout)
+		res, err := c.UpdateOne(wctx, filter, update)
+		cf()
+		if err != nil {
+			return reencrypted, skipped, err
+		}
+		if res.MatchedCount == 0 {
+			skipped++
+			continue
+		}
+		reencrypted++
+	}
+
+	return reencrypted, skipped, cursor.Err()
+}
+
+// reencryptSandboxMessages re-encrypts messages of sandbox message collection the way ReencryptMessages
+// does. Sandbox messages are not changed once captured, a message is skipped only when it is removed
+// while it is re-encrypted.
+func (s *store) reencryptSandboxMessages(ctx context.Context) (reencrypted int64, skipped int64, err error) {
+	c := s.db.Collection(SandboxMessageCollectionName)
+
+	cursor, err := c.Find(ctx, bson.M{}, options.Find().SetBatchSize(streamBatchSize))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var stored sender.SandboxMessage
+		if err := cursor.Decode(&stored); err != nil {
+			return reencrypted, skipped, err
+		}
+		if s.isSandboxCurrent(stored) {
+			continue
+		}
+
+		m, err := s.decryptSandboxMessage(stored)
+		if err != nil {
+			return reencrypted, skipped, err
+		}
+		m, err = s.encryptSandboxMessage(m)
+		if err != nil {
+			return reencrypted, skipped, err
+		}
+
+		update := bson.M{"$set": bson.M{
+			"content":             m.Content,
+			"content_encrypted":   m.ContentEncrypted,
+			"recipient":           m.Recipient,
+			"recipient_encrypted": m.RecipientEncrypted,
+		}}
+
+		wctx, cf := context.WithTimeout(ctx, s.writeTimeout)
+		res, err := c.UpdateOne(wctx, bson.M{"_id": stored.ID}, update)
+		cf()
+		if err != nil {
+			return reencrypted, skipped, err
+		}
+		if res.MatchedCount == 0 {
+			skipped++
+			continue
+		}
+		reencrypted++
+	}
+
+	return reencrypted, skipped, cursor.Err()
+}
+
+// decryptMessages returns mts decrypted, messages which can not be decrypted are logged and skipped so
+// that a single broken message does not fail the whole read
+func (s *store) decryptMessages(method string, mts []sender.MessageTransaction) []sender.MessageTransaction {
+	decrypted := make([]sender.MessageTransaction, 0, len(mts))
+	for _, mt := range mts {
+		mt, err := s.decryptMessage(mt)
+		if err != nil {
+			s.logUndecryptable(method, mt.ID, err)
+			continue
+		}
+		decrypted = append(decrypted, mt)
+	}
+
+	return decrypted
+}
+
+// logUndecryptable logs message id which is skipped by method since it can not be decrypted
+func (s *store) logUndecryptable(method string, id primitive.ObjectID, err error) {
+	_ = s.l.Log("method", method, "msg", "skipping message which can not be decrypted", "id", id.Hex(), "error", err.Error())
+}
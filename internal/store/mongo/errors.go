@@ -0,0 +1,64 @@
+package mongostore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrMessageNotFound is returned when no message matches the given id
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrAPIKeyNotFound is returned when no api key matches the given id or hash
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// ErrEncryptionNotConfigured is returned when messages are re-encrypted without encryption keys
+var ErrEncryptionNotConfigured = errors.New("encryption is not configured")
+
+// compile-time proofs of error interface implementation
+var (
+	_ error = (*StatusConflictError)(nil)
+	_ error = (*EditConflictError)(nil)
+)
+
+// StatusConflictError represents a rejected message status transition. It is returned when the
+// transition is not allowed by the state machine or when the message was changed by another writer
+// since it was read.
+type StatusConflictError struct {
+	ID      primitive.ObjectID
+	From    string
+	To      string
+	Version int64
+	// LeaseUntil is the lease of the claim when message is processing
+	LeaseUntil *time.Time
+}
+
+// Error returns status conflict error's error message
+func (e *StatusConflictError) Error() string {
+	if !CanTransition(e.From, e.To) {
+		return fmt.Sprintf("illegal status transition of message %s, from: %s, to: %s", e.ID.Hex(), e.From, e.To)
+	}
+
+	return fmt.Sprintf("message %s was modified concurrently, expected status: %s, expected version: %d", e.ID.Hex(), e.From, e.Version)
+}
+
+// EditConflictError represents a rejected message edit. It is returned when the message is not
+// editable in its status or was changed by another writer since it was read.
+type EditConflictError struct {
+	ID      primitive.ObjectID
+	Status  string
+	Version int64
+	// LeaseUntil is the lease of the claim when message is processing
+	LeaseUntil *time.Time
+}
+
+// Error returns edit conflict error's error message
+func (e *EditConflictError) Error() string {
+	if !IsEditable(e.Status) {
+		return fmt.Sprintf("message %s can not be edited in %s status", e.ID.Hex(), e.Status)
+	}
+
+	return fmt.Sprintf("message %s was modified concurrently, expected status: %s, expected version: %d", e.ID.Hex(), e.Status, e.Version)
+}
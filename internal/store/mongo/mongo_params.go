@@ -1,31 +1,185 @@
 package mongostore
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
-	STATUS_PENDING = "pending"
-	STATUS_SENT    = "sent"
-	STATUS_FAILED  = "failed"
-	STATUS_INVALID = "invalid"
+	STATUS_PENDING    = "pending"
+	STATUS_PROCESSING = "processing"
+	STATUS_SENT       = "sent"
+	STATUS_DELIVERED  = "delivered"
+	STATUS_FAILED     = "failed"
+	STATUS_INVALID    = "invalid"
+	STATUS_CANCELLED  = "cancelled"
+	STATUS_BLOCKED    = "blocked_by_environment" // recipient is not in the allow-list of a non-prod environment
 )
 
+// statusTransitions holds allowed message status transitions, keyed by current status
+var statusTransitions = map[string][]string{
+	STATUS_PENDING:    {STATUS_PROCESSING, STATUS_INVALID, STATUS_CANCELLED, STATUS_BLOCKED},
+	STATUS_PROCESSING: {STATUS_SENT, STATUS_FAILED, STATUS_INVALID, STATUS_PENDING, STATUS_PROCESSING, STATUS_BLOCKED, STATUS_CANCELLED}, // claim is released back to pending on drain, claimed again or cancelled once its lease expires
+	STATUS_SENT:       {STATUS_DELIVERED},
+	STATUS_FAILED:     {STATUS_PROCESSING, STATUS_INVALID, STATUS_CANCELLED, STATUS_BLOCKED, STATUS_PENDING}, // dead letters are requeued to pending
+	STATUS_DELIVERED:  {},
+	STATUS_INVALID:    {STATUS_PENDING},
+	STATUS_CANCELLED:  {},
+	STATUS_BLOCKED:    {},
+}
+
+// CanTransition reports whether a message can move from one status to another
+func CanTransition(from, to string) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsEditable reports whether message content, recipient, send time or priority can be changed in given status
+func IsEditable(status string) bool {
+	return status == STATUS_PENDING || status == STATUS_FAILED
+}
+
+// IsClaimExpired reports whether mt is claimed by a worker whose lease expired by now, the worker is
+// considered stopped and the message is claimed again by the next batch
+func IsClaimExpired(mt sender.MessageTransaction, now time.Time) bool {
+	return mt.Status == STATUS_PROCESSING && mt.LeaseUntil != nil && mt.LeaseUntil.Before(now)
+}
+
+// RequiresExpiredClaim reports whether moving a message from one status to another is allowed only once
+// its claim expired, a running claim is neither claimed again nor cancelled
+func RequiresExpiredClaim(from, to string) bool {
+	return from == STATUS_PROCESSING && (to == STATUS_PROCESSING || to == STATUS_CANCELLED)
+}
+
+// StatusesTransitionableTo returns sorted statuses which can move to given status
+func StatusesTransitionableTo(to string) []string {
+	ss := make([]string, 0)
+	for from := range statusTransitions {
+		if CanTransition(from, to) {
+			ss = append(ss, from)
+		}
+	}
+	sort.Strings(ss)
+
+	return ss
+}
+
+// StatusDetails holds send outcome recorded together with a status change
+type StatusDetails struct {
+	SentAt            *time.Time
+	DeliveredAt       *time.Time
+	Provider          string
+	ProviderMessageID string
+	Error             string
+	// LeaseUntil is the time claim of the message expires, it is set when message is claimed
+	LeaseUntil *time.Time
+	// Requeue moves dead letter back to pending, its attempts and last error are cleared
+	Requeue bool
+}
+
 type MessageFilter struct {
-	Status []string
+	IDs         []primitive.ObjectID
+	Status      []string
+	Recipient   string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	SentFrom    *time.Time
+	SentTo      *time.Time
+	Tags        []string // only messages having all of the tags
+	Due         bool     // only messages without send time or whose send time has come
+	// ExpiredClaims matches processing messages whose lease expired as well as messages in Status
+	ExpiredClaims bool
+}
+
+// IsEmpty reports whether filter matches every message
+func (f MessageFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && len(f.Status) == 0 && f.Recipient == "" && f.CreatedFrom == nil && f.CreatedTo == nil &&
+		f.SentFrom == nil && f.SentTo == nil && len(f.Tags) == 0 && !f.Due && !f.ExpiredClaims
 }
 
 func (f MessageFilter) ToFilter(baseFilter bson.M) bson.M {
-	if len(f.Status) > 0 {
+	if len(f.IDs) > 0 {
+		baseFilter["_id"] = bson.M{"$in": f.IDs}
+	}
+
+	if len(f.Status) > 0 && !f.ExpiredClaims {
 		baseFilter["status"] = bson.M{"$in": f.Status}
 	}
 
+	if f.ExpiredClaims {
+		statuses := bson.A{bson.M{"status": STATUS_PROCESSING, "lease_until": bson.M{"$lt": time.Now()}}}
+		if len(f.Status) > 0 {
+			statuses = append(statuses, bson.M{"status": bson.M{"$in": f.Status}})
+		}
+
+		and, _ := baseFilter["$and"].(bson.A)
+		baseFilter["$and"] = append(and, bson.M{"$or": statuses})
+	}
+
+	if f.Recipient != "" {
+		baseFilter["recipient"] = f.Recipient
+	}
+
+	if f.CreatedFrom != nil || f.CreatedTo != nil {
+		baseFilter["created_at"] = timeRange(f.CreatedFrom, f.CreatedTo)
+	}
+
+	if f.SentFrom != nil || f.SentTo != nil {
+		baseFilter["sent_at"] = timeRange(f.SentFrom, f.SentTo)
+	}
+
+	if len(f.Tags) > 0 {
+		baseFilter["tags"] = bson.M{"$all": f.Tags}
+	}
+
+	if f.Due {
+		baseFilter["$or"] = bson.A{
+			bson.M{"send_at": nil},
+			bson.M{"send_at": bson.M{"$lte": time.Now()}},
+		}
+	}
+
 	return baseFilter
 }
 
+// timeRange returns inclusive range condition, from or to may be nil
+func timeRange(from, to *time.Time) bson.M {
+	r := bson.M{}
+	if from != nil {
+		r["$gte"] = from
+	}
+	if to != nil {
+		r["$lte"] = to
+	}
+
+	return r
+}
+
+// sort fields
+const (
+	SortByCreatedAt = "created_at"
+	SortBySentAt    = "sent_at"
+)
+
 type MessageOptions struct {
-	Limit int64
+	Limit    int64
+	SortBy   string // sorts by priority and then creation time when empty
+	SortDesc bool
+	After    *MessageCursor // only messages after cursor in sort order, requires SortBy
 }
 
 func (f MessageOptions) ToOptions() *options.FindOptions {
@@ -35,5 +189,90 @@ func (f MessageOptions) ToOptions() *options.FindOptions {
 		options.SetLimit(f.Limit)
 	}
 
+	if f.SortBy == "" {
+		options.SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "created_at", Value: 1}})
+	} else {
+		order := 1
+		if f.SortDesc {
+			order = -1
+		}
+		options.SetSort(bson.D{{Key: f.SortBy, Value: order}, {Key: "_id", Value: order}})
+	}
+
 	return options
 }
+
+// ToFilter adds cursor condition to filter
+func (f MessageOptions) ToFilter(baseFilter bson.M) bson.M {
+	if f.After == nil || f.SortBy == "" {
+		return baseFilter
+	}
+
+	op := "$gt"
+	if f.SortDesc {
+		op = "$lt"
+	}
+
+	// null values sort before any time value
+	var after bson.A
+	if f.After.Value == nil {
+		after = bson.A{bson.M{f.SortBy: nil, "_id": bson.M{op: f.After.ID}}}
+		if !f.SortDesc {
+			after = append(after, bson.M{f.SortBy: bson.M{"$ne": nil}})
+		}
+	} else {
+		after = bson.A{
+			bson.M{f.SortBy: bson.M{op: f.After.Value}},
+			bson.M{f.SortBy: f.After.Value, "_id": bson.M{op: f.After.ID}},
+		}
+		if f.SortDesc {
+			after = append(after, bson.M{f.SortBy: nil})
+		}
+	}
+
+	and, _ := baseFilter["$and"].(bson.A)
+	baseFilter["$and"] = append(and, bson.M{"$or": after})
+
+	return baseFilter
+}
+
+// MessageCursor represents position of a message in sort order
+type MessageCursor struct {
+	Value *time.Time         `json:"v"`
+	ID    primitive.ObjectID `json:"id"`
+}
+
+// NewMessageCursor returns cursor pointing to message in given sort field
+func NewMessageCursor(mt sender.MessageTransaction, sortBy string) MessageCursor {
+	c := MessageCursor{ID: mt.ID}
+	switch sortBy {
+	case SortByCreatedAt:
+		createdAt := mt.CreatedAt
+		c.Value = &createdAt
+	case SortBySentAt:
+		c.Value = mt.SentAt
+	}
+
+	return c
+}
+
+// Encode returns opaque cursor token
+func (c MessageCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeMessageCursor decodes cursor token returned by Encode
+func DecodeMessageCursor(token string) (*MessageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decoding cursor failed, %s", err.Error())
+	}
+
+	c := &MessageCursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("decoding cursor failed, %s", err.Error())
+	}
+
+	return c, nil
+}
@@ -1,31 +1,54 @@
 package mongostore
 
 import (
+	"errors"
 	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"math"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 
+	"github.com/go-kit/log"
 	"github.com/mkaykisiz/sender"
 	envvars "github.com/mkaykisiz/sender/configs/env-vars"
-	"golang.org/x/net/context"
+	"github.com/mkaykisiz/sender/internal/encryption"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"golang.org/x/net/context"
 )
 
 const (
-	MessageCollectionName = "message"
+	MessageCollectionName        = "message"
+	APIKeyCollectionName         = "api_key"
+	SandboxMessageCollectionName = "sandbox_message"
 )
 
+// streamBatchSize is the number of documents fetched per round trip while streaming messages
+const streamBatchSize = 500
+
 // Store defines behaviors of mongo store
 type Store interface {
 	Close() error
+	Ping(ctx context.Context) error
 	GetMessages(ctx context.Context, f MessageFilter, o MessageOptions) (mts []sender.MessageTransaction, err error)
-	UpdateMessageStatus(ctx context.Context, id primitive.ObjectID, status string, sentAt *time.Time) error
+	StreamMessages(ctx context.Context, f MessageFilter, o MessageOptions, fn func(sender.MessageTransaction) error) error
+	GetMessage(ctx context.Context, id primitive.ObjectID) (sender.MessageTransaction, error)
+	GetMessageByProviderMessageID(ctx context.Context, providerMessageID string) (sender.MessageTransaction, error)
+	UpdateMessageStatus(ctx context.Context, mt sender.MessageTransaction, status string, d StatusDetails) (sender.MessageTransaction, error)
+	UpdateMessage(ctx context.Context, current sender.MessageTransaction, updated sender.MessageTransaction) (sender.MessageTransaction, error)
 	Count(ctx context.Context, f MessageFilter) (int64, error)
 	InsertMany(ctx context.Context, mts []sender.MessageTransaction) error
+	GetMessageStats(ctx context.Context, since time.Time) (sender.MessageStats, error)
+	InsertAPIKey(ctx context.Context, k sender.APIKey) (sender.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (sender.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]sender.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id primitive.ObjectID) (sender.APIKey, error)
+	ReencryptMessages(ctx context.Context) (reencrypted int64, skipped int64, err error)
+	InsertSandboxMessage(ctx context.Context, m sender.SandboxMessage) (sender.SandboxMessage, error)
+	GetSandboxMessages(ctx context.Context, recipient string, limit int64) ([]sender.SandboxMessage, error)
 }
 
 // store represents mongo store
@@ -39,10 +62,14 @@ type store struct {
 	disconnectTimeout time.Duration
 	c                 *mongo.Client
 	db                *mongo.Database
+	cipher            *encryption.Cipher
+	l                 log.Logger
 }
 
-// NewStore creates and returns mongo store
-func NewStore(m envvars.Mongo) (*store, error) {
+// NewStore creates and returns mongo store, content and recipient of messages are encrypted with cipher.
+// Messages are stored in plaintext when cipher is nil. Messages skipped by reads since they can not be
+// decrypted are logged to l.
+func NewStore(m envvars.Mongo, cipher *encryption.Cipher, l log.Logger) (*store, error) {
 	s := &store{
 		uri:               m.URI,
 		database:          m.Database,
@@ -51,6 +78,8 @@ func NewStore(m envvars.Mongo) (*store, error) {
 		writeTimeout:      m.WriteTimeout,
 		pingTimeout:       m.PingTimeout,
 		disconnectTimeout: m.DisconnectTimeout,
+		cipher:            cipher,
+		l:                 l,
 	}
 
 	cctx, ccf := context.WithTimeout(context.Background(), s.connectTimeout)
@@ -58,6 +87,8 @@ func NewStore(m envvars.Mongo) (*store, error) {
 
 	opts := options.Client()
 	opts.ApplyURI(s.uri)
+	// commands are traced without their statements, filters and documents hold recipients
+	opts.SetMonitor(otelmongo.NewMonitor())
 
 	c, err := mongo.Connect(cctx, opts)
 	if err != nil {
@@ -84,10 +115,7 @@ func (s *store) GetMessages(ctx context.Context, f MessageFilter, o MessageOptio
 
 	var messageTransactions []sender.MessageTransaction
 
-	findOptions := o.ToOptions()
-	findOptions.SetSort(bson.D{{"created_at", 1}})
-
-	cursor, err := s.db.Collection(MessageCollectionName).Find(ctx, f.ToFilter(bson.M{}), findOptions)
+	cursor, err := s.db.Collection(MessageCollectionName).Find(ctx, o.ToFilter(s.messageFilter(f)), o.ToOptions())
 	if err != nil {
 		return messageTransactions, err
 	}
@@ -96,30 +124,146 @@ func (s *store) GetMessages(ctx context.Context, f MessageFilter, o MessageOptio
 	if err != nil {
 		return messageTransactions, err
 	}
-	return messageTransactions, nil
+	return s.decryptMessages("GetMessages", messageTransactions), nil
+}
+
+// StreamMessages calls fn for each message matching filter in order of options without loading
+// the result set into memory. Streaming stops at the first error returned by fn. Read timeout is
+// not applied since the duration depends on the result size, ctx bounds the stream instead.
+func (s *store) StreamMessages(ctx context.Context, f MessageFilter, o MessageOptions, fn func(sender.MessageTransaction) error) error {
+	opts := o.ToOptions().SetBatchSize(streamBatchSize)
+
+	cursor, err := s.db.Collection(MessageCollectionName).Find(ctx, o.ToFilter(s.messageFilter(f)), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var mt sender.MessageTransaction
+		if err := cursor.Decode(&mt); err != nil {
+			return err
+		}
+
+		mt, err := s.decryptMessage(mt)
+		if err != nil {
+			s.logUndecryptable("StreamMessages", mt.ID, err)
+			continue
+		}
+
+		if err := fn(mt); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// GetMessage returns message by id or ErrMessageNotFound
+func (s *store) GetMessage(ctx context.Context, id primitive.ObjectID) (sender.MessageTransaction, error) {
+	ctx, cf := context.WithTimeout(ctx, s.readTimeout)
+	defer cf()
+
+	var mt sender.MessageTransaction
+	err := s.db.Collection(MessageCollectionName).FindOne(ctx, bson.M{"_id": id}).Decode(&mt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return mt, ErrMessageNotFound
+	}
+	if err != nil {
+		return mt, err
+	}
+	return s.decryptMessage(mt)
 }
 
-func (s *store) UpdateMessageStatus(ctx context.Context, id primitive.ObjectID, status string, sentAt *time.Time) error {
+// GetMessageByProviderMessageID returns message by the id given by its provider or ErrMessageNotFound
+func (s *store) GetMessageByProviderMessageID(ctx context.Context, providerMessageID string) (sender.MessageTransaction, error) {
+	ctx, cf := context.WithTimeout(ctx, s.readTimeout)
+	defer cf()
+
+	var mt sender.MessageTransaction
+	err := s.db.Collection(MessageCollectionName).FindOne(ctx, bson.M{"provider_message_id": providerMessageID}).Decode(&mt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return mt, ErrMessageNotFound
+	}
+	if err != nil {
+		return mt, err
+	}
+	return s.decryptMessage(mt)
+}
+
+// UpdateMessageStatus moves message to given status if the transition is allowed. Update is applied
+// only when the stored message still has the status and version of mt, otherwise a
+// *StatusConflictError is returned. Claiming the message for sending counts as an attempt and holds
+// the claim until lease of d, a processing message is claimed again or cancelled only once its lease
+// expired.
+// Updated message is returned on success.
+func (s *store) UpdateMessageStatus(ctx context.Context, mt sender.MessageTransaction, status string, d StatusDetails) (sender.MessageTransaction, error) {
+	conflictErr := &StatusConflictError{ID: mt.ID, From: mt.Status, To: status, Version: mt.Version, LeaseUntil: mt.LeaseUntil}
+	now := time.Now()
+	if !CanTransition(mt.Status, status) {
+		return sender.MessageTransaction{}, conflictErr
+	}
+	if RequiresExpiredClaim(mt.Status, status) && !IsClaimExpired(mt, now) {
+		return sender.MessageTransaction{}, conflictErr
+	}
+
 	ctx, cf := context.WithTimeout(ctx, s.writeTimeout)
 	defer cf()
 
-	update := bson.M{"status": status}
-	if sentAt != nil {
-		update["sent_at"] = sentAt
+	filter := versionFilter(mt)
+	if RequiresExpiredClaim(mt.Status, status) {
+		filter["lease_until"] = bson.M{"$lt": now}
 	}
 
-	_, err := s.db.Collection(MessageCollectionName).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
+	set := bson.M{"status": status, "version": mt.Version + 1, "updated_at": now}
+	if d.SentAt != nil {
+		set["sent_at"] = d.SentAt
+	}
+	if d.DeliveredAt != nil {
+		set["delivered_at"] = d.DeliveredAt
+	}
+	if d.Provider != "" {
+		set["provider"] = d.Provider
+	}
+	if d.ProviderMessageID != "" {
+		set["provider_message_id"] = d.ProviderMessageID
+	}
+	if d.Error != "" {
+		set["last_error"] = d.Error
+	}
+
+	update := bson.M{"$set": set}
+	if status == STATUS_PROCESSING {
+		set["claimed_at"] = now
+		if d.LeaseUntil != nil {
+			set["lease_until"] = d.LeaseUntil
+		}
+		update["$inc"] = bson.M{"attempts": 1}
+	} else {
+		update["$unset"] = bson.M{"lease_until": ""}
+	}
+	if d.Requeue {
+		set["attempts"] = 0
+		update["$unset"] = bson.M{"lease_until": "", "last_error": ""}
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated sender.MessageTransaction
+	err := s.db.Collection(MessageCollectionName).FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return sender.MessageTransaction{}, conflictErr
+	}
 	if err != nil {
-		return err
+		return sender.MessageTransaction{}, err
 	}
-	return nil
+	return s.decryptMessage(updated)
 }
 
 func (s *store) Count(ctx context.Context, f MessageFilter) (int64, error) {
 	ctx, cf := context.WithTimeout(ctx, s.readTimeout)
 	defer cf()
 
-	count, err := s.db.Collection(MessageCollectionName).CountDocuments(ctx, f.ToFilter(bson.M{}))
+	count, err := s.db.Collection(MessageCollectionName).CountDocuments(ctx, s.messageFilter(f))
 	if err != nil {
 		return 0, err
 	}
@@ -132,6 +276,10 @@ func (s *store) InsertMany(ctx context.Context, mts []sender.MessageTransaction)
 
 	var documents []interface{}
 	for _, mt := range mts {
+		mt, err := s.encryptMessage(mt)
+		if err != nil {
+			return err
+		}
 		documents = append(documents, mt)
 	}
 
@@ -142,6 +290,334 @@ func (s *store) InsertMany(ctx context.Context, mts []sender.MessageTransaction)
 	return nil
 }
 
+// UpdateMessage replaces content, recipient, send time and priority of current with values of updated,
+// send time is cleared when updated has none. Update is applied only when current is editable and the stored message still has the status and
+// version of current, otherwise a *EditConflictError is returned. Replaced values are appended to
+// message history. Updated message is returned on success.
+func (s *store) UpdateMessage(ctx context.Context, current sender.MessageTransaction, updated sender.MessageTransaction) (sender.MessageTransaction, error) {
+	conflictErr := &EditConflictError{ID: current.ID, Status: current.Status, Version: current.Version, LeaseUntil: current.LeaseUntil}
+	if !IsEditable(current.Status) {
+		return sender.MessageTransaction{}, conflictErr
+	}
+
+	encrypted, err := s.encryptMessage(updated)
+	if err != nil {
+		return sender.MessageTransaction{}, err
+	}
+	revision, err := s.encryptRevision(current.Revision(time.Now()))
+	if err != nil {
+		return sender.MessageTransaction{}, err
+	}
+
+	ctx, cf := context.WithTimeout(ctx, s.writeTimeout)
+	defer cf()
+
+	set := bson.M{
+		"content":    encrypted.Content,
+		"recipient":  encrypted.Recipient,
+		"priority":   updated.Priority,
+		"version":    current.Version + 1,
+		"updated_at": time.Now(),
+	}
+	unset := bson.M{}
+	if encrypted.ContentEncrypted != "" {
+		set["content_encrypted"] = encrypted.ContentEncrypted
+	} else {
+		unset["content_encrypted"] = ""
+	}
+	if encrypted.RecipientEncrypted != "" {
+		set["recipient_encrypted"] = encrypted.RecipientEncrypted
+	} else {
+		unset["recipient_encrypted"] = ""
+	}
+	if updated.SendAt != nil {
+		set["send_at"] = updated.SendAt
+	} else {
+		unset["send_at"] = ""
+	}
+	update := bson.M{
+		"$set":   set,
+		"$unset": unset,
+		"$push":  bson.M{"history": revision},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var mt sender.MessageTransaction
+	err = s.db.Collection(MessageCollectionName).FindOneAndUpdate(ctx, versionFilter(current), update, opts).Decode(&mt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return sender.MessageTransaction{}, conflictErr
+	}
+	if err != nil {
+		return sender.MessageTransaction{}, err
+	}
+	return s.decryptMessage(mt)
+}
+
+// GetMessageStats aggregates status counts, oldest pending message, and sends, provider outcomes and
+// send latency percentiles since given time. Latency percentiles require MongoDB 7.0 or later.
+func (s *store) GetMessageStats(ctx context.Context, since time.Time) (sender.MessageStats, error) {
+	ctx, cf := context.WithTimeout(ctx, s.readTimeout)
+	defer cf()
+
+	c := s.db.Collection(MessageCollectionName)
+	stats := sender.MessageStats{StatusCounts: map[string]int64{}, Providers: []sender.ProviderStats{}}
+
+	// counts per status
+	var statusCounts []struct {
+		Status string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return stats, fmt.Errorf("aggregating status counts failed, %s", err.Error())
+	}
+	if err := cursor.All(ctx, &statusCounts); err != nil {
+		return stats, fmt.Errorf("decoding status counts failed, %s", err.Error())
+	}
+	for _, sc := range statusCounts {
+		stats.StatusCounts[sc.Status] = sc.Count
+	}
+
+	// oldest pending message
+	var oldest sender.MessageTransaction
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetProjection(bson.M{"created_at": 1})
+	err = c.FindOne(ctx, bson.M{"status": STATUS_PENDING}, opts).Decode(&oldest)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return stats, fmt.Errorf("finding oldest pending message failed, %s", err.Error())
+	}
+	if err == nil {
+		stats.OldestPendingCreatedAt = &oldest.CreatedAt
+	}
+
+	// sends in window
+	stats.SentInWindow, err = c.CountDocuments(ctx, bson.M{"sent_at": bson.M{"$gte": since}})
+	if err != nil {
+		return stats, fmt.Errorf("counting sent messages failed, %s", err.Error())
+	}
+
+	// outcomes per provider in window
+	cursor, err = c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"updated_at": bson.M{"$gte": since},
+			"status":     bson.M{"$in": bson.A{STATUS_SENT, STATUS_DELIVERED, STATUS_FAILED}},
+			"provider":   bson.M{"$exists": true},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$provider",
+			"sent":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$status", bson.A{STATUS_SENT, STATUS_DELIVERED}}}, 1, 0}}},
+			"failed": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$status", STATUS_FAILED}}, 1, 0}}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return stats, fmt.Errorf("aggregating provider stats failed, %s", err.Error())
+	}
+	if err := cursor.All(ctx, &stats.Providers); err != nil {
+		return stats, fmt.Errorf("decoding provider stats failed, %s", err.Error())
+	}
+	for i, ps := range stats.Providers {
+		if total := ps.Sent + ps.Failed; total > 0 {
+			stats.Providers[i].FailureRate = float64(ps.Failed) / float64(total)
+		}
+	}
+
+	// created_at to sent_at latency percentiles in window
+	percentiles := make([]float64, 0, 3)
+	for _, p := range []float64{0.5, 0.9, 0.99} {
+		v, err := latencyPercentile(ctx, c, since, stats.SentInWindow, p)
+		if err != nil {
+			return stats, err
+		}
+		percentiles = append(percentiles, v)
+	}
+	stats.Latency = sender.LatencyStats{P50: percentiles[0], P90: percentiles[1], P99: percentiles[2]}
+
+	return stats, nil
+}
+
+// latencyPercentile returns p-th created_at to sent_at latency in milliseconds of n messages sent since by
+// nearest rank. $percentile operator is not used since it needs mongodb 7.0, sort is bounded to rank
+// documents by the limit that follows it.
+func latencyPercentile(ctx context.Context, c *mongo.Collection, since time.Time, n int64, p float64) (float64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+
+	rank := int64(math.Ceil(p*float64(n))) - 1
+	var latency []struct {
+		Latency float64 `bson:"latency"`
+	}
+	cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"sent_at": bson.M{"$gte": since}}}},
+		{{Key: "$project", Value: bson.M{"latency": bson.M{"$subtract": bson.A{"$sent_at", "$created_at"}}}}},
+		{{Key: "$sort", Value: bson.M{"latency": 1}}},
+		{{Key: "$skip", Value: rank}},
+		{{Key: "$limit", Value: 1}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, fmt.Errorf("aggregating latency percentile failed, %s", err.Error())
+	}
+	if err := cursor.All(ctx, &latency); err != nil {
+		return 0, fmt.Errorf("decoding latency percentile failed, %s", err.Error())
+	}
+	if len(latency) == 0 {
+		return 0, nil
+	}
+
+	return latency[0].Latency, nil
+}
+
+// InsertAPIKey inserts api key and returns it with its generated id
+func (s *store) InsertAPIKey(ctx context.Context, k sender.APIKey) (sender.APIKey, error) {
+	ctx, cf := context.WithTimeout(ctx, s.writeTimeout)
+	defer cf()
+
+	if k.ID.IsZero() {
+		k.ID = primitive.NewObjectID()
+	}
+
+	_, err := s.db.Collection(APIKeyCollectionName).InsertOne(ctx, k)
+	if err != nil {
+		return k, err
+	}
+	return k, nil
+}
+
+// GetAPIKeyByHash returns api key by hash of the key or ErrAPIKeyNotFound, revoked keys are returned as well
+func (s *store) GetAPIKeyByHash(ctx context.Context, hash string) (sender.APIKey, error) {
+	ctx, cf := context.WithTimeout(ctx, s.readTimeout)
+	defer cf()
+
+	var k sender.APIKey
+	err := s.db.Collection(APIKeyCollectionName).FindOne(ctx, bson.M{"hash": hash}).Decode(&k)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return k, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return k, err
+	}
+	return k, nil
+}
+
+// GetAPIKeys returns all api keys ordered by creation time
+func (s *store) GetAPIKeys(ctx context.Context) ([]sender.APIKey, error) {
+	ctx, cf := context.WithTimeout(ctx, s.readTimeout)
+	defer cf()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := s.db.Collection(APIKeyCollectionName).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := make([]sender.APIKey, 0)
+	if err := cursor.All(ctx, &ks); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// RevokeAPIKey marks api key as revoked and returns it, revoking an already revoked key keeps its
+// revocation time. ErrAPIKeyNotFound is returned when no api key has the id.
+func (s *store) RevokeAPIKey(ctx context.Context, id primitive.ObjectID) (sender.APIKey, error) {
+	ctx, cf := context.WithTimeout(ctx, s.writeTimeout)
+	defer cf()
+
+	filter := bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var k sender.APIKey
+	err := s.db.Collection(APIKeyCollectionName).FindOneAndUpdate(ctx, filter, update, opts).Decode(&k)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = s.db.Collection(APIKeyCollectionName).FindOne(ctx, bson.M{"_id": id}).Decode(&k)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return k, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return k, err
+	}
+	return k, nil
+}
+
+// InsertSandboxMessage stores message captured by sandbox provider and returns it with its id
+func (s *store) InsertSandboxMessage(ctx context.Context, m sender.SandboxMessage) (sender.SandboxMessage, error) {
+	ctx, cf := context.WithTimeout(ctx, s.writeTimeout)
+	defer cf()
+
+	if m.ID.IsZero() {
+		m.ID = primitive.NewObjectID()
+	}
+
+	encrypted, err := s.encryptSandboxMessage(m)
+	if err != nil {
+		return m, err
+	}
+
+	_, err = s.db.Collection(SandboxMessageCollectionName).InsertOne(ctx, encrypted)
+	if err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// GetSandboxMessages returns up to limit messages captured by sandbox provider, most recent first. All
+// recipients are matched when recipient is empty.
+func (s *store) GetSandboxMessages(ctx context.Context, recipient string, limit int64) ([]sender.SandboxMessage, error) {
+	ctx, cf := context.WithTimeout(ctx, s.readTimeout)
+	defer cf()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit)
+
+	cursor, err := s.db.Collection(SandboxMessageCollectionName).Find(ctx, s.recipientFilter(recipient), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []sender.SandboxMessage
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+	ms := make([]sender.SandboxMessage, 0, len(stored))
+	for _, m := range stored {
+		m, err := s.decryptSandboxMessage(m)
+		if err != nil {
+			s.logUndecryptable("GetSandboxMessages", m.ID, err)
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// versionFilter returns filter matching message only if its status and version are unchanged
+func versionFilter(mt sender.MessageTransaction) bson.M {
+	filter := bson.M{"_id": mt.ID, "status": mt.Status, "version": mt.Version}
+	if mt.Version == 0 {
+		// documents written before versioning have no version field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	return filter
+}
+
+// Ping checks primary is reachable, ping timeout is applied unless ctx has an earlier deadline
+func (s *store) Ping(ctx context.Context) error {
+	ctx, cf := context.WithTimeout(ctx, s.pingTimeout)
+	defer cf()
+
+	if err := s.c.Ping(ctx, readpref.Primary()); err != nil {
+		return fmt.Errorf("pinging failed, %s", err.Error())
+	}
+
+	return nil
+}
+
 // Close disconnects underlying mongo client
 func (s *store) Close() error {
 	ctx, cf := context.WithTimeout(context.Background(), s.disconnectTimeout)
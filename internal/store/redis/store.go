@@ -2,11 +2,14 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/mkaykisiz/sender"
 	envvars "github.com/mkaykisiz/sender/configs/env-vars"
 )
 
@@ -14,9 +17,23 @@ const (
 	messageExpiration = 1 * time.Hour
 )
 
+const (
+	messageStatsKeyPrefix   = "message-stats:"
+	messageEventsChannel    = "message-events"
+	signatureNonceKeyPrefix = "signature-nonce:"
+	rateLimitKeyPrefix      = "rate-limit:"
+)
+
 // Store defines behaviors of redis store
 type Store interface {
 	CacheMessageID(ctx context.Context, id string) error
+	GetMessageStats(ctx context.Context, windowMinutes int) (*sender.MessageStats, error)
+	CacheMessageStats(ctx context.Context, stats sender.MessageStats, ttl time.Duration) error
+	PublishMessageEvent(ctx context.Context, e sender.MessageEvent) error
+	SubscribeMessageEvents(ctx context.Context) (<-chan sender.MessageEvent, error)
+	ClaimNonce(ctx context.Context, keyID, nonce string, ttl time.Duration) (bool, error)
+	CountRequest(ctx context.Context, key string, window time.Duration) (count int64, resetAt time.Time, err error)
+	Ping(ctx context.Context) error
 	Close() error
 }
 
@@ -65,6 +82,7 @@ func NewStore(r envvars.Redis) (Store, error) {
 	}
 
 	c := redis.NewClient(opts)
+	c.AddHook(tracingHook{})
 
 	if err := c.Ping(context.Background()).Err(); err != nil {
 		return nil, fmt.Errorf("pinging failed, %s", err.Error())
@@ -84,6 +102,132 @@ func (s *store) CacheMessageID(ctx context.Context, id string) error {
 	return nil
 }
 
+// GetMessageStats returns cached message stats of given window, nil if there is none
+func (s *store) GetMessageStats(ctx context.Context, windowMinutes int) (*sender.MessageStats, error) {
+	data, err := s.c.Get(ctx, fmt.Sprintf("%s%d", messageStatsKeyPrefix, windowMinutes)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting message stats failed, %s", err.Error())
+	}
+
+	stats := &sender.MessageStats{}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, fmt.Errorf("unmarshalling message stats failed, %s", err.Error())
+	}
+
+	return stats, nil
+}
+
+// CacheMessageStats caches message stats by its window
+func (s *store) CacheMessageStats(ctx context.Context, stats sender.MessageStats, ttl time.Duration) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("marshalling message stats failed, %s", err.Error())
+	}
+
+	if err := s.c.Set(ctx, fmt.Sprintf("%s%d", messageStatsKeyPrefix, stats.WindowMinutes), data, ttl).Err(); err != nil {
+		return fmt.Errorf("setting message stats failed, %s", err.Error())
+	}
+
+	return nil
+}
+
+// PublishMessageEvent publishes message event to every subscriber of all replicas
+func (s *store) PublishMessageEvent(ctx context.Context, e sender.MessageEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshalling message event failed, %s", err.Error())
+	}
+
+	if err := s.c.Publish(ctx, messageEventsChannel, data).Err(); err != nil {
+		return fmt.Errorf("publishing message event failed, %s", err.Error())
+	}
+
+	return nil
+}
+
+// SubscribeMessageEvents subscribes to message events, returned channel is closed when ctx is done.
+// Malformed events are skipped.
+func (s *store) SubscribeMessageEvents(ctx context.Context) (<-chan sender.MessageEvent, error) {
+	ps := s.c.Subscribe(ctx, messageEventsChannel)
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, fmt.Errorf("subscribing message events failed, %s", err.Error())
+	}
+
+	events := make(chan sender.MessageEvent)
+	go func() {
+		defer close(events)
+		defer ps.Close()
+
+		messages := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var e sender.MessageEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+					continue
+				}
+
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// ClaimNonce stores signature nonce of key for ttl, it returns false if the nonce is stored already
+func (s *store) ClaimNonce(ctx context.Context, keyID, nonce string, ttl time.Duration) (bool, error) {
+	claimed, err := s.c.SetNX(ctx, signatureNonceKeyPrefix+keyID+":"+nonce, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("claiming nonce failed, %s", err.Error())
+	}
+
+	return claimed, nil
+}
+
+// CountRequest counts a request of key in the current fixed window and returns the count of the window
+// together with its end. Windows are aligned to the clock so that every replica shares them.
+func (s *store) CountRequest(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	start := time.Now().Truncate(window)
+	resetAt := start.Add(window)
+	windowKey := fmt.Sprintf("%s%s:%d", rateLimitKeyPrefix, key, start.Unix())
+
+	var incr *redis.IntCmd
+	_, err := s.c.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		incr = p.Incr(ctx, windowKey)
+		p.ExpireAt(ctx, windowKey, resetAt)
+		return nil
+	})
+	if err != nil {
+		return 0, resetAt, fmt.Errorf("counting request failed, %s", err.Error())
+	}
+
+	return incr.Val(), resetAt, nil
+}
+
+// Ping checks redis is reachable within deadline of ctx
+func (s *store) Ping(ctx context.Context) error {
+	if err := s.c.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("pinging failed, %s", err.Error())
+	}
+
+	return nil
+}
+
 // Close closes underlying redis client
 func (s *store) Close() error {
 	return s.c.Close()
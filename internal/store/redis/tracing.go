@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mkaykisiz/sender/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook starts a client span for every command and pipeline. Command arguments are not recorded
+// since they hold message ids and events with recipients.
+type tracingHook struct{}
+
+// BeforeProcess starts span of cmd
+func (tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = tracing.Start(ctx, "redis."+cmd.Name(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationName(cmd.Name())),
+	)
+	return ctx, nil
+}
+
+// AfterProcess ends span of cmd
+func (tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	tracing.End(trace.SpanFromContext(ctx), commandError(cmd))
+	return nil
+}
+
+// BeforeProcessPipeline starts span of pipeline
+func (tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = tracing.Start(ctx, "redis.pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, attribute.Int("db.redis.num_cmd", len(cmds))),
+	)
+	return ctx, nil
+}
+
+// AfterProcessPipeline ends span of pipeline, the first failed command is recorded as its error
+func (tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if err = commandError(cmd); err != nil {
+			break
+		}
+	}
+
+	tracing.End(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// commandError returns error of cmd, missing keys are not errors
+func commandError(cmd redis.Cmder) error {
+	if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name spans of the service are created with
+const InstrumentationName = "github.com/mkaykisiz/sender"
+
+// Config represents tracer provider configurations
+type Config struct {
+	ServiceName string
+	Environment string
+	Release     string
+	// Endpoint is the url spans are exported to, OTEL_EXPORTER_OTLP_* environment variables are used when empty
+	Endpoint    string
+	SampleRatio float64
+}
+
+// NewProvider creates tracer provider exporting spans over otlp http, registers it as the global tracer
+// provider and propagates w3c trace context. Spans are sampled by ratio unless their parent is sampled.
+func NewProvider(ctx context.Context, c Config) (*sdktrace.TracerProvider, error) {
+	var opts []otlptracehttp.Option
+	if c.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(c.Endpoint))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating span exporter failed, %s", err.Error())
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName),
+		semconv.ServiceVersion(c.Release),
+		semconv.DeploymentEnvironment(c.Environment),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating trace resource failed, %s", err.Error())
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp, nil
+}
+
+// Start starts span of name as a child of span in ctx, spans are dropped unless tracing is configured
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, opts...)
+}
+
+// End records err on span unless it is nil and ends span
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
+
+// RecordError records err on span and marks span failed unless err is nil
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// IDs returns hex encoded trace and span ids of span in ctx, they are empty when ctx carries no span
+func IDs(ctx context.Context) (traceID string, spanID string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+
+	return sc.TraceID().String(), sc.SpanID().String()
+}
+
+// Link returns link to span of hex encoded trace and span ids, ok is false when the ids are not valid
+func Link(traceID string, spanID string) (link trace.Link, ok bool) {
+	tid, err := trace.TraceIDFromHex(traceID)
+	if err != nil {
+		return trace.Link{}, false
+	}
+	sid, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return trace.Link{}, false
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: trace.FlagsSampled, Remote: true})
+	return trace.Link{SpanContext: sc}, true
+}
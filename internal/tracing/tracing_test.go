@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestIDsAndLink(t *testing.T) {
+	t.Run("span in context", func(t *testing.T) {
+		tp := sdktrace.NewTracerProvider()
+		ctx, span := tp.Tracer(InstrumentationName).Start(context.Background(), "CreateMessage")
+		defer span.End()
+
+		traceID, spanID := IDs(ctx)
+		link, ok := Link(traceID, spanID)
+
+		assert.True(t, ok)
+		assert.Equal(t, span.SpanContext().TraceID(), link.SpanContext.TraceID())
+		assert.Equal(t, span.SpanContext().SpanID(), link.SpanContext.SpanID())
+	})
+
+	t.Run("no span in context", func(t *testing.T) {
+		traceID, spanID := IDs(context.Background())
+		_, ok := Link(traceID, spanID)
+
+		assert.Empty(t, traceID)
+		assert.Empty(t, spanID)
+		assert.False(t, ok)
+	})
+}
+
+func TestEnd(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	_, ok := tp.Tracer(InstrumentationName).Start(context.Background(), "ok")
+	End(ok, nil)
+	_, failed := tp.Tracer(InstrumentationName).Start(context.Background(), "failed")
+	End(failed, errors.New("provider unavailable"))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "provider unavailable", spans[1].Status().Description)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-kit/kit/log"
 	kittransport "github.com/go-kit/kit/transport"
+	"github.com/mkaykisiz/sender/internal/requestid"
 )
 
 // compile-time proof of go-kit transport's error handler interface implementation
@@ -24,7 +25,12 @@ func NewErrorHandler(l log.Logger, endpointName string) *ErrorHandler {
 	}
 }
 
-// Handle logs error
-func (eh *ErrorHandler) Handle(_ context.Context, err error) {
+// Handle logs error with request id of ctx
+func (eh *ErrorHandler) Handle(ctx context.Context, err error) {
+	if id := requestid.FromContext(ctx); id != "" {
+		_ = eh.l.Log("requestID", id, "error", err.Error())
+		return
+	}
+
 	_ = eh.l.Log("error", err.Error())
 }
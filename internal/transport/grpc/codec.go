@@ -0,0 +1,221 @@
+package grpctransport
+
+import (
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/transport/grpc/pb"
+)
+
+func decodeHealthRequest(_ interface{}) sender.Request {
+	return &sender.HealthRequest{}
+}
+
+func encodeHealthResponse(_ sender.Response) interface{} {
+	return &pb.HealthResponse{}
+}
+
+func decodeStartStopMessageSendingRequest(r interface{}) sender.Request {
+	req := r.(*pb.StartStopMessageSendingRequest)
+
+	return &sender.StartStopMessageSendingRequest{Action: req.GetAction()}
+}
+
+func encodeStartStopMessageSendingResponse(r sender.Response) interface{} {
+	res := r.(sender.StartStopMessageSendingResponse)
+
+	return &pb.StartStopMessageSendingResponse{Status: res.Status}
+}
+
+func decodeGetWorkerStatusRequest(r interface{}) sender.Request {
+	req := r.(*pb.GetWorkerStatusRequest)
+
+	return &sender.GetWorkerStatusRequest{Batches: int(req.GetBatches())}
+}
+
+func encodeGetWorkerStatusResponse(r sender.Response) interface{} {
+	res := r.(sender.GetWorkerStatusResponse)
+
+	return &pb.GetWorkerStatusResponse{Status: toPBWorkerStatus(res.Status)}
+}
+
+func decodeRunWorkerBatchRequest(r interface{}) sender.Request {
+	req := r.(*pb.RunWorkerBatchRequest)
+
+	return &sender.RunWorkerBatchRequest{DryRun: req.GetDryRun()}
+}
+
+func encodeRunWorkerBatchResponse(r sender.Response) interface{} {
+	res := r.(sender.RunWorkerBatchResponse)
+
+	messages := make([]*pb.PlannedSend, 0, len(res.Messages))
+	for _, p := range res.Messages {
+		messages = append(messages, &pb.PlannedSend{
+			Message:  toPBMessageSummary(p.Message),
+			Action:   p.Action,
+			Provider: p.Provider,
+		})
+	}
+
+	return &pb.RunWorkerBatchResponse{Status: res.Status, Messages: messages}
+}
+
+func decodeCreateMessageRequest(r interface{}) sender.Request {
+	req := r.(*pb.CreateMessageRequest)
+
+	return &sender.CreateMessageRequest{
+		Content:   req.GetContent(),
+		Recipient: req.GetRecipient(),
+		SendAt:    fromTimestamp(req.GetSendAt()),
+		Priority:  int(req.GetPriority()),
+		Tags:      req.GetTags(),
+	}
+}
+
+func encodeCreateMessageResponse(r sender.Response) interface{} {
+	res := r.(sender.CreateMessageResponse)
+
+	return &pb.CreateMessageResponse{Message: toPBMessage(res.Message)}
+}
+
+func decodeRetrieveSentMessagesRequest(r interface{}) sender.Request {
+	req := r.(*pb.RetrieveSentMessagesRequest)
+
+	return &sender.RetrieveSentMessagesRequest{
+		Cursor:      req.GetCursor(),
+		Limit:       req.GetLimit(),
+		Recipient:   req.GetRecipient(),
+		Status:      req.GetStatus(),
+		Tags:        req.GetTags(),
+		CreatedFrom: fromTimestamp(req.GetCreatedFrom()),
+		CreatedTo:   fromTimestamp(req.GetCreatedTo()),
+		SentFrom:    fromTimestamp(req.GetSentFrom()),
+		SentTo:      fromTimestamp(req.GetSentTo()),
+		SortBy:      req.GetSortBy(),
+		SortOrder:   req.GetSortOrder(),
+	}
+}
+
+func encodeRetrieveSentMessagesResponse(r sender.Response) interface{} {
+	res := r.(sender.RetrieveSentMessagesResponse)
+
+	messages := make([]*pb.MessageSummary, 0, len(res.Messages))
+	for _, m := range res.Messages {
+		messages = append(messages, toPBMessageSummary(m))
+	}
+
+	return &pb.RetrieveSentMessagesResponse{Messages: messages, NextCursor: res.NextCursor}
+}
+
+func decodeGetMessageRequest(r interface{}) sender.Request {
+	req := r.(*pb.GetMessageRequest)
+
+	return &sender.GetMessageRequest{ID: req.GetId()}
+}
+
+func encodeGetMessageResponse(r sender.Response) interface{} {
+	res := r.(sender.GetMessageResponse)
+
+	return &pb.GetMessageResponse{Message: toPBMessage(res.Message)}
+}
+
+func decodeUpdateMessageRequest(r interface{}) sender.Request {
+	req := r.(*pb.UpdateMessageRequest)
+
+	return &sender.UpdateMessageRequest{
+		ID:        req.GetId(),
+		Version:   req.Version,
+		Content:   req.Content,
+		Recipient: req.Recipient,
+		SendAt:    fromTimestamp(req.GetSendAt()),
+		SendNow:   req.GetSendNow(),
+		Priority:  fromOptionalInt32(req.Priority),
+	}
+}
+
+func encodeUpdateMessageResponse(r sender.Response) interface{} {
+	res := r.(sender.UpdateMessageResponse)
+
+	return &pb.UpdateMessageResponse{Message: toPBMessage(res.Message)}
+}
+
+func decodeCancelMessageRequest(r interface{}) sender.Request {
+	req := r.(*pb.CancelMessageRequest)
+
+	return &sender.CancelMessageRequest{ID: req.GetId()}
+}
+
+func encodeCancelMessageResponse(r sender.Response) interface{} {
+	res := r.(sender.CancelMessageResponse)
+
+	return &pb.CancelMessageResponse{Message: toPBMessage(res.Message)}
+}
+
+func decodeCancelMessagesRequest(r interface{}) sender.Request {
+	req := r.(*pb.CancelMessagesRequest)
+
+	return &sender.CancelMessagesRequest{
+		IDs:         req.GetIds(),
+		Recipient:   req.GetRecipient(),
+		CreatedFrom: fromTimestamp(req.GetCreatedFrom()),
+		CreatedTo:   fromTimestamp(req.GetCreatedTo()),
+	}
+}
+
+func encodeCancelMessagesResponse(r sender.Response) interface{} {
+	res := r.(sender.CancelMessagesResponse)
+
+	return &pb.CancelMessagesResponse{CancelledCount: res.CancelledCount, SkippedCount: res.SkippedCount, SkippedIds: res.SkippedIDs}
+}
+
+func decodeRequeueMessagesRequest(r interface{}) sender.Request {
+	req := r.(*pb.RequeueMessagesRequest)
+
+	return &sender.RequeueMessagesRequest{
+		IDs:         req.GetIds(),
+		Status:      req.GetStatus(),
+		Recipient:   req.GetRecipient(),
+		CreatedFrom: fromTimestamp(req.GetCreatedFrom()),
+		CreatedTo:   fromTimestamp(req.GetCreatedTo()),
+	}
+}
+
+func encodeRequeueMessagesResponse(r sender.Response) interface{} {
+	res := r.(sender.RequeueMessagesResponse)
+
+	return &pb.RequeueMessagesResponse{RequeuedCount: res.RequeuedCount}
+}
+
+func decodeGetStatsRequest(r interface{}) sender.Request {
+	req := r.(*pb.GetStatsRequest)
+
+	return &sender.GetStatsRequest{WindowMinutes: int(req.GetWindowMinutes())}
+}
+
+func encodeGetStatsResponse(r sender.Response) interface{} {
+	res := r.(sender.GetStatsResponse)
+
+	return &pb.GetStatsResponse{Stats: toPBMessageStats(res.Stats)}
+}
+
+func decodeExportMessagesRequest(r interface{}) sender.Request {
+	req := r.(*pb.ExportMessagesRequest)
+
+	return &sender.ExportMessagesRequest{
+		Recipient:   req.GetRecipient(),
+		Status:      req.GetStatus(),
+		Tags:        req.GetTags(),
+		CreatedFrom: fromTimestamp(req.GetCreatedFrom()),
+		CreatedTo:   fromTimestamp(req.GetCreatedTo()),
+		SentFrom:    fromTimestamp(req.GetSentFrom()),
+		SentTo:      fromTimestamp(req.GetSentTo()),
+	}
+}
+
+func decodeStreamMessageEventsRequest(r interface{}) sender.Request {
+	req := r.(*pb.StreamMessageEventsRequest)
+
+	return &sender.StreamMessageEventsRequest{
+		Recipient: req.GetRecipient(),
+		Campaign:  req.GetCampaign(),
+		Status:    req.GetStatus(),
+	}
+}
@@ -0,0 +1,158 @@
+package grpctransport
+
+import (
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/transport/grpc/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toPBMessage(m *sender.MessageTransaction) *pb.Message {
+	if m == nil {
+		return nil
+	}
+
+	history := make([]*pb.MessageRevision, 0, len(m.History))
+	for _, r := range m.History {
+		history = append(history, &pb.MessageRevision{
+			Content:   r.Content,
+			Recipient: r.Recipient,
+			Priority:  int32(r.Priority),
+			SendAt:    toTimestamp(r.SendAt),
+			Version:   r.Version,
+			ChangedAt: timestamppb.New(r.ChangedAt),
+		})
+	}
+
+	return &pb.Message{
+		Id:                m.ID.Hex(),
+		Content:           m.Content,
+		Recipient:         m.Recipient,
+		Status:            m.Status,
+		Version:           m.Version,
+		Priority:          int32(m.Priority),
+		Tags:              m.Tags,
+		Attempts:          int32(m.Attempts),
+		SendAt:            toTimestamp(m.SendAt),
+		SentAt:            toTimestamp(m.SentAt),
+		CreatedAt:         timestamppb.New(m.CreatedAt),
+		UpdatedAt:         toTimestamp(m.UpdatedAt),
+		History:           history,
+		Provider:          m.Provider,
+		ProviderMessageId: m.ProviderMessageID,
+		LastError:         m.LastError,
+	}
+}
+
+func toPBMessageSummary(m sender.ResponseMessage) *pb.MessageSummary {
+	return &pb.MessageSummary{
+		Id:        m.ID,
+		Content:   m.Content,
+		Recipient: m.Recipient,
+		Status:    m.Status,
+		Tags:      m.Tags,
+		SentAt:    toTimestamp(m.SentAt),
+		CreatedAt: timestamppb.New(m.CreatedAt),
+	}
+}
+
+func toPBMessageEvent(e sender.MessageEvent) *pb.MessageEvent {
+	return &pb.MessageEvent{
+		Id:         e.ID,
+		Status:     e.Status,
+		Recipient:  e.Recipient,
+		Tags:       e.Tags,
+		Version:    e.Version,
+		OccurredAt: timestamppb.New(e.OccurredAt),
+	}
+}
+
+func toPBMessageStats(s *sender.MessageStats) *pb.MessageStats {
+	if s == nil {
+		return nil
+	}
+
+	providers := make([]*pb.ProviderStats, 0, len(s.Providers))
+	for _, p := range s.Providers {
+		providers = append(providers, &pb.ProviderStats{
+			Provider:    p.Provider,
+			Sent:        p.Sent,
+			Failed:      p.Failed,
+			FailureRate: p.FailureRate,
+		})
+	}
+
+	return &pb.MessageStats{
+		StatusCounts:            s.StatusCounts,
+		OldestPendingCreatedAt:  toTimestamp(s.OldestPendingCreatedAt),
+		OldestPendingAgeSeconds: s.OldestPendingAgeSeconds,
+		WindowMinutes:           int32(s.WindowMinutes),
+		SentInWindow:            s.SentInWindow,
+		ThroughputPerMinute:     s.ThroughputPerMinute,
+		Providers:               providers,
+		Latency: &pb.LatencyStats{
+			P50Ms: s.Latency.P50,
+			P90Ms: s.Latency.P90,
+			P99Ms: s.Latency.P99,
+		},
+		GeneratedAt: timestamppb.New(s.GeneratedAt),
+	}
+}
+
+func toPBWorkerStatus(s *sender.WorkerStatus) *pb.WorkerStatus {
+	if s == nil {
+		return nil
+	}
+
+	batches := make([]*pb.BatchSummary, 0, len(s.Batches))
+	for _, b := range s.Batches {
+		batches = append(batches, &pb.BatchSummary{
+			StartedAt:  timestamppb.New(b.StartedAt),
+			DurationMs: b.DurationMS,
+			Selected:   b.Selected,
+			Sent:       b.Sent,
+			Failed:     b.Failed,
+			Invalid:    b.Invalid,
+			Blocked:    b.Blocked,
+			Error:      b.Error,
+		})
+	}
+
+	return &pb.WorkerStatus{
+		Running:         s.Running,
+		IntervalSeconds: s.IntervalSeconds,
+		BatchSize:       s.BatchSize,
+		LastRunAt:       toTimestamp(s.LastRunAt),
+		NextRunAt:       toTimestamp(s.NextRunAt),
+		Batches:         batches,
+	}
+}
+
+// toTimestamp returns nil for nil time so that optional times stay unset
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(*t)
+}
+
+// fromTimestamp returns nil for unset timestamp
+func fromTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+
+	t := ts.AsTime()
+	return &t
+}
+
+func fromOptionalInt32(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+
+	i := int(*v)
+	return &i
+}
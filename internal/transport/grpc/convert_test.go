@@ -0,0 +1,81 @@
+package grpctransport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/transport/grpc/pb"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestToPBMessage(t *testing.T) {
+	assert.Nil(t, toPBMessage(nil))
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sendAt := createdAt.Add(time.Hour)
+	m := &sender.MessageTransaction{
+		ID:        primitive.NewObjectID(),
+		Content:   "Test message",
+		Recipient: "+905551234567",
+		Status:    "pending",
+		Version:   2,
+		Priority:  5,
+		SendAt:    &sendAt,
+		CreatedAt: createdAt,
+		History: []sender.MessageRevision{
+			{Content: "Old message", Recipient: "+905551234567", Version: 1, ChangedAt: createdAt},
+		},
+	}
+
+	res := toPBMessage(m)
+
+	assert.Equal(t, m.ID.Hex(), res.Id)
+	assert.Equal(t, int32(5), res.Priority)
+	assert.Equal(t, sendAt, res.SendAt.AsTime())
+	assert.Nil(t, res.SentAt)
+	assert.Equal(t, createdAt, res.CreatedAt.AsTime())
+	if assert.Len(t, res.History, 1) {
+		assert.Equal(t, "Old message", res.History[0].Content)
+		assert.Nil(t, res.History[0].SendAt)
+	}
+}
+
+func TestToPBWorkerStatus(t *testing.T) {
+	assert.Nil(t, toPBWorkerStatus(nil))
+
+	res := toPBWorkerStatus(&sender.WorkerStatus{
+		Running: true,
+		Batches: []sender.BatchSummary{{Selected: 3, Sent: 1, Failed: 1, Blocked: 1}},
+	})
+
+	assert.True(t, res.Running)
+	assert.Nil(t, res.LastRunAt)
+	if assert.Len(t, res.Batches, 1) {
+		assert.Equal(t, int64(1), res.Batches[0].Blocked)
+	}
+}
+
+func TestDecodeUpdateMessageRequest(t *testing.T) {
+	sendAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	priority := int32(7)
+
+	req := decodeUpdateMessageRequest(&pb.UpdateMessageRequest{
+		Id:       "65a1b2c3d4e5f6a7b8c9d0e1",
+		SendAt:   timestamppb.New(sendAt),
+		Priority: &priority,
+	}).(*sender.UpdateMessageRequest)
+
+	assert.Equal(t, "65a1b2c3d4e5f6a7b8c9d0e1", req.ID)
+	assert.Equal(t, sendAt, *req.SendAt)
+	assert.Equal(t, 7, *req.Priority)
+	assert.Nil(t, req.Content)
+	assert.False(t, req.SendNow)
+
+	req = decodeUpdateMessageRequest(&pb.UpdateMessageRequest{Id: "65a1b2c3d4e5f6a7b8c9d0e1", SendNow: true}).(*sender.UpdateMessageRequest)
+
+	assert.True(t, req.SendNow)
+	assert.Nil(t, req.SendAt)
+}
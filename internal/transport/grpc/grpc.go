@@ -0,0 +1,411 @@
+package grpctransport
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/auth"
+	"github.com/mkaykisiz/sender/internal/clientip"
+	"github.com/mkaykisiz/sender/internal/endpoints"
+	"github.com/mkaykisiz/sender/internal/localization"
+	"github.com/mkaykisiz/sender/internal/redact"
+	"github.com/mkaykisiz/sender/internal/requestid"
+	"github.com/mkaykisiz/sender/internal/transport"
+	"github.com/mkaykisiz/sender/internal/transport/grpc/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// endpoint names
+const (
+	health                  = "Health"
+	startStopMessageSending = "StartStopMessageSending"
+	getWorkerStatus         = "GetWorkerStatus"
+	runWorkerBatch          = "RunWorkerBatch"
+	createMessage           = "CreateMessage"
+	retrieveSentMessages    = "RetrieveSentMessages"
+	getMessage              = "GetMessage"
+	updateMessage           = "UpdateMessage"
+	cancelMessage           = "CancelMessage"
+	cancelMessages          = "CancelMessages"
+	requeueMessages         = "RequeueMessages"
+	getStats                = "GetStats"
+)
+
+// errorDomain is the domain of error info details of returned statuses
+const errorDomain = "sender"
+
+const invalidResponseError = "invalid response"
+
+// compile-time proof of grpc sender server interface implementation
+var _ pb.SenderServer = (*grpcServer)(nil)
+
+// grpcServer represents grpc sender server
+type grpcServer struct {
+	pb.UnimplementedSenderServer
+
+	health                  kitgrpc.Handler
+	startStopMessageSending kitgrpc.Handler
+	getWorkerStatus         kitgrpc.Handler
+	runWorkerBatch          kitgrpc.Handler
+	createMessage           kitgrpc.Handler
+	retrieveSentMessages    kitgrpc.Handler
+	getMessage              kitgrpc.Handler
+	updateMessage           kitgrpc.Handler
+	cancelMessage           kitgrpc.Handler
+	cancelMessages          kitgrpc.Handler
+	requeueMessages         kitgrpc.Handler
+	getStats                kitgrpc.Handler
+	exportMessages          endpoint.Endpoint
+	streamMessageEvents     endpoint.Endpoint
+}
+
+// NewGRPCServer makes and returns grpc sender server served by the same endpoints as http transport,
+// am authenticates every method but health
+func NewGRPCServer(l log.Logger, s sender.Service, am auth.Middleware, rl endpoint.Middleware) pb.SenderServer {
+	es := endpoints.MakeEndpoints(s, am, rl)
+
+	return &grpcServer{
+		exportMessages:      es.ExportMessagesEndpoint,
+		streamMessageEvents: es.StreamMessageEventsEndpoint,
+		health: kitgrpc.NewServer(
+			statusErrors(es.HealthEndpoint), makeDecoder(decodeHealthRequest), makeEncoder(encodeHealthResponse), makeDefaultServerOptions(l, health)...,
+		),
+		startStopMessageSending: kitgrpc.NewServer(
+			statusErrors(es.StartStopMessageSendingEndpoint), makeDecoder(decodeStartStopMessageSendingRequest), makeEncoder(encodeStartStopMessageSendingResponse), makeDefaultServerOptions(l, startStopMessageSending)...,
+		),
+		getWorkerStatus: kitgrpc.NewServer(
+			statusErrors(es.GetWorkerStatusEndpoint), makeDecoder(decodeGetWorkerStatusRequest), makeEncoder(encodeGetWorkerStatusResponse), makeDefaultServerOptions(l, getWorkerStatus)...,
+		),
+		runWorkerBatch: kitgrpc.NewServer(
+			statusErrors(es.RunWorkerBatchEndpoint), makeDecoder(decodeRunWorkerBatchRequest), makeEncoder(encodeRunWorkerBatchResponse), makeDefaultServerOptions(l, runWorkerBatch)...,
+		),
+		createMessage: kitgrpc.NewServer(
+			statusErrors(es.CreateMessageEndpoint), makeDecoder(decodeCreateMessageRequest), makeEncoder(encodeCreateMessageResponse), makeDefaultServerOptions(l, createMessage)...,
+		),
+		retrieveSentMessages: kitgrpc.NewServer(
+			statusErrors(es.RetrieveSentMessagesEndpoint), makeDecoder(decodeRetrieveSentMessagesRequest), makeEncoder(encodeRetrieveSentMessagesResponse), makeDefaultServerOptions(l, retrieveSentMessages)...,
+		),
+		getMessage: kitgrpc.NewServer(
+			statusErrors(es.GetMessageEndpoint), makeDecoder(decodeGetMessageRequest), makeEncoder(encodeGetMessageResponse), makeDefaultServerOptions(l, getMessage)...,
+		),
+		updateMessage: kitgrpc.NewServer(
+			statusErrors(es.UpdateMessageEndpoint), makeDecoder(decodeUpdateMessageRequest), makeEncoder(encodeUpdateMessageResponse), makeDefaultServerOptions(l, updateMessage)...,
+		),
+		cancelMessage: kitgrpc.NewServer(
+			statusErrors(es.CancelMessageEndpoint), makeDecoder(decodeCancelMessageRequest), makeEncoder(encodeCancelMessageResponse), makeDefaultServerOptions(l, cancelMessage)...,
+		),
+		cancelMessages: kitgrpc.NewServer(
+			statusErrors(es.CancelMessagesEndpoint), makeDecoder(decodeCancelMessagesRequest), makeEncoder(encodeCancelMessagesResponse), makeDefaultServerOptions(l, cancelMessages)...,
+		),
+		requeueMessages: kitgrpc.NewServer(
+			statusErrors(es.RequeueMessagesEndpoint), makeDecoder(decodeRequeueMessagesRequest), makeEncoder(encodeRequeueMessagesResponse), makeDefaultServerOptions(l, requeueMessages)...,
+		),
+		getStats: kitgrpc.NewServer(
+			statusErrors(es.GetStatsEndpoint), makeDecoder(decodeGetStatsRequest), makeEncoder(encodeGetStatsResponse), makeDefaultServerOptions(l, getStats)...,
+		),
+	}
+}
+
+// Health serves health
+func (g *grpcServer) Health(ctx context.Context, r *pb.HealthRequest) (*pb.HealthResponse, error) {
+	_, res, err := g.health.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.HealthResponse), nil
+}
+
+// StartStopMessageSending serves start stop message sending
+func (g *grpcServer) StartStopMessageSending(ctx context.Context, r *pb.StartStopMessageSendingRequest) (*pb.StartStopMessageSendingResponse, error) {
+	_, res, err := g.startStopMessageSending.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.StartStopMessageSendingResponse), nil
+}
+
+// GetWorkerStatus serves get worker status
+func (g *grpcServer) GetWorkerStatus(ctx context.Context, r *pb.GetWorkerStatusRequest) (*pb.GetWorkerStatusResponse, error) {
+	_, res, err := g.getWorkerStatus.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.GetWorkerStatusResponse), nil
+}
+
+// RunWorkerBatch serves run worker batch
+func (g *grpcServer) RunWorkerBatch(ctx context.Context, r *pb.RunWorkerBatchRequest) (*pb.RunWorkerBatchResponse, error) {
+	_, res, err := g.runWorkerBatch.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.RunWorkerBatchResponse), nil
+}
+
+// CreateMessage serves create message
+func (g *grpcServer) CreateMessage(ctx context.Context, r *pb.CreateMessageRequest) (*pb.CreateMessageResponse, error) {
+	_, res, err := g.createMessage.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.CreateMessageResponse), nil
+}
+
+// RetrieveSentMessages serves retrieve sent messages
+func (g *grpcServer) RetrieveSentMessages(ctx context.Context, r *pb.RetrieveSentMessagesRequest) (*pb.RetrieveSentMessagesResponse, error) {
+	_, res, err := g.retrieveSentMessages.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.RetrieveSentMessagesResponse), nil
+}
+
+// GetMessage serves get message
+func (g *grpcServer) GetMessage(ctx context.Context, r *pb.GetMessageRequest) (*pb.GetMessageResponse, error) {
+	_, res, err := g.getMessage.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.GetMessageResponse), nil
+}
+
+// UpdateMessage serves update message
+func (g *grpcServer) UpdateMessage(ctx context.Context, r *pb.UpdateMessageRequest) (*pb.UpdateMessageResponse, error) {
+	_, res, err := g.updateMessage.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.UpdateMessageResponse), nil
+}
+
+// CancelMessage serves cancel message
+func (g *grpcServer) CancelMessage(ctx context.Context, r *pb.CancelMessageRequest) (*pb.CancelMessageResponse, error) {
+	_, res, err := g.cancelMessage.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.CancelMessageResponse), nil
+}
+
+// CancelMessages serves cancel messages
+func (g *grpcServer) CancelMessages(ctx context.Context, r *pb.CancelMessagesRequest) (*pb.CancelMessagesResponse, error) {
+	_, res, err := g.cancelMessages.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.CancelMessagesResponse), nil
+}
+
+// RequeueMessages serves requeue messages
+func (g *grpcServer) RequeueMessages(ctx context.Context, r *pb.RequeueMessagesRequest) (*pb.RequeueMessagesResponse, error) {
+	_, res, err := g.requeueMessages.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.RequeueMessagesResponse), nil
+}
+
+// GetStats serves get stats
+func (g *grpcServer) GetStats(ctx context.Context, r *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
+	_, res, err := g.getStats.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.GetStatsResponse), nil
+}
+
+// ExportMessages streams exported messages
+func (g *grpcServer) ExportMessages(r *pb.ExportMessagesRequest, stream pb.Sender_ExportMessagesServer) error {
+	ctx := newStreamContext(stream.Context())
+
+	req, err := makeDecoder(decodeExportMessagesRequest)(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	response, err := g.exportMessages(ctx, req)
+	if err != nil {
+		return newStatusError(ctx, err)
+	}
+
+	res := response.(sender.ExportMessagesResponse)
+	if res.APIError() != nil {
+		return newStatusError(ctx, res.APIError())
+	}
+
+	err = res.Messages(func(m sender.ResponseMessage) error {
+		return stream.Send(toPBMessageSummary(m))
+	})
+	if err != nil {
+		return newStatusError(ctx, apierror.NewInternalServerError(err))
+	}
+
+	return nil
+}
+
+// StreamMessageEvents streams message events until the client cancels
+func (g *grpcServer) StreamMessageEvents(r *pb.StreamMessageEventsRequest, stream pb.Sender_StreamMessageEventsServer) error {
+	ctx := newStreamContext(stream.Context())
+
+	req, err := makeDecoder(decodeStreamMessageEventsRequest)(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	response, err := g.streamMessageEvents(ctx, req)
+	if err != nil {
+		return newStatusError(ctx, err)
+	}
+
+	res := response.(sender.StreamMessageEventsResponse)
+	if res.APIError() != nil {
+		return newStatusError(ctx, res.APIError())
+	}
+
+	for e := range res.Events {
+		if res.MaskPII {
+			e = redact.MessageEvent(e)
+		}
+		if err := stream.Send(toPBMessageEvent(e)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func makeDefaultServerOptions(l log.Logger, endpointName string) []kitgrpc.ServerOption {
+	options := []kitgrpc.ServerOption{
+		kitgrpc.ServerErrorHandler(transport.NewErrorHandler(l, endpointName)),
+		kitgrpc.ServerBefore(localization.AddLocalizerToGRPCContext),
+		kitgrpc.ServerBefore(auth.GRPCToContext),
+		kitgrpc.ServerBefore(clientip.GRPCToContext),
+		kitgrpc.ServerBefore(requestid.GRPCToContext),
+	}
+	return options
+}
+
+// statusErrors converts errors returned by endpoint, such as authentication errors, to grpc statuses
+// while the localizer is still in context
+func statusErrors(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		res, err := next(ctx, request)
+		if err != nil {
+			return nil, newStatusError(ctx, err)
+		}
+		return res, nil
+	}
+}
+
+// makeDecoder converts protobuf request to service request, sets its ip address and validates it
+func makeDecoder(convert func(r interface{}) sender.Request) kitgrpc.DecodeRequestFunc {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := convert(r)
+
+		req.SetIPAddress(clientip.FromContext(ctx))
+
+		if err := transport.Validate(req); err != nil {
+			apiError := apierror.NewValidationError(err.Error(), "")
+			apiError.BaseError = err
+			return nil, newStatusError(ctx, apiError)
+		}
+
+		return req, nil
+	}
+}
+
+// makeEncoder converts service response to protobuf response or its api error to grpc status
+func makeEncoder(convert func(r sender.Response) interface{}) kitgrpc.EncodeResponseFunc {
+	return func(ctx context.Context, response interface{}) (interface{}, error) {
+		r, ok := response.(sender.Response)
+		if !ok {
+			return nil, newStatusError(ctx, errors.New(invalidResponseError))
+		}
+
+		if r.APIError() != nil {
+			return nil, newStatusError(ctx, r.APIError())
+		}
+
+		return convert(r), nil
+	}
+}
+
+// newStreamContext adds localizer, api key, client ip address and request id of streaming request to its context like server before
+// functions of unary methods
+func newStreamContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	ctx = localization.AddLocalizerToGRPCContext(ctx, md)
+	ctx = clientip.GRPCToContext(ctx, md)
+	ctx = requestid.GRPCToContext(ctx, md)
+	return auth.GRPCToContext(ctx, md)
+}
+
+// newStatusError returns localized grpc status of api error, api error name and code are given in error info details
+func newStatusError(ctx context.Context, err error) error {
+	var apiErr *apierror.APIError
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.Is(err, sender.ErrServiceUnavailable):
+		apiErr = apierror.NewServiceUnavailableError(err)
+	default:
+		apiErr = apierror.DefaultInternalServerError
+	}
+
+	// localizing a copy keeps shared default errors untouched
+	localized := *apiErr
+	localized.Localize(localization.GetLocalizerFromContext(ctx))
+
+	st := status.New(grpcCode(localized.Code), localized.Message)
+	withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   localized.Name,
+		Domain:   errorDomain,
+		Metadata: map[string]string{"code": strconv.Itoa(localized.Code)},
+	})
+	if detailsErr == nil {
+		st = withDetails
+	}
+
+	if localized.RetryAfter > 0 {
+		withRetryInfo, detailsErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(localized.RetryAfter)})
+		if detailsErr == nil {
+			st = withRetryInfo
+		}
+	}
+
+	return st.Err()
+}
+
+// grpcCode maps api error code to grpc status code
+func grpcCode(code int) codes.Code {
+	switch code {
+	case apierror.CodeValidationError, apierror.CodeBadRequestError:
+		return codes.InvalidArgument
+	case apierror.CodeUnauthorizedError:
+		return codes.Unauthenticated
+	case apierror.CodeConflictError:
+		return codes.Aborted
+	case apierror.CodeNotFoundError:
+		return codes.NotFound
+	case apierror.CodeForbiddenError:
+		return codes.PermissionDenied
+	case apierror.CodeTooManyRequestsError:
+		return codes.ResourceExhausted
+	case apierror.CodeServiceUnavailableError:
+		return codes.Unavailable
+	case apierror.CodeInternalServerError:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
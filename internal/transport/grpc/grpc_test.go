@@ -0,0 +1,79 @@
+package grpctransport
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	envvars "github.com/mkaykisiz/sender/configs/env-vars"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/localization"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		code int
+		want codes.Code
+	}{
+		{apierror.CodeValidationError, codes.InvalidArgument},
+		{apierror.CodeBadRequestError, codes.InvalidArgument},
+		{apierror.CodeUnauthorizedError, codes.Unauthenticated},
+		{apierror.CodeConflictError, codes.Aborted},
+		{apierror.CodeNotFoundError, codes.NotFound},
+		{apierror.CodeForbiddenError, codes.PermissionDenied},
+		{apierror.CodeTooManyRequestsError, codes.ResourceExhausted},
+		{apierror.CodeServiceUnavailableError, codes.Unavailable},
+		{apierror.CodeInternalServerError, codes.Internal},
+		{0, codes.Unknown},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, grpcCode(tt.code), "code %d", tt.code)
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+	err := localization.InitializeBundle(envvars.Localization{LanguageFilesDirectory: "../../localization/language-files"})
+	assert.NoError(t, err)
+	ctx := localization.AddLocalizerToGRPCContext(context.Background(), metadata.MD{})
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+		wantName string
+	}{
+		{"api error", apierror.NewNotFoundError("message not found", ""), codes.NotFound, apierror.NameNotFoundError},
+		{"service unavailable", sender.ErrServiceUnavailable, codes.Unavailable, apierror.NameServiceUnavailableError},
+		{"plain error", errors.New("connection refused"), codes.Internal, apierror.NameInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(newStatusError(ctx, tt.err))
+
+			assert.True(t, ok)
+			assert.Equal(t, tt.wantCode, st.Code())
+			if assert.NotEmpty(t, st.Details()) {
+				assert.Equal(t, tt.wantName, st.Details()[0].(*errdetails.ErrorInfo).Reason)
+			}
+		})
+	}
+
+	t.Run("retry after", func(t *testing.T) {
+		apiErr := apierror.NewTooManyRequestsError(errors.New("rate limit exceeded"), 30*time.Second)
+
+		st, _ := status.FromError(newStatusError(ctx, apiErr))
+
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+		assert.Len(t, st.Details(), 2)
+		assert.Equal(t, int64(30), st.Details()[1].(*errdetails.RetryInfo).RetryDelay.Seconds)
+	})
+}
@@ -0,0 +1,656 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sender.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Sender_Health_FullMethodName                  = "/sender.v1.Sender/Health"
+	Sender_StartStopMessageSending_FullMethodName = "/sender.v1.Sender/StartStopMessageSending"
+	Sender_GetWorkerStatus_FullMethodName         = "/sender.v1.Sender/GetWorkerStatus"
+	Sender_RunWorkerBatch_FullMethodName          = "/sender.v1.Sender/RunWorkerBatch"
+	Sender_CreateMessage_FullMethodName           = "/sender.v1.Sender/CreateMessage"
+	Sender_RetrieveSentMessages_FullMethodName    = "/sender.v1.Sender/RetrieveSentMessages"
+	Sender_GetMessage_FullMethodName              = "/sender.v1.Sender/GetMessage"
+	Sender_UpdateMessage_FullMethodName           = "/sender.v1.Sender/UpdateMessage"
+	Sender_CancelMessage_FullMethodName           = "/sender.v1.Sender/CancelMessage"
+	Sender_CancelMessages_FullMethodName          = "/sender.v1.Sender/CancelMessages"
+	Sender_RequeueMessages_FullMethodName         = "/sender.v1.Sender/RequeueMessages"
+	Sender_GetStats_FullMethodName                = "/sender.v1.Sender/GetStats"
+	Sender_ExportMessages_FullMethodName          = "/sender.v1.Sender/ExportMessages"
+	Sender_StreamMessageEvents_FullMethodName     = "/sender.v1.Sender/StreamMessageEvents"
+)
+
+// SenderClient is the client API for Sender service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Sender exposes the operations of the HTTP API over gRPC. Errors are returned as gRPC statuses
+// carrying a google.rpc.ErrorInfo detail whose reason is the api error name.
+type SenderClient interface {
+	// Health checks health
+	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+	// StartStopMessageSending starts or stops message sending
+	StartStopMessageSending(ctx context.Context, in *StartStopMessageSendingRequest, opts ...grpc.CallOption) (*StartStopMessageSendingResponse, error)
+	// GetWorkerStatus returns state of the worker and its recent batches
+	GetWorkerStatus(ctx context.Context, in *GetWorkerStatusRequest, opts ...grpc.CallOption) (*GetWorkerStatusResponse, error)
+	// RunWorkerBatch starts a worker batch right away or previews the next batch on dry run
+	RunWorkerBatch(ctx context.Context, in *RunWorkerBatchRequest, opts ...grpc.CallOption) (*RunWorkerBatchResponse, error)
+	// CreateMessage queues a new message
+	CreateMessage(ctx context.Context, in *CreateMessageRequest, opts ...grpc.CallOption) (*CreateMessageResponse, error)
+	// RetrieveSentMessages retrieves sent messages page by page
+	RetrieveSentMessages(ctx context.Context, in *RetrieveSentMessagesRequest, opts ...grpc.CallOption) (*RetrieveSentMessagesResponse, error)
+	// GetMessage returns message with its status, timestamps, attempts and provider info
+	GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*GetMessageResponse, error)
+	// UpdateMessage changes a message which is not sent yet
+	UpdateMessage(ctx context.Context, in *UpdateMessageRequest, opts ...grpc.CallOption) (*UpdateMessageResponse, error)
+	// CancelMessage cancels a pending or failed message by id
+	CancelMessage(ctx context.Context, in *CancelMessageRequest, opts ...grpc.CallOption) (*CancelMessageResponse, error)
+	// CancelMessages cancels pending and failed messages matching filter, messages claimed by a worker are skipped
+	CancelMessages(ctx context.Context, in *CancelMessagesRequest, opts ...grpc.CallOption) (*CancelMessagesResponse, error)
+	// RequeueMessages moves failed and invalid messages matching filter back to pending
+	RequeueMessages(ctx context.Context, in *RequeueMessagesRequest, opts ...grpc.CallOption) (*RequeueMessagesResponse, error)
+	// GetStats returns queue and delivery statistics
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
+	// ExportMessages streams every message matching filters ordered by created_at
+	ExportMessages(ctx context.Context, in *ExportMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MessageSummary], error)
+	// StreamMessageEvents streams message status changes until the client cancels
+	StreamMessageEvents(ctx context.Context, in *StreamMessageEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MessageEvent], error)
+}
+
+type senderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSenderClient(cc grpc.ClientConnInterface) SenderClient {
+	return &senderClient{cc}
+}
+
+func (c *senderClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, Sender_Health_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) StartStopMessageSending(ctx context.Context, in *StartStopMessageSendingRequest, opts ...grpc.CallOption) (*StartStopMessageSendingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartStopMessageSendingResponse)
+	err := c.cc.Invoke(ctx, Sender_StartStopMessageSending_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) GetWorkerStatus(ctx context.Context, in *GetWorkerStatusRequest, opts ...grpc.CallOption) (*GetWorkerStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetWorkerStatusResponse)
+	err := c.cc.Invoke(ctx, Sender_GetWorkerStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) RunWorkerBatch(ctx context.Context, in *RunWorkerBatchRequest, opts ...grpc.CallOption) (*RunWorkerBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunWorkerBatchResponse)
+	err := c.cc.Invoke(ctx, Sender_RunWorkerBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) CreateMessage(ctx context.Context, in *CreateMessageRequest, opts ...grpc.CallOption) (*CreateMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateMessageResponse)
+	err := c.cc.Invoke(ctx, Sender_CreateMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) RetrieveSentMessages(ctx context.Context, in *RetrieveSentMessagesRequest, opts ...grpc.CallOption) (*RetrieveSentMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RetrieveSentMessagesResponse)
+	err := c.cc.Invoke(ctx, Sender_RetrieveSentMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*GetMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetMessageResponse)
+	err := c.cc.Invoke(ctx, Sender_GetMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) UpdateMessage(ctx context.Context, in *UpdateMessageRequest, opts ...grpc.CallOption) (*UpdateMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateMessageResponse)
+	err := c.cc.Invoke(ctx, Sender_UpdateMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) CancelMessage(ctx context.Context, in *CancelMessageRequest, opts ...grpc.CallOption) (*CancelMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelMessageResponse)
+	err := c.cc.Invoke(ctx, Sender_CancelMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) CancelMessages(ctx context.Context, in *CancelMessagesRequest, opts ...grpc.CallOption) (*CancelMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelMessagesResponse)
+	err := c.cc.Invoke(ctx, Sender_CancelMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) RequeueMessages(ctx context.Context, in *RequeueMessagesRequest, opts ...grpc.CallOption) (*RequeueMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RequeueMessagesResponse)
+	err := c.cc.Invoke(ctx, Sender_RequeueMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, Sender_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) ExportMessages(ctx context.Context, in *ExportMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MessageSummary], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Sender_ServiceDesc.Streams[0], Sender_ExportMessages_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportMessagesRequest, MessageSummary]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Sender_ExportMessagesClient = grpc.ServerStreamingClient[MessageSummary]
+
+func (c *senderClient) StreamMessageEvents(ctx context.Context, in *StreamMessageEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MessageEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Sender_ServiceDesc.Streams[1], Sender_StreamMessageEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamMessageEventsRequest, MessageEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Sender_StreamMessageEventsClient = grpc.ServerStreamingClient[MessageEvent]
+
+// SenderServer is the server API for Sender service.
+// All implementations must embed UnimplementedSenderServer
+// for forward compatibility.
+//
+// Sender exposes the operations of the HTTP API over gRPC. Errors are returned as gRPC statuses
+// carrying a google.rpc.ErrorInfo detail whose reason is the api error name.
+type SenderServer interface {
+	// Health checks health
+	Health(context.Context, *HealthRequest) (*HealthResponse, error)
+	// StartStopMessageSending starts or stops message sending
+	StartStopMessageSending(context.Context, *StartStopMessageSendingRequest) (*StartStopMessageSendingResponse, error)
+	// GetWorkerStatus returns state of the worker and its recent batches
+	GetWorkerStatus(context.Context, *GetWorkerStatusRequest) (*GetWorkerStatusResponse, error)
+	// RunWorkerBatch starts a worker batch right away or previews the next batch on dry run
+	RunWorkerBatch(context.Context, *RunWorkerBatchRequest) (*RunWorkerBatchResponse, error)
+	// CreateMessage queues a new message
+	CreateMessage(context.Context, *CreateMessageRequest) (*CreateMessageResponse, error)
+	// RetrieveSentMessages retrieves sent messages page by page
+	RetrieveSentMessages(context.Context, *RetrieveSentMessagesRequest) (*RetrieveSentMessagesResponse, error)
+	// GetMessage returns message with its status, timestamps, attempts and provider info
+	GetMessage(context.Context, *GetMessageRequest) (*GetMessageResponse, error)
+	// UpdateMessage changes a message which is not sent yet
+	UpdateMessage(context.Context, *UpdateMessageRequest) (*UpdateMessageResponse, error)
+	// CancelMessage cancels a pending or failed message by id
+	CancelMessage(context.Context, *CancelMessageRequest) (*CancelMessageResponse, error)
+	// CancelMessages cancels pending and failed messages matching filter, messages claimed by a worker are skipped
+	CancelMessages(context.Context, *CancelMessagesRequest) (*CancelMessagesResponse, error)
+	// RequeueMessages moves failed and invalid messages matching filter back to pending
+	RequeueMessages(context.Context, *RequeueMessagesRequest) (*RequeueMessagesResponse, error)
+	// GetStats returns queue and delivery statistics
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
+	// ExportMessages streams every message matching filters ordered by created_at
+	ExportMessages(*ExportMessagesRequest, grpc.ServerStreamingServer[MessageSummary]) error
+	// StreamMessageEvents streams message status changes until the client cancels
+	StreamMessageEvents(*StreamMessageEventsRequest, grpc.ServerStreamingServer[MessageEvent]) error
+	mustEmbedUnimplementedSenderServer()
+}
+
+// UnimplementedSenderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSenderServer struct{}
+
+func (UnimplementedSenderServer) Health(context.Context, *HealthRequest) (*HealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
+}
+func (UnimplementedSenderServer) StartStopMessageSending(context.Context, *StartStopMessageSendingRequest) (*StartStopMessageSendingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartStopMessageSending not implemented")
+}
+func (UnimplementedSenderServer) GetWorkerStatus(context.Context, *GetWorkerStatusRequest) (*GetWorkerStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorkerStatus not implemented")
+}
+func (UnimplementedSenderServer) RunWorkerBatch(context.Context, *RunWorkerBatchRequest) (*RunWorkerBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunWorkerBatch not implemented")
+}
+func (UnimplementedSenderServer) CreateMessage(context.Context, *CreateMessageRequest) (*CreateMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateMessage not implemented")
+}
+func (UnimplementedSenderServer) RetrieveSentMessages(context.Context, *RetrieveSentMessagesRequest) (*RetrieveSentMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetrieveSentMessages not implemented")
+}
+func (UnimplementedSenderServer) GetMessage(context.Context, *GetMessageRequest) (*GetMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMessage not implemented")
+}
+func (UnimplementedSenderServer) UpdateMessage(context.Context, *UpdateMessageRequest) (*UpdateMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateMessage not implemented")
+}
+func (UnimplementedSenderServer) CancelMessage(context.Context, *CancelMessageRequest) (*CancelMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelMessage not implemented")
+}
+func (UnimplementedSenderServer) CancelMessages(context.Context, *CancelMessagesRequest) (*CancelMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelMessages not implemented")
+}
+func (UnimplementedSenderServer) RequeueMessages(context.Context, *RequeueMessagesRequest) (*RequeueMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequeueMessages not implemented")
+}
+func (UnimplementedSenderServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedSenderServer) ExportMessages(*ExportMessagesRequest, grpc.ServerStreamingServer[MessageSummary]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportMessages not implemented")
+}
+func (UnimplementedSenderServer) StreamMessageEvents(*StreamMessageEventsRequest, grpc.ServerStreamingServer[MessageEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMessageEvents not implemented")
+}
+func (UnimplementedSenderServer) mustEmbedUnimplementedSenderServer() {}
+func (UnimplementedSenderServer) testEmbeddedByValue()                {}
+
+// UnsafeSenderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SenderServer will
+// result in compilation errors.
+type UnsafeSenderServer interface {
+	mustEmbedUnimplementedSenderServer()
+}
+
+func RegisterSenderServer(s grpc.ServiceRegistrar, srv SenderServer) {
+	// If the following call pancis, it indicates UnimplementedSenderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Sender_ServiceDesc, srv)
+}
+
+func _Sender_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).Health(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_Health_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).Health(ctx, req.(*HealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_StartStopMessageSending_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartStopMessageSendingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).StartStopMessageSending(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_StartStopMessageSending_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).StartStopMessageSending(ctx, req.(*StartStopMessageSendingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_GetWorkerStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWorkerStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).GetWorkerStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_GetWorkerStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).GetWorkerStatus(ctx, req.(*GetWorkerStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_RunWorkerBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunWorkerBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).RunWorkerBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_RunWorkerBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).RunWorkerBatch(ctx, req.(*RunWorkerBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_CreateMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).CreateMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_CreateMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).CreateMessage(ctx, req.(*CreateMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_RetrieveSentMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetrieveSentMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).RetrieveSentMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_RetrieveSentMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).RetrieveSentMessages(ctx, req.(*RetrieveSentMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_GetMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).GetMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_GetMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).GetMessage(ctx, req.(*GetMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_UpdateMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).UpdateMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_UpdateMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).UpdateMessage(ctx, req.(*UpdateMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_CancelMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).CancelMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_CancelMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).CancelMessage(ctx, req.(*CancelMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_CancelMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).CancelMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_CancelMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).CancelMessages(ctx, req.(*CancelMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_RequeueMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequeueMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).RequeueMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_RequeueMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).RequeueMessages(ctx, req.(*RequeueMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_ExportMessages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportMessagesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SenderServer).ExportMessages(m, &grpc.GenericServerStream[ExportMessagesRequest, MessageSummary]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Sender_ExportMessagesServer = grpc.ServerStreamingServer[MessageSummary]
+
+func _Sender_StreamMessageEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamMessageEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SenderServer).StreamMessageEvents(m, &grpc.GenericServerStream[StreamMessageEventsRequest, MessageEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Sender_StreamMessageEventsServer = grpc.ServerStreamingServer[MessageEvent]
+
+// Sender_ServiceDesc is the grpc.ServiceDesc for Sender service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sender_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sender.v1.Sender",
+	HandlerType: (*SenderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Health",
+			Handler:    _Sender_Health_Handler,
+		},
+		{
+			MethodName: "StartStopMessageSending",
+			Handler:    _Sender_StartStopMessageSending_Handler,
+		},
+		{
+			MethodName: "GetWorkerStatus",
+			Handler:    _Sender_GetWorkerStatus_Handler,
+		},
+		{
+			MethodName: "RunWorkerBatch",
+			Handler:    _Sender_RunWorkerBatch_Handler,
+		},
+		{
+			MethodName: "CreateMessage",
+			Handler:    _Sender_CreateMessage_Handler,
+		},
+		{
+			MethodName: "RetrieveSentMessages",
+			Handler:    _Sender_RetrieveSentMessages_Handler,
+		},
+		{
+			MethodName: "GetMessage",
+			Handler:    _Sender_GetMessage_Handler,
+		},
+		{
+			MethodName: "UpdateMessage",
+			Handler:    _Sender_UpdateMessage_Handler,
+		},
+		{
+			MethodName: "CancelMessage",
+			Handler:    _Sender_CancelMessage_Handler,
+		},
+		{
+			MethodName: "CancelMessages",
+			Handler:    _Sender_CancelMessages_Handler,
+		},
+		{
+			MethodName: "RequeueMessages",
+			Handler:    _Sender_RequeueMessages_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Sender_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportMessages",
+			Handler:       _Sender_ExportMessages_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamMessageEvents",
+			Handler:       _Sender_StreamMessageEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sender.proto",
+}
@@ -0,0 +1,126 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+)
+
+// export content types
+const (
+	csvContentType    = "text/csv; charset=utf-8"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// exportFlushInterval is the number of rows written between flushes to the client
+const exportFlushInterval = 100
+
+var csvHeader = []string{"id", "recipient", "content", "status", "tags", "created_at", "sent_at"}
+
+// exportEncoder streams messages of export response row by row instead of encoding a single JSON object
+func exportEncoder(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	r, ok := response.(sender.ExportMessagesResponse)
+	if !ok {
+		return errors.New(invalidResponseError)
+	}
+
+	if r.APIError() != nil {
+		errorEncoder(ctx, r.APIError(), rw)
+		return nil
+	}
+
+	contentType, write := ndjsonContentType, writeNDJSON
+	if r.Format == sender.ExportFormatCSV {
+		contentType, write = csvContentType, writeCSV
+	}
+
+	// export takes as long as its messages take to be read, server write timeout must not cut it
+	_ = http.NewResponseController(rw).SetWriteDeadline(time.Time{})
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="messages.%s"`, r.Format))
+	rw.WriteHeader(http.StatusOK)
+
+	if err := write(rw, r.Messages); err != nil {
+		// status is already sent, aborting the connection lets the client know the export is incomplete
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+func writeNDJSON(w io.Writer, messages func(fn func(sender.ResponseMessage) error) error) error {
+	enc := json.NewEncoder(w)
+
+	rows := 0
+	return messages(func(m sender.ResponseMessage) error {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushInterval == 0 {
+			flush(w)
+		}
+		return nil
+	})
+}
+
+func writeCSV(w io.Writer, messages func(fn func(sender.ResponseMessage) error) error) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	rows := 0
+	err := messages(func(m sender.ResponseMessage) error {
+		if err := cw.Write(csvRecord(m)); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushInterval == 0 {
+			cw.Flush()
+			flush(w)
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvRecord(m sender.ResponseMessage) []string {
+	sentAt := ""
+	if m.SentAt != nil {
+		sentAt = m.SentAt.Format(time.RFC3339)
+	}
+
+	return []string{
+		m.ID,
+		m.Recipient,
+		m.Content,
+		m.Status,
+		strings.Join(m.Tags, ";"),
+		m.CreatedAt.Format(time.RFC3339),
+		sentAt,
+	}
+}
+
+// flush sends buffered rows to the client so that they are not held in memory
+func flush(w io.Writer) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,48 @@
+package httptransport
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportEncoder(t *testing.T) {
+	t.Run("streams past server write timeout", func(t *testing.T) {
+		rows := 5
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_ = exportEncoder(r.Context(), rw, sender.ExportMessagesResponse{
+				Format: sender.ExportFormatNDJSON,
+				Messages: func(fn func(sender.ResponseMessage) error) error {
+					for i := 0; i < rows; i++ {
+						time.Sleep(40 * time.Millisecond)
+						if err := fn(sender.ResponseMessage{ID: "id", Status: "sent"}); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			})
+		}))
+		srv.Config.WriteTimeout = 50 * time.Millisecond
+		srv.Start()
+		defer srv.Close()
+
+		res, err := http.Get(srv.URL)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+
+		lines := 0
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			lines++
+		}
+		assert.NoError(t, sc.Err())
+		assert.Equal(t, rows, lines)
+		assert.Equal(t, ndjsonContentType, res.Header.Get("Content-Type"))
+	})
+}
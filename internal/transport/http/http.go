@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/mkaykisiz/sender"
 
@@ -14,52 +17,169 @@ import (
 	"github.com/go-kit/kit/log"
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/go-openapi/runtime/middleware"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/iris-contrib/schema"
 	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/auth"
+	"github.com/mkaykisiz/sender/internal/clientip"
 	"github.com/mkaykisiz/sender/internal/endpoints"
 	"github.com/mkaykisiz/sender/internal/localization"
+	"github.com/mkaykisiz/sender/internal/requestid"
+	"github.com/mkaykisiz/sender/internal/signing"
 	"github.com/mkaykisiz/sender/internal/transport"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // endpoint names
 const (
 	health                  = "Health"
+	liveness                = "Liveness"
+	readiness               = "Readiness"
 	startStopMessageSending = "StartStopMessageSending"
+	getWorkerStatus         = "GetWorkerStatus"
+	runWorkerBatch          = "RunWorkerBatch"
+	createMessage           = "CreateMessage"
 	retrieveSentMessages    = "RetrieveSentMessages"
+	cancelMessage           = "CancelMessage"
+	cancelMessages          = "CancelMessages"
+	requeueMessages         = "RequeueMessages"
+	updateMessage           = "UpdateMessage"
+	getMessage              = "GetMessage"
+	getStats                = "GetStats"
+	exportMessages          = "ExportMessages"
+	streamMessageEvents     = "StreamMessageEvents"
+	createAPIKey            = "CreateAPIKey"
+	listAPIKeys             = "ListAPIKeys"
+	revokeAPIKey            = "RevokeAPIKey"
+	deliveryReceipt         = "DeliveryReceipt"
+	getSandboxMessages      = "GetSandboxMessages"
 )
 
 // decoder tags
 const (
 	headerTag = "header"
 	queryTag  = "query"
+	pathTag   = "path"
 )
 
 const invalidResponseError = "invalid response"
 const multipartFormSizeLimit = 10 * 1024 * 1024
 
-// MakeHTTPHandler makes and returns http handler
-func MakeHTTPHandler(l log.Logger, s sender.Service) http.Handler {
-	es := endpoints.MakeEndpoints(s)
+// MakeHTTPHandler makes and returns http handler, am authenticates and rl rate limits every endpoint but
+// health checks and provider callbacks whose signatures are verified by v. Client ip addresses are resolved by ipr,
+// request ids are accepted or generated and echoed in responses.
+func MakeHTTPHandler(l log.Logger, s sender.Service, am auth.Middleware, rl endpoint.Middleware, v *signing.Verifier, ipr *clientip.Resolver) http.Handler {
+	es := endpoints.MakeEndpoints(s, am, rl)
 
 	r := mux.NewRouter()
+	r.Use(traceRoutes)
 
 	// health GET /health
 	r.Methods("GET").Path("/health").Handler(
 		makeHealthHandler(es.HealthEndpoint, makeDefaultServerOptions(l, health)),
 	)
 
+	// liveness GET /health/live
+	r.Methods("GET").Path("/health/live").Handler(
+		makeLivenessHandler(es.LivenessEndpoint, makeDefaultServerOptions(l, liveness)),
+	)
+
+	// readiness GET /health/ready
+	r.Methods("GET").Path("/health/ready").Handler(
+		makeReadinessHandler(es.ReadinessEndpoint, makeDefaultServerOptions(l, readiness)),
+	)
+
 	// start-stop-message-sending POST /start-stop-sending
 	r.Methods("POST").Path("/start-stop-sending").Handler(
 		makeStartStopMessageSendingHandler(es.StartStopMessageSendingEndpoint, makeDefaultServerOptions(l, startStopMessageSending)),
 	)
 
+	// get-worker-status GET /worker/status
+	r.Methods("GET").Path("/worker/status").Handler(
+		makeGetWorkerStatusHandler(es.GetWorkerStatusEndpoint, makeDefaultServerOptions(l, getWorkerStatus)),
+	)
+
+	// run-worker-batch POST /worker/run
+	r.Methods("POST").Path("/worker/run").Handler(
+		makeRunWorkerBatchHandler(es.RunWorkerBatchEndpoint, makeDefaultServerOptions(l, runWorkerBatch)),
+	)
+
+	// create-message POST /create-message
+	r.Methods("POST").Path("/create-message").Handler(
+		makeCreateMessageHandler(es.CreateMessageEndpoint, makeDefaultServerOptions(l, createMessage)),
+	)
+
 	// retrieve-sent-messages GET /retrieve-sent-messages
 	r.Methods("GET").Path("/retrieve-sent-messages").Handler(
 		makeRetrieveSentMessagesHandler(es.RetrieveSentMessagesEndpoint, makeDefaultServerOptions(l, retrieveSentMessages)),
 	)
 
+	// cancel-message POST /cancel-message
+	r.Methods("POST").Path("/cancel-message").Handler(
+		makeCancelMessageHandler(es.CancelMessageEndpoint, makeDefaultServerOptions(l, cancelMessage)),
+	)
+
+	// cancel-messages POST /cancel-messages
+	r.Methods("POST").Path("/cancel-messages").Handler(
+		makeCancelMessagesHandler(es.CancelMessagesEndpoint, makeDefaultServerOptions(l, cancelMessages)),
+	)
+
+	// requeue-messages POST /requeue-messages
+	r.Methods("POST").Path("/requeue-messages").Handler(
+		makeRequeueMessagesHandler(es.RequeueMessagesEndpoint, makeDefaultServerOptions(l, requeueMessages)),
+	)
+
+	// update-message POST /update-message
+	r.Methods("POST").Path("/update-message").Handler(
+		makeUpdateMessageHandler(es.UpdateMessageEndpoint, makeDefaultServerOptions(l, updateMessage)),
+	)
+
+	// get-message GET /messages/{id}
+	r.Methods("GET").Path("/messages/{id}").Handler(
+		makeGetMessageHandler(es.GetMessageEndpoint, makeDefaultServerOptions(l, getMessage)),
+	)
+
+	// get-stats GET /stats
+	r.Methods("GET").Path("/stats").Handler(
+		makeGetStatsHandler(es.GetStatsEndpoint, makeDefaultServerOptions(l, getStats)),
+	)
+
+	// export-messages GET /export-messages
+	r.Methods("GET").Path("/export-messages").Handler(
+		makeExportMessagesHandler(es.ExportMessagesEndpoint, makeDefaultServerOptions(l, exportMessages)),
+	)
+
+	// stream-message-events GET /message-events
+	r.Methods("GET").Path("/message-events").Handler(
+		makeStreamMessageEventsHandler(es.StreamMessageEventsEndpoint, makeDefaultServerOptions(l, streamMessageEvents)),
+	)
+
+	// create-api-key POST /create-api-key
+	r.Methods("POST").Path("/create-api-key").Handler(
+		makeCreateAPIKeyHandler(es.CreateAPIKeyEndpoint, makeDefaultServerOptions(l, createAPIKey)),
+	)
+
+	// list-api-keys GET /api-keys
+	r.Methods("GET").Path("/api-keys").Handler(
+		makeListAPIKeysHandler(es.ListAPIKeysEndpoint, makeDefaultServerOptions(l, listAPIKeys)),
+	)
+
+	// revoke-api-key POST /revoke-api-key
+	r.Methods("POST").Path("/revoke-api-key").Handler(
+		makeRevokeAPIKeyHandler(es.RevokeAPIKeyEndpoint, makeDefaultServerOptions(l, revokeAPIKey)),
+	)
+
+	// delivery-receipt POST /delivery-receipts
+	r.Methods("POST").Path("/delivery-receipts").Handler(
+		verifySignature(l, v, deliveryReceipt, makeDeliveryReceiptHandler(es.DeliveryReceiptEndpoint, makeDefaultServerOptions(l, deliveryReceipt))),
+	)
+
+	// get-sandbox-messages GET /sandbox/messages
+	r.Methods("GET").Path("/sandbox/messages").Handler(
+		makeGetSandboxMessagesHandler(es.GetSandboxMessagesEndpoint, makeDefaultServerOptions(l, getSandboxMessages)),
+	)
+
 	// core services docs
 	swaggerRouter := r.PathPrefix("/docs").Subrouter()
 
@@ -82,7 +202,7 @@ func MakeHTTPHandler(l log.Logger, s sender.Service) http.Handler {
 			middleware.SwaggerUI(opts, nil).ServeHTTP(w, r)
 		})
 
-	return r
+	return requestid.Handler(ipr.Handler(r))
 }
 
 func makeHealthHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
@@ -90,21 +210,141 @@ func makeHealthHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption
 	return h
 }
 
+func makeLivenessHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.LivenessRequest{}), probeEncoder, serverOptions...)
+	return h
+}
+
+func makeReadinessHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.ReadinessRequest{}), probeEncoder, serverOptions...)
+	return h
+}
+
 func makeStartStopMessageSendingHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
 	h := kithttp.NewServer(e, makeDecoder(sender.StartStopMessageSendingRequest{}), encoder, serverOptions...)
 	return h
 }
 
+func makeGetWorkerStatusHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.GetWorkerStatusRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeRunWorkerBatchHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.RunWorkerBatchRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeCreateMessageHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.CreateMessageRequest{}), encoder, serverOptions...)
+	return h
+}
+
 func makeRetrieveSentMessagesHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
 	h := kithttp.NewServer(e, makeDecoder(sender.RetrieveSentMessagesRequest{}), encoder, serverOptions...)
 	return h
 }
 
+func makeCancelMessageHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.CancelMessageRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeCancelMessagesHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.CancelMessagesRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeRequeueMessagesHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.RequeueMessagesRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeUpdateMessageHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.UpdateMessageRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeGetMessageHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.GetMessageRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeGetStatsHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.GetStatsRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeExportMessagesHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.ExportMessagesRequest{}), exportEncoder, serverOptions...)
+	return h
+}
+
+func makeStreamMessageEventsHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.StreamMessageEventsRequest{}), sseEncoder, serverOptions...)
+	return h
+}
+
+func makeCreateAPIKeyHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.CreateAPIKeyRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeListAPIKeysHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.ListAPIKeysRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeRevokeAPIKeyHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.RevokeAPIKeyRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeDeliveryReceiptHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.DeliveryReceiptRequest{}), encoder, serverOptions...)
+	return h
+}
+
+func makeGetSandboxMessagesHandler(e endpoint.Endpoint, serverOptions []kithttp.ServerOption) http.Handler {
+	h := kithttp.NewServer(e, makeDecoder(sender.GetSandboxMessagesRequest{}), encoder, serverOptions...)
+	return h
+}
+
+// MakeMetricsHandler returns handler serving prometheus metrics. It is served on a listener of its own
+// so that metrics, which are not authenticated, are not exposed together with the api.
+func MakeMetricsHandler() http.Handler {
+	r := mux.NewRouter()
+
+	// metrics GET /metrics
+	r.Methods("GET").Path("/metrics").Handler(promhttp.Handler())
+
+	return r
+}
+
+// traceRoutes starts server spans of requests continuing trace context given by clients, spans are named by
+// route templates so that ids in paths do not end up in span names. Health checks are not traced.
+func traceRoutes(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil {
+					return r.Method + " " + tpl
+				}
+			}
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !strings.HasPrefix(r.URL.Path, "/health")
+		}),
+	)
+}
+
 func makeDefaultServerOptions(l log.Logger, endpointName string) []kithttp.ServerOption {
 	options := []kithttp.ServerOption{
 		kithttp.ServerErrorEncoder(errorEncoder),
 		kithttp.ServerErrorHandler(transport.NewErrorHandler(l, endpointName)),
 		kithttp.ServerBefore(localization.AddLocalizerToContext),
+		kithttp.ServerBefore(auth.HTTPToContext),
 	}
 	return options
 }
@@ -123,6 +363,10 @@ func makeDecoder(emptyReq interface{}) kithttp.DecodeRequestFunc {
 			return nil, fmt.Errorf("decoding request query failed, %s", err.Error())
 		}
 
+		if err := newPathDecoder().Decode(req, getPathValues(r)); err != nil {
+			return nil, fmt.Errorf("decoding request path failed, %s", err.Error())
+		}
+
 		if requestHasBody(r) {
 			formValueTags := getFormValueTags(req)
 			formFileTags := getFormFileTags(req)
@@ -159,7 +403,7 @@ func makeDecoder(emptyReq interface{}) kithttp.DecodeRequestFunc {
 			}
 		}
 
-		if err := validate(req); err != nil {
+		if err := transport.Validate(req); err != nil {
 			apiError := apierror.NewValidationError(err.Error(), "")
 			apiError.BaseError = err
 			return nil, apiError
@@ -169,12 +413,9 @@ func makeDecoder(emptyReq interface{}) kithttp.DecodeRequestFunc {
 	}
 }
 
+// getIPAddress returns client ip address resolved by the resolver of handler
 func getIPAddress(r *http.Request) string {
-	if ipAddress := r.Header.Get("X-Real-Ip"); ipAddress != "" {
-		return ipAddress
-	}
-
-	if ipAddress := r.Header.Get("X-Forwarded-For"); ipAddress != "" {
+	if ipAddress := clientip.FromContext(r.Context()); ipAddress != "" {
 		return ipAddress
 	}
 
@@ -189,6 +430,22 @@ func newQueryDecoder() *schema.Decoder {
 	return newDecoder(queryTag)
 }
 
+func newPathDecoder() *schema.Decoder {
+	return newDecoder(pathTag)
+}
+
+// getPathValues returns route variables in the form of url values
+func getPathValues(r *http.Request) map[string][]string {
+	vars := mux.Vars(r)
+
+	values := make(map[string][]string, len(vars))
+	for k, v := range vars {
+		values[k] = []string{v}
+	}
+
+	return values
+}
+
 func newDecoder(tag string) *schema.Decoder {
 	decoder := schema.NewDecoder()
 	decoder.IgnoreUnknownKeys(true)
@@ -253,17 +510,6 @@ func setValue(tagName string, tag string, value interface{}, req interface{}) {
 	}
 }
 
-func validate(req interface{}) error {
-	errs := validator.New().Struct(req)
-	if errs == nil {
-		return nil
-	}
-
-	firstErr := errs.(validator.ValidationErrors)[0]
-
-	return fmt.Errorf("validation failed, tag: %s, field: %s", firstErr.Tag(), firstErr.Field())
-}
-
 func encoder(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
 
 	r, ok := response.(sender.Response)
@@ -286,6 +532,27 @@ func encoder(ctx context.Context, rw http.ResponseWriter, response interface{})
 	return json.NewEncoder(rw).Encode(lr)
 }
 
+// probeEncoder encodes health probe responses, failed probes are sent with status of their error and
+// the same body as succeeded ones so that the failing component is visible to whoever reads the probe
+func probeEncoder(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	r, ok := response.(sender.Response)
+	if !ok {
+		return errors.New(invalidResponseError)
+	}
+
+	statusCode := http.StatusOK
+	l := localization.GetLocalizerFromContext(ctx)
+	if apiErr, ok := r.APIError().(*apierror.APIError); ok {
+		apiErr.Localize(l)
+		statusCode = apiErr.StatusCode
+	}
+
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(statusCode)
+
+	return json.NewEncoder(rw).Encode(r.Localize(l))
+}
+
 type errorResponse struct {
 	Data   interface{}        `json:"data"`
 	Result *apierror.APIError `json:"result"`
@@ -307,6 +574,9 @@ func errorEncoder(ctx context.Context, err error, rw http.ResponseWriter) {
 	}
 
 	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if apiErr.RetryAfter > 0 {
+		rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
 	rw.WriteHeader(apiErr.StatusCode)
 	_ = json.NewEncoder(rw).Encode(er)
 }
@@ -0,0 +1,55 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeDecoder_PathValues(t *testing.T) {
+	var decoded interface{}
+	var decodeErr error
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/messages/{id}").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		decoded, decodeErr = makeDecoder(sender.GetMessageRequest{})(r.Context(), r)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	t.Run("fills request from path", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/messages/65a1b2c3d4e5f6a7b8c9d0e1")
+		assert.NoError(t, err)
+		res.Body.Close()
+
+		assert.NoError(t, decodeErr)
+		assert.Equal(t, "65a1b2c3d4e5f6a7b8c9d0e1", decoded.(*sender.GetMessageRequest).ID)
+	})
+
+	t.Run("rejects invalid id", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/messages/not-an-id")
+		assert.NoError(t, err)
+		res.Body.Close()
+
+		var apiErr *apierror.APIError
+		assert.True(t, errors.As(decodeErr, &apiErr))
+		assert.Equal(t, apierror.CodeValidationError, apiErr.Code)
+	})
+}
+
+func TestMakeMetricsHandler(t *testing.T) {
+	srv := httptest.NewServer(MakeMetricsHandler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/metrics")
+	assert.NoError(t, err)
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
@@ -0,0 +1,61 @@
+package httptransport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/mkaykisiz/sender/internal/apierror"
+	"github.com/mkaykisiz/sender/internal/localization"
+	"github.com/mkaykisiz/sender/internal/signing"
+	"github.com/mkaykisiz/sender/internal/transport"
+)
+
+// signedBodySizeLimit is the size limit of bodies of signed requests, they are read whole to be verified
+const signedBodySizeLimit = 1024 * 1024
+
+// verifySignature returns handler passing requests to next only if their signature is verified by v
+func verifySignature(l log.Logger, v *signing.Verifier, endpointName string, next http.Handler) http.Handler {
+	eh := transport.NewErrorHandler(l, endpointName)
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := localization.AddLocalizerToContext(r.Context(), r)
+
+		body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, signedBodySizeLimit))
+		if err != nil {
+			err = fmt.Errorf("reading signed request body failed, %s", err.Error())
+			eh.Handle(ctx, err)
+			errorEncoder(ctx, apierror.NewBadRequestError(err.Error(), "signed-request-body-bad-request-error-message"), rw)
+			return
+		}
+
+		if err := v.Verify(ctx, r, body); err != nil {
+			eh.Handle(ctx, err)
+			errorEncoder(ctx, newSignatureError(err), rw)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// newSignatureError returns unauthorized error for verification failures, other errors are internal
+func newSignatureError(err error) *apierror.APIError {
+	switch {
+	case errors.Is(err, signing.ErrMissingSignature),
+		errors.Is(err, signing.ErrMalformedSignature),
+		errors.Is(err, signing.ErrUnknownKey),
+		errors.Is(err, signing.ErrExpiredSignature),
+		errors.Is(err, signing.ErrInvalidSignature),
+		errors.Is(err, signing.ErrReplayedRequest):
+		apiErr := apierror.NewUnauthorizedError(err)
+		apiErr.MessageLocalizerKey = "invalid-signature-unauthorized-error-message"
+		return apiErr
+	default:
+		return apierror.NewInternalServerError(err)
+	}
+}
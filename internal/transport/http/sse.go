@@ -0,0 +1,106 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/mkaykisiz/sender/internal/redact"
+)
+
+const eventStreamContentType = "text/event-stream"
+
+// sseHeartbeatInterval is the interval of comments sent to keep idle connections and proxies open
+const sseHeartbeatInterval = 15 * time.Second
+
+// shutdownContextKey is the context key of the context cancelled on server shutdown
+type shutdownContextKey struct{}
+
+// CloseStreamsOnShutdown makes open event streams of hs end when hs is shut down, otherwise shutdown waits
+// for their clients to disconnect. Other requests are not cancelled. It must be called before hs serves.
+func CloseStreamsOnShutdown(hs *http.Server) {
+	shutdown, cancel := context.WithCancel(context.Background())
+
+	base := hs.BaseContext
+	hs.BaseContext = func(l net.Listener) context.Context {
+		ctx := context.Background()
+		if base != nil {
+			ctx = base(l)
+		}
+		return context.WithValue(ctx, shutdownContextKey{}, shutdown)
+	}
+	hs.RegisterOnShutdown(cancel)
+}
+
+// shutdownDone returns channel closed on server shutdown, it is nil when ctx is not of a server set up
+// with CloseStreamsOnShutdown
+func shutdownDone(ctx context.Context) <-chan struct{} {
+	if shutdown, ok := ctx.Value(shutdownContextKey{}).(context.Context); ok {
+		return shutdown.Done()
+	}
+
+	return nil
+}
+
+// sseEncoder writes events of the response as server-sent events until the request context is done or
+// the server is shut down
+func sseEncoder(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	r, ok := response.(sender.StreamMessageEventsResponse)
+	if !ok {
+		return errors.New(invalidResponseError)
+	}
+
+	if r.APIError() != nil {
+		errorEncoder(ctx, r.APIError(), rw)
+		return nil
+	}
+
+	// stream lives as long as the client is connected, server write timeout must not cut it
+	_ = http.NewResponseController(rw).SetWriteDeadline(time.Time{})
+
+	rw.Header().Set("Content-Type", eventStreamContentType)
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	flush(rw)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	shutdown := shutdownDone(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-shutdown:
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(rw, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		case e, ok := <-r.Events:
+			if !ok {
+				return nil
+			}
+			if r.MaskPII {
+				e = redact.MessageEvent(e)
+			}
+
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+
+			if _, err := fmt.Fprintf(rw, "id: %s-%d\nevent: %s\ndata: %s\n\n", e.ID, e.Version, e.Status, data); err != nil {
+				return nil
+			}
+		}
+		flush(rw)
+	}
+}
@@ -0,0 +1,50 @@
+package httptransport
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkaykisiz/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseStreamsOnShutdown(t *testing.T) {
+	events := make(chan sender.MessageEvent)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_ = sseEncoder(r.Context(), rw, sender.StreamMessageEventsResponse{Events: events})
+	}))
+	CloseStreamsOnShutdown(srv.Config)
+	srv.Start()
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, eventStreamContentType, res.Header.Get("Content-Type"))
+
+	ctx, cf := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cf()
+	assert.NoError(t, srv.Config.Shutdown(ctx))
+
+	// stream is ended by server rather than by client
+	_, err = bufio.NewReader(res.Body).ReadString('\n')
+	assert.Error(t, err)
+}
+
+func TestSSEEncoder_MaskPII(t *testing.T) {
+	events := make(chan sender.MessageEvent, 1)
+	events <- sender.MessageEvent{ID: "1", Status: "sent", Recipient: "+905551234567", Version: 2}
+	close(events)
+
+	rec := httptest.NewRecorder()
+	err := sseEncoder(context.Background(), rec, sender.StreamMessageEventsResponse{Events: events, MaskPII: true})
+
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(rec.Body.String(), `"recipient":"+********4567"`))
+	assert.False(t, strings.Contains(rec.Body.String(), "+905551234567"))
+}
@@ -0,0 +1,19 @@
+package transport
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validate validates request by its validate tags and returns the first failure
+func Validate(req interface{}) error {
+	errs := validator.New().Struct(req)
+	if errs == nil {
+		return nil
+	}
+
+	firstErr := errs.(validator.ValidationErrors)[0]
+
+	return fmt.Errorf("validation failed, tag: %s, field: %s", firstErr.Tag(), firstErr.Field())
+}
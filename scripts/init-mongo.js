@@ -20,6 +20,16 @@ db.messages.createIndex(
     }
 );
 
+// Index for sending higher priority messages first
+// The worker sorts unsent messages by priority and then by creation time
+db.messages.createIndex(
+    { "status": 1, "priority": -1, "created_at": 1 },
+    {
+        name: "idx_status_priority_created_at",
+        background: true
+    }
+);
+
 // Index for retrieving sent messages
 // This index is used by the retrieve-sent-messages API endpoint
 db.messages.createIndex(
@@ -30,7 +40,33 @@ db.messages.createIndex(
     }
 );
 
-// Optional: Index for recipient lookup (if needed for future features)
+// Indexes for paging retrieved messages by creation or send time
+db.messages.createIndex(
+    { "status": 1, "created_at": -1, "_id": -1 },
+    {
+        name: "idx_status_created_at_id",
+        background: true
+    }
+);
+db.messages.createIndex(
+    { "status": 1, "sent_at": -1, "_id": -1 },
+    {
+        name: "idx_status_sent_at_id",
+        background: true
+    }
+);
+
+// Index for filtering messages by tag
+db.messages.createIndex(
+    { "tags": 1 },
+    {
+        name: "idx_tags",
+        background: true,
+        sparse: true
+    }
+);
+
+// Index for recipient lookup, recipient holds its blind index when encryption is configured
 db.messages.createIndex(
     { "recipient": 1 },
     {
@@ -40,6 +76,35 @@ db.messages.createIndex(
     }
 );
 
+// Index for matching delivery receipts to messages by provider message id
+db.messages.createIndex(
+    { "provider_message_id": 1 },
+    {
+        name: "idx_provider_message_id",
+        background: true,
+        sparse: true
+    }
+);
+
+// Unique index for authenticating requests by api key hash
+db.api_key.createIndex(
+    { "hash": 1 },
+    {
+        name: "idx_hash",
+        unique: true,
+        background: true
+    }
+);
+
+// Index for listing captured sandbox messages by recipient, most recent first
+db.sandbox_message.createIndex(
+    { "recipient": 1, "created_at": -1 },
+    {
+        name: "idx_recipient_created_at",
+        background: true
+    }
+);
+
 // Print created indexes
 print("Created indexes:");
 db.messages.getIndexes().forEach(function (index) {
@@ -26,12 +26,74 @@ const (
 	LanguageCodeEN = "en"
 )
 
+// export formats
 const (
-	MaxMessageLength = 1000
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// actions a worker batch takes on a message
+const (
+	BatchActionSend       = "send"
+	BatchActionInvalidate = "invalidate"
+	BatchActionBlock      = "block" // recipient is not in the allow-list of a non-prod environment
+)
+
+// statuses of messages captured by sandbox provider
+const (
+	SandboxStatusAccepted = "accepted"
+	SandboxStatusFailed   = "failed" // failure is simulated
+)
+
+// health check component statuses
+const (
+	ComponentStatusUp      = "up"
+	ComponentStatusDown    = "down"
+	ComponentStatusStopped = "stopped" // worker is stopped on purpose
+	ComponentStatusStalled = "stalled" // worker has not finished a batch in time
+)
+
+// health check component names
+const (
+	ComponentMongo  = "mongo"
+	ComponentRedis  = "redis"
+	ComponentWorker = "worker"
+)
+
+const (
+	MaxMessageLength   = 1000
+	MaxMessagePriority = 10
+)
+
+// api key scopes
+const (
+	ScopeMessagesCreate = "messages:create"
+	ScopeMessagesRead   = "messages:read"
+	ScopeMessagesWrite  = "messages:write"
+	ScopeWorkerControl  = "worker:control"
+	ScopeKeysAdmin      = "keys:admin"
+	ScopePIIRead        = "pii:read" // recipients and contents are masked in responses without it
+)
+
+// api key roles
+const (
+	RoleIngestion = "ingestion"
+	RoleSupport   = "support"
+	RoleSRE       = "sre"
+	RoleAdmin     = "admin"
 )
 
 var (
 	LanguageCodes = []string{LanguageCodeTR, LanguageCodeEN}
+	Scopes        = []string{ScopeMessagesCreate, ScopeMessagesRead, ScopeMessagesWrite, ScopeWorkerControl, ScopeKeysAdmin, ScopePIIRead}
+
+	// RoleScopes holds scopes granted by each role
+	RoleScopes = map[string][]string{
+		RoleIngestion: {ScopeMessagesCreate},
+		RoleSupport:   {ScopeMessagesRead},
+		RoleSRE:       {ScopeMessagesRead, ScopeWorkerControl},
+		RoleAdmin:     Scopes,
+	}
 )
 
 type (
@@ -39,27 +101,229 @@ type (
 		ID        string     `json:"id"`
 		Content   string     `json:"content"`
 		Recipient string     `json:"recipient"`
-		Status    string     `json:"status"` // "pending", "sent", "failed"
+		Status    string     `json:"status"` // "pending", "processing", "sent", "delivered", "failed", "invalid", "cancelled", "blocked_by_environment"
+		Tags      []string   `json:"tags,omitempty"`
 		SentAt    *time.Time `json:"sent_at,omitempty"`
+		CreatedAt time.Time  `json:"created_at"`
 	}
 
 	MessageTransaction struct {
 		ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 		Content   string             `json:"content" bson:"content" validate:"required,max=1000"`
 		Recipient string             `json:"recipient" bson:"recipient"` // TODO birden fazla adi var
-		Status    string             `json:"status" bson:"status"`       // "pending", "sent", "failed"
+		Status    string             `json:"status" bson:"status"`       // "pending", "processing", "sent", "delivered", "failed", "invalid", "cancelled", "blocked_by_environment"
+		Version   int64              `json:"version" bson:"version"`     // incremented on every change
+		Priority  int                `json:"priority" bson:"priority"`   // higher priority messages are sent first
+		Tags      []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+		Attempts  int                `json:"attempts" bson:"attempts"` // number of times the message was claimed for sending
+		SendAt    *time.Time         `json:"send_at,omitempty" bson:"send_at,omitempty"`
 		SentAt    *time.Time         `json:"sent_at,omitempty" bson:"sent_at,omitempty"`
 		CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+		UpdatedAt *time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+		History   []MessageRevision  `json:"history,omitempty" bson:"history,omitempty"`
+
+		Provider          string `json:"provider,omitempty" bson:"provider,omitempty"`
+		ProviderMessageID string `json:"provider_message_id,omitempty" bson:"provider_message_id,omitempty"`
+		LastError         string `json:"last_error,omitempty" bson:"last_error,omitempty"`
+		// DeliveredAt is reported by provider's delivery receipt
+		DeliveredAt *time.Time `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+		// ClaimedAt is the time the message was last claimed for sending. The claim is held until LeaseUntil,
+		// a processing message whose lease expired is claimed again by the next batch.
+		ClaimedAt  *time.Time `json:"claimed_at,omitempty" bson:"claimed_at,omitempty"`
+		LeaseUntil *time.Time `json:"lease_until,omitempty" bson:"lease_until,omitempty"`
+
+		// ContentEncrypted holds encrypted content in store while Content is empty and RecipientEncrypted
+		// holds encrypted recipient while Recipient holds its blind index, they are empty once the message
+		// is read
+		ContentEncrypted   string `json:"-" bson:"content_encrypted,omitempty"`
+		RecipientEncrypted string `json:"-" bson:"recipient_encrypted,omitempty"`
+
+		// TraceID and SpanID identify the span of the request which created the message, sends are linked to it
+		TraceID string `json:"trace_id,omitempty" bson:"trace_id,omitempty"`
+		SpanID  string `json:"-" bson:"span_id,omitempty"`
+		// RequestID is the id of the request which created the message, it is sent to provider with the message
+		RequestID string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	}
+
+	// MessageRevision holds message values replaced by an edit
+	MessageRevision struct {
+		Content   string     `json:"content" bson:"content"`
+		Recipient string     `json:"recipient" bson:"recipient"`
+		Priority  int        `json:"priority" bson:"priority"`
+		SendAt    *time.Time `json:"send_at,omitempty" bson:"send_at,omitempty"`
+		Version   int64      `json:"version" bson:"version"`
+		ChangedAt time.Time  `json:"changed_at" bson:"changed_at"`
+
+		// ContentEncrypted and RecipientEncrypted hold encrypted values in store while Content and
+		// Recipient are empty, they are empty once the message is read
+		ContentEncrypted   string `json:"-" bson:"content_encrypted,omitempty"`
+		RecipientEncrypted string `json:"-" bson:"recipient_encrypted,omitempty"`
+	}
+)
+
+// MessageStats represents queue and delivery statistics
+type (
+	MessageStats struct {
+		StatusCounts            map[string]int64 `json:"status_counts"`
+		OldestPendingCreatedAt  *time.Time       `json:"oldest_pending_created_at,omitempty"`
+		OldestPendingAgeSeconds float64          `json:"oldest_pending_age_seconds"`
+		WindowMinutes           int              `json:"window_minutes"`
+		SentInWindow            int64            `json:"sent_in_window"`
+		ThroughputPerMinute     float64          `json:"throughput_per_minute"`
+		Providers               []ProviderStats  `json:"providers"`
+		Latency                 LatencyStats     `json:"latency"`
+		GeneratedAt             time.Time        `json:"generated_at"`
+	}
+
+	// MessageEvent represents a message status change
+	MessageEvent struct {
+		ID         string    `json:"id"`
+		Status     string    `json:"status"`
+		Recipient  string    `json:"recipient"`
+		Tags       []string  `json:"tags,omitempty"`
+		Version    int64     `json:"version"`
+		OccurredAt time.Time `json:"occurred_at"`
+	}
+
+	// ProviderStats represents outcome counts of a provider in statistics window
+	ProviderStats struct {
+		Provider    string  `json:"provider" bson:"_id"`
+		Sent        int64   `json:"sent" bson:"sent"`
+		Failed      int64   `json:"failed" bson:"failed"`
+		FailureRate float64 `json:"failure_rate" bson:"-"`
+	}
+
+	// LatencyStats represents created_at to sent_at latency percentiles in milliseconds
+	LatencyStats struct {
+		P50 float64 `json:"p50_ms"`
+		P90 float64 `json:"p90_ms"`
+		P99 float64 `json:"p99_ms"`
 	}
 )
 
+// WorkerStatus represents state of the worker and summaries of its recent batches
+type (
+	WorkerStatus struct {
+		Running         bool           `json:"running"`
+		IntervalSeconds float64        `json:"interval_seconds"`
+		BatchSize       int64          `json:"batch_size"`
+		LastRunAt       *time.Time     `json:"last_run_at,omitempty"`
+		NextRunAt       *time.Time     `json:"next_run_at,omitempty"`
+		Batches         []BatchSummary `json:"batches"`
+	}
+
+	// PlannedSend represents what the next worker batch would do with a message, Provider is the provider
+	// the message would be sent with
+	PlannedSend struct {
+		Message  ResponseMessage `json:"message"`
+		Action   string          `json:"action"`
+		Provider string          `json:"provider,omitempty"`
+	}
+
+	// BatchSummary represents outcome counts of a worker batch, Error is set when messages could not be selected
+	BatchSummary struct {
+		StartedAt  time.Time `json:"started_at"`
+		DurationMS float64   `json:"duration_ms"`
+		Selected   int64     `json:"selected"`
+		Sent       int64     `json:"sent"`
+		Failed     int64     `json:"failed"`
+		Invalid    int64     `json:"invalid"`
+		Blocked    int64     `json:"blocked"`
+		Error      string    `json:"error,omitempty"`
+	}
+)
+
+// ComponentHealth represents health of a dependency or background process checked by probes
+type ComponentHealth struct {
+	Status    string                 `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	LatencyMS float64                `json:"latency_ms,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// APIKey represents an api key, the key itself is only returned on creation and stored hashed
+type APIKey struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	Prefix    string             `json:"prefix" bson:"prefix"` // first characters of the key to tell keys apart
+	Hash      string             `json:"-" bson:"hash"`
+	Scopes    []string           `json:"scopes" bson:"scopes"`
+	CreatedBy string             `json:"created_by,omitempty" bson:"created_by,omitempty"` // name of the key which created this key
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	RevokedAt *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// SandboxMessage represents a message captured by sandbox provider instead of being delivered,
+// ProviderMessageID is a fake id given to accepted messages
+type SandboxMessage struct {
+	ID                primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProviderMessageID string             `json:"provider_message_id,omitempty" bson:"provider_message_id,omitempty"`
+	Recipient         string             `json:"recipient" bson:"recipient"`
+	Content           string             `json:"content" bson:"content"`
+	Status            string             `json:"status" bson:"status"` // "accepted" or "failed"
+	Error             string             `json:"error,omitempty" bson:"error,omitempty"`
+	RequestID         string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	CreatedAt         time.Time          `json:"created_at" bson:"created_at"`
+
+	// ContentEncrypted holds encrypted content in store while Content is empty and RecipientEncrypted
+	// holds encrypted recipient while Recipient holds its blind index, they are empty once the message
+	// is read
+	ContentEncrypted   string `json:"-" bson:"content_encrypted,omitempty"`
+	RecipientEncrypted string `json:"-" bson:"recipient_encrypted,omitempty"`
+}
+
+// IsRevoked reports whether api key is revoked
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// NewResponseMessage returns response message of message transaction
+func NewResponseMessage(m MessageTransaction) ResponseMessage {
+	return ResponseMessage{
+		ID:        m.ID.Hex(),
+		Content:   m.Content,
+		Recipient: m.Recipient,
+		Status:    m.Status,
+		Tags:      m.Tags,
+		SentAt:    m.SentAt,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// NewMessageEvent returns status change event of message transaction
+func NewMessageEvent(m MessageTransaction) MessageEvent {
+	return MessageEvent{
+		ID:         m.ID.Hex(),
+		Status:     m.Status,
+		Recipient:  m.Recipient,
+		Tags:       m.Tags,
+		Version:    m.Version,
+		OccurredAt: time.Now(),
+	}
+}
+
 func (m *MessageTransaction) IsValid() bool {
 	if len(m.Content) > MaxMessageLength || len(m.Recipient) == 0 || len(m.Content) == 0 {
 		return false
 	}
+	if m.Priority < 0 || m.Priority > MaxMessagePriority {
+		return false
+	}
 	return true
 }
 
+// Revision returns message's current editable values as a revision
+func (m *MessageTransaction) Revision(changedAt time.Time) MessageRevision {
+	return MessageRevision{
+		Content:   m.Content,
+		Recipient: m.Recipient,
+		Priority:  m.Priority,
+		SendAt:    m.SendAt,
+		Version:   m.Version,
+		ChangedAt: changedAt,
+	}
+}
+
 type HealthStatus atomic.Bool
 
 func (s *HealthStatus) SetStatus(state bool) {
@@ -80,8 +344,26 @@ var (
 // Service defines behaviors of sample service
 type Service interface {
 	Health(context.Context, HealthRequest) HealthResponse
+	Liveness(context.Context, LivenessRequest) LivenessResponse
+	Readiness(context.Context, ReadinessRequest) ReadinessResponse
 	StartStopMessageSending(context.Context, StartStopMessageSendingRequest) StartStopMessageSendingResponse
+	GetWorkerStatus(context.Context, GetWorkerStatusRequest) GetWorkerStatusResponse
+	RunWorkerBatch(context.Context, RunWorkerBatchRequest) RunWorkerBatchResponse
+	CreateMessage(context.Context, CreateMessageRequest) CreateMessageResponse
 	RetrieveSentMessages(context.Context, RetrieveSentMessagesRequest) RetrieveSentMessagesResponse
+	CancelMessage(context.Context, CancelMessageRequest) CancelMessageResponse
+	CancelMessages(context.Context, CancelMessagesRequest) CancelMessagesResponse
+	RequeueMessages(context.Context, RequeueMessagesRequest) RequeueMessagesResponse
+	UpdateMessage(context.Context, UpdateMessageRequest) UpdateMessageResponse
+	GetMessage(context.Context, GetMessageRequest) GetMessageResponse
+	GetStats(context.Context, GetStatsRequest) GetStatsResponse
+	ExportMessages(context.Context, ExportMessagesRequest) ExportMessagesResponse
+	StreamMessageEvents(context.Context, StreamMessageEventsRequest) StreamMessageEventsResponse
+	CreateAPIKey(context.Context, CreateAPIKeyRequest) CreateAPIKeyResponse
+	ListAPIKeys(context.Context, ListAPIKeysRequest) ListAPIKeysResponse
+	RevokeAPIKey(context.Context, RevokeAPIKeyRequest) RevokeAPIKeyResponse
+	ReceiveDeliveryReceipt(context.Context, DeliveryReceiptRequest) DeliveryReceiptResponse
+	GetSandboxMessages(context.Context, GetSandboxMessagesRequest) GetSandboxMessagesResponse
 
 	StartSendMessage(count int, delay time.Duration)
 }
@@ -100,11 +382,51 @@ type Response interface {
 // compile-time proofs of request interface implementation
 var (
 	_ Request = (*HealthRequest)(nil)
+	_ Request = (*LivenessRequest)(nil)
+	_ Request = (*ReadinessRequest)(nil)
+	_ Request = (*StartStopMessageSendingRequest)(nil)
+	_ Request = (*GetWorkerStatusRequest)(nil)
+	_ Request = (*RunWorkerBatchRequest)(nil)
+	_ Request = (*CreateMessageRequest)(nil)
+	_ Request = (*RetrieveSentMessagesRequest)(nil)
+	_ Request = (*CancelMessageRequest)(nil)
+	_ Request = (*CancelMessagesRequest)(nil)
+	_ Request = (*RequeueMessagesRequest)(nil)
+	_ Request = (*UpdateMessageRequest)(nil)
+	_ Request = (*GetMessageRequest)(nil)
+	_ Request = (*GetStatsRequest)(nil)
+	_ Request = (*ExportMessagesRequest)(nil)
+	_ Request = (*StreamMessageEventsRequest)(nil)
+	_ Request = (*CreateAPIKeyRequest)(nil)
+	_ Request = (*ListAPIKeysRequest)(nil)
+	_ Request = (*RevokeAPIKeyRequest)(nil)
+	_ Request = (*DeliveryReceiptRequest)(nil)
+	_ Request = (*GetSandboxMessagesRequest)(nil)
 )
 
 // compile-time proofs of response interface implementation
 var (
 	_ Response = (*HealthResponse)(nil)
+	_ Response = (*LivenessResponse)(nil)
+	_ Response = (*ReadinessResponse)(nil)
+	_ Response = (*StartStopMessageSendingResponse)(nil)
+	_ Response = (*GetWorkerStatusResponse)(nil)
+	_ Response = (*RunWorkerBatchResponse)(nil)
+	_ Response = (*CreateMessageResponse)(nil)
+	_ Response = (*RetrieveSentMessagesResponse)(nil)
+	_ Response = (*CancelMessageResponse)(nil)
+	_ Response = (*CancelMessagesResponse)(nil)
+	_ Response = (*RequeueMessagesResponse)(nil)
+	_ Response = (*UpdateMessageResponse)(nil)
+	_ Response = (*GetMessageResponse)(nil)
+	_ Response = (*GetStatsResponse)(nil)
+	_ Response = (*ExportMessagesResponse)(nil)
+	_ Response = (*StreamMessageEventsResponse)(nil)
+	_ Response = (*CreateAPIKeyResponse)(nil)
+	_ Response = (*ListAPIKeysResponse)(nil)
+	_ Response = (*RevokeAPIKeyResponse)(nil)
+	_ Response = (*DeliveryReceiptResponse)(nil)
+	_ Response = (*GetSandboxMessagesResponse)(nil)
 )
 
 // HealthRequest and HealthResponse represents health request and response
@@ -113,6 +435,24 @@ type (
 	HealthResponse struct{}
 )
 
+// LivenessRequest and LivenessResponse represents liveness probe request and response
+type (
+	LivenessRequest  struct{}
+	LivenessResponse struct {
+		Result     *apierror.APIError         `json:"result"`
+		Components map[string]ComponentHealth `json:"components"`
+	}
+)
+
+// ReadinessRequest and ReadinessResponse represents readiness probe request and response
+type (
+	ReadinessRequest  struct{}
+	ReadinessResponse struct {
+		Result     *apierror.APIError         `json:"result"`
+		Components map[string]ComponentHealth `json:"components"`
+	}
+)
+
 // StartStopMessageSendingRequest and StartStopMessageSendingResponse represents request and response
 type (
 	StartStopMessageSendingRequest struct {
@@ -125,14 +465,258 @@ type (
 	}
 )
 
+// GetWorkerStatusRequest and GetWorkerStatusResponse represents request and response
+type (
+	GetWorkerStatusRequest struct {
+		IPAddress string `json:"-"`
+		Batches   int    `json:"-" query:"batches" validate:"omitempty,min=1,max=20"`
+	}
+	GetWorkerStatusResponse struct {
+		Result *apierror.APIError `json:"result"`
+		Status *WorkerStatus      `json:"status"`
+	}
+)
+
+// RunWorkerBatchRequest and RunWorkerBatchResponse represents request and response
+type (
+	RunWorkerBatchRequest struct {
+		IPAddress string `json:"-"`
+		DryRun    bool   `json:"dry_run"`
+	}
+	RunWorkerBatchResponse struct {
+		Result   *apierror.APIError `json:"result"`
+		Status   string             `json:"status"`             // "started" or "dry_run"
+		Messages []PlannedSend      `json:"messages,omitempty"` // messages the batch would process on dry run
+	}
+)
+
+// CreateMessageRequest and CreateMessageResponse represents request and response
+type (
+	CreateMessageRequest struct {
+		IPAddress string     `json:"-"`
+		Content   string     `json:"content" validate:"required,max=1000"`
+		Recipient string     `json:"recipient" validate:"required"`
+		SendAt    *time.Time `json:"send_at"`
+		Priority  int        `json:"priority" validate:"min=0,max=10"`
+		Tags      []string   `json:"tags"`
+	}
+	CreateMessageResponse struct {
+		Result  *apierror.APIError  `json:"result"`
+		Message *MessageTransaction `json:"message"`
+	}
+)
+
 // RetrieveSentMessagesRequest and RetrieveSentMessagesResponse represents request and response
 type (
 	RetrieveSentMessagesRequest struct {
-		IPAddress string `json:"-"`
+		IPAddress   string     `json:"-"`
+		Cursor      string     `json:"-" query:"cursor"`
+		Limit       int64      `json:"-" query:"limit" validate:"omitempty,min=1,max=100"`
+		Recipient   string     `json:"-" query:"recipient"`
+		Status      []string   `json:"-" query:"status" validate:"omitempty,dive,oneof=pending processing sent delivered failed invalid cancelled blocked_by_environment"`
+		Tags        []string   `json:"-" query:"tag"`
+		CreatedFrom *time.Time `json:"-" query:"created_from"`
+		CreatedTo   *time.Time `json:"-" query:"created_to"`
+		SentFrom    *time.Time `json:"-" query:"sent_from"`
+		SentTo      *time.Time `json:"-" query:"sent_to"`
+		SortBy      string     `json:"-" query:"sort_by" validate:"omitempty,oneof=created_at sent_at"`
+		SortOrder   string     `json:"-" query:"sort_order" validate:"omitempty,oneof=asc desc"`
 	}
 	RetrieveSentMessagesResponse struct {
-		Result   *apierror.APIError   `json:"result"`
-		Messages []MessageTransaction `json:"messages"`
+		Result     *apierror.APIError `json:"result"`
+		Messages   []ResponseMessage  `json:"messages"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}
+)
+
+// CancelMessageRequest and CancelMessageResponse represents request and response
+type (
+	CancelMessageRequest struct {
+		IPAddress string `json:"-"`
+		ID        string `json:"id" validate:"required,len=24,hexadecimal"`
+	}
+	CancelMessageResponse struct {
+		Result  *apierror.APIError  `json:"result"`
+		Message *MessageTransaction `json:"message"`
+	}
+)
+
+// CancelMessagesRequest and CancelMessagesResponse represents request and response
+type (
+	CancelMessagesRequest struct {
+		IPAddress   string     `json:"-"`
+		IDs         []string   `json:"ids" validate:"omitempty,dive,len=24,hexadecimal"`
+		Recipient   string     `json:"recipient"`
+		CreatedFrom *time.Time `json:"created_from"`
+		CreatedTo   *time.Time `json:"created_to"`
+	}
+	CancelMessagesResponse struct {
+		Result         *apierror.APIError `json:"result"`
+		CancelledCount int64              `json:"cancelled_count"`
+		// SkippedCount and SkippedIDs are messages matching filter which were claimed by a worker
+		SkippedCount int64    `json:"skipped_count"`
+		SkippedIDs   []string `json:"skipped_ids"`
+	}
+)
+
+// RequeueMessagesRequest and RequeueMessagesResponse represents request and response
+type (
+	RequeueMessagesRequest struct {
+		IPAddress   string     `json:"-"`
+		IDs         []string   `json:"ids" validate:"omitempty,dive,len=24,hexadecimal"`
+		Status      []string   `json:"status" validate:"omitempty,dive,oneof=failed invalid"` // failed and invalid when empty
+		Recipient   string     `json:"recipient"`
+		CreatedFrom *time.Time `json:"created_from"`
+		CreatedTo   *time.Time `json:"created_to"`
+	}
+	RequeueMessagesResponse struct {
+		Result        *apierror.APIError `json:"result"`
+		RequeuedCount int64              `json:"requeued_count"`
+	}
+)
+
+// UpdateMessageRequest and UpdateMessageResponse represents request and response
+type (
+	UpdateMessageRequest struct {
+		IPAddress string     `json:"-"`
+		ID        string     `json:"id" validate:"required,len=24,hexadecimal"`
+		Version   *int64     `json:"version"` // optional, expected current version of the message
+		Content   *string    `json:"content" validate:"omitempty,max=1000"`
+		Recipient *string    `json:"recipient" validate:"omitempty,min=1"`
+		SendAt    *time.Time `json:"send_at"`
+		SendNow   bool       `json:"send_now" validate:"excluded_with=SendAt"` // clears send time so that message is sent by the next batch
+		Priority  *int       `json:"priority" validate:"omitempty,min=0,max=10"`
+	}
+	UpdateMessageResponse struct {
+		Result  *apierror.APIError  `json:"result"`
+		Message *MessageTransaction `json:"message"`
+	}
+)
+
+// GetMessageRequest and GetMessageResponse represents request and response
+type (
+	GetMessageRequest struct {
+		IPAddress string `json:"-"`
+		ID        string `json:"-" path:"id" validate:"required,len=24,hexadecimal"`
+	}
+	GetMessageResponse struct {
+		Result  *apierror.APIError  `json:"result"`
+		Message *MessageTransaction `json:"message"`
+	}
+)
+
+// GetStatsRequest and GetStatsResponse represents request and response
+type (
+	GetStatsRequest struct {
+		IPAddress     string `json:"-"`
+		WindowMinutes int    `json:"-" query:"window_minutes" validate:"omitempty,min=1,max=1440"`
+	}
+	GetStatsResponse struct {
+		Result *apierror.APIError `json:"result"`
+		Stats  *MessageStats      `json:"stats"`
+	}
+)
+
+// ExportMessagesRequest and ExportMessagesResponse represents request and response
+type (
+	ExportMessagesRequest struct {
+		IPAddress   string     `json:"-"`
+		Accept      string     `json:"-" header:"Accept"`
+		Format      string     `json:"-" query:"format" validate:"omitempty,oneof=csv ndjson"`
+		Recipient   string     `json:"-" query:"recipient"`
+		Status      []string   `json:"-" query:"status" validate:"omitempty,dive,oneof=pending processing sent delivered failed invalid cancelled blocked_by_environment"`
+		Tags        []string   `json:"-" query:"tag"`
+		CreatedFrom *time.Time `json:"-" query:"created_from"`
+		CreatedTo   *time.Time `json:"-" query:"created_to"`
+		SentFrom    *time.Time `json:"-" query:"sent_from"`
+		SentTo      *time.Time `json:"-" query:"sent_to"`
+	}
+	ExportMessagesResponse struct {
+		Result *apierror.APIError `json:"result"`
+		Format string             `json:"-"`
+		// Messages calls fn for each exported message, it stops at the first error returned by fn
+		Messages func(fn func(ResponseMessage) error) error `json:"-"`
+	}
+)
+
+// StreamMessageEventsRequest and StreamMessageEventsResponse represents request and response
+type (
+	StreamMessageEventsRequest struct {
+		IPAddress string   `json:"-"`
+		Recipient string   `json:"-" query:"recipient"`
+		Campaign  string   `json:"-" query:"campaign"`
+		Status    []string `json:"-" query:"status" validate:"omitempty,dive,oneof=pending processing sent delivered failed invalid cancelled blocked_by_environment"`
+	}
+	StreamMessageEventsResponse struct {
+		Result *apierror.APIError `json:"result"`
+		// Events is closed when the request context is done
+		Events <-chan MessageEvent `json:"-"`
+		// MaskPII is true when the subscriber can not read personal data, stream encoders mask its events
+		MaskPII bool `json:"-"`
+	}
+)
+
+// CreateAPIKeyRequest and CreateAPIKeyResponse represents request and response
+type (
+	CreateAPIKeyRequest struct {
+		IPAddress string   `json:"-"`
+		Name      string   `json:"name" validate:"required,max=100"`
+		Role      string   `json:"role" validate:"required_without=Scopes,omitempty,oneof=ingestion support sre admin"`
+		Scopes    []string `json:"scopes" validate:"required_without=Role,omitempty,dive,oneof=messages:create messages:read messages:write worker:control keys:admin pii:read"`
+	}
+	CreateAPIKeyResponse struct {
+		Result *apierror.APIError `json:"result"`
+		APIKey *APIKey            `json:"api_key"`
+		Key    string             `json:"key"` // returned only once, it can not be read again
+	}
+)
+
+// ListAPIKeysRequest and ListAPIKeysResponse represents request and response
+type (
+	ListAPIKeysRequest struct {
+		IPAddress string `json:"-"`
+	}
+	ListAPIKeysResponse struct {
+		Result  *apierror.APIError `json:"result"`
+		APIKeys []APIKey           `json:"api_keys"`
+	}
+)
+
+// RevokeAPIKeyRequest and RevokeAPIKeyResponse represents request and response
+type (
+	RevokeAPIKeyRequest struct {
+		IPAddress string `json:"-"`
+		ID        string `json:"id" validate:"required,len=24,hexadecimal"`
+	}
+	RevokeAPIKeyResponse struct {
+		Result *apierror.APIError `json:"result"`
+		APIKey *APIKey            `json:"api_key"`
+	}
+)
+
+// DeliveryReceiptRequest and DeliveryReceiptResponse represents provider callback request and response
+type (
+	DeliveryReceiptRequest struct {
+		IPAddress         string     `json:"-"`
+		ProviderMessageID string     `json:"messageId" validate:"required"`
+		Status            string     `json:"status" validate:"required,oneof=delivered"`
+		DeliveredAt       *time.Time `json:"deliveredAt"`
+	}
+	DeliveryReceiptResponse struct {
+		Result *apierror.APIError `json:"result"`
+	}
+)
+
+// GetSandboxMessagesRequest and GetSandboxMessagesResponse represents request and response
+type (
+	GetSandboxMessagesRequest struct {
+		IPAddress string `json:"-"`
+		Recipient string `json:"-" query:"recipient"`
+		Limit     int64  `json:"-" query:"limit" validate:"omitempty,min=1,max=100"`
+	}
+	GetSandboxMessagesResponse struct {
+		Result   *apierror.APIError `json:"result"`
+		Messages []SandboxMessage   `json:"messages"`
 	}
 )
 
@@ -144,16 +728,102 @@ type Header struct {
 // SetIPAddress does nothing since health request doesn't have ip address
 func (r *HealthRequest) SetIPAddress(_ string) {}
 
+// SetIPAddress does nothing since liveness request doesn't have ip address
+func (r *LivenessRequest) SetIPAddress(_ string) {}
+
+// SetIPAddress does nothing since readiness request doesn't have ip address
+func (r *ReadinessRequest) SetIPAddress(_ string) {}
+
 // SetIPAddress request's ip address
 func (r *StartStopMessageSendingRequest) SetIPAddress(ipAddress string) {
 	r.IPAddress = ipAddress
 }
 
+// SetIPAddress request's ip address
+func (r *GetWorkerStatusRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *RunWorkerBatchRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *CreateMessageRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
 // SetIPAddress request's ip address
 func (r *RetrieveSentMessagesRequest) SetIPAddress(ipAddress string) {
 	r.IPAddress = ipAddress
 }
 
+// SetIPAddress request's ip address
+func (r *CancelMessageRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *CancelMessagesRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *RequeueMessagesRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *UpdateMessageRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *GetMessageRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *GetStatsRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *ExportMessagesRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *StreamMessageEventsRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *CreateAPIKeyRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *ListAPIKeysRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *RevokeAPIKeyRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *DeliveryReceiptRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
+// SetIPAddress request's ip address
+func (r *GetSandboxMessagesRequest) SetIPAddress(ipAddress string) {
+	r.IPAddress = ipAddress
+}
+
 // APIError returns error when API is shutting down
 func (r HealthResponse) APIError() error {
 	if !HEALTH_STATUS.GetStatus() {
@@ -162,6 +832,24 @@ func (r HealthResponse) APIError() error {
 	return nil
 }
 
+// APIError returns error when a component the probe depends on is unhealthy
+func (r LivenessResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns error when API is shutting down or a component the probe depends on is unhealthy
+func (r ReadinessResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
 // APIError returns error when API is shutting down
 func (r StartStopMessageSendingResponse) APIError() error {
 	if r.Result == nil {
@@ -171,6 +859,33 @@ func (r StartStopMessageSendingResponse) APIError() error {
 	return r.Result
 }
 
+// APIError returns response's api error
+func (r GetWorkerStatusResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r RunWorkerBatchResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r CreateMessageResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
 // APIError returns error when API is shutting down
 func (r RetrieveSentMessagesResponse) APIError() error {
 	if r.Result == nil {
@@ -180,17 +895,224 @@ func (r RetrieveSentMessagesResponse) APIError() error {
 	return r.Result
 }
 
+// APIError returns response's api error
+func (r CancelMessageResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r CancelMessagesResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r RequeueMessagesResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r UpdateMessageResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r GetMessageResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r GetStatsResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r ExportMessagesResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r StreamMessageEventsResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r CreateAPIKeyResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r ListAPIKeysResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r RevokeAPIKeyResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r DeliveryReceiptResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
+// APIError returns response's api error
+func (r GetSandboxMessagesResponse) APIError() error {
+	if r.Result == nil {
+		return nil
+	}
+
+	return r.Result
+}
+
 // Localize localizes response
 func (r HealthResponse) Localize(_ *i18n.Localizer) interface{} {
 	return r
 }
 
+// Localize localizes response
+func (r LivenessResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r ReadinessResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
 // Localize localizes response
 func (r StartStopMessageSendingResponse) Localize(_ *i18n.Localizer) interface{} {
 	return r
 }
 
+// Localize localizes response
+func (r GetWorkerStatusResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r RunWorkerBatchResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r CreateMessageResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
 // Localize localizes response
 func (r RetrieveSentMessagesResponse) Localize(_ *i18n.Localizer) interface{} {
 	return r
 }
+
+// Localize localizes response
+func (r CancelMessageResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r CancelMessagesResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r RequeueMessagesResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r UpdateMessageResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r GetMessageResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r GetStatsResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r ExportMessagesResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r StreamMessageEventsResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r CreateAPIKeyResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r ListAPIKeysResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r RevokeAPIKeyResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r DeliveryReceiptResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}
+
+// Localize localizes response
+func (r GetSandboxMessagesResponse) Localize(_ *i18n.Localizer) interface{} {
+	return r
+}